@@ -0,0 +1,48 @@
+package v1
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// ManagerSpec and WorkerSpec are the OpenAPI documents of the manager and
+// worker APIs. Both daemons serve theirs at /v1/openapi.json; tests keep
+// them in sync with the routes and the types of this package.
+var (
+	//go:embed manager.json
+	ManagerSpec []byte
+	//go:embed worker.json
+	WorkerSpec []byte
+)
+
+// SpecHandler serves the OpenAPI document spec.
+func SpecHandler(spec []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// Operations lists the operations spec documents as "METHOD /path",
+// sorted.
+func Operations(spec []byte) ([]string, error) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage
+	}
+	err := json.Unmarshal(spec, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []string
+	for path, methods := range doc.Paths {
+		for method := range methods {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(ops)
+	return ops, nil
+}
@@ -0,0 +1,144 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type schema struct {
+	Ref                  string `json:"$ref"`
+	Type                 string
+	Format               string
+	Required             []string
+	Properties           map[string]schema
+	Items                *schema
+	AdditionalProperties *schema
+}
+
+func loadSchemas(t *testing.T, spec []byte) map[string]schema {
+	var doc struct {
+		Components struct {
+			Schemas map[string]schema
+		}
+	}
+	err := json.Unmarshal(spec, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc.Components.Schemas
+}
+
+// checkSchema compares the schema s with the JSON encoding of typ: the
+// properties must be the encoded fields, the required properties those
+// that are never omitted, and every property must have the type of its
+// field.
+func checkSchema(t *testing.T, name string, s schema, typ reflect.Type) {
+	fields := map[string]reflect.StructField{}
+	var required []string
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		tag, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if tag == "" || tag == "-" {
+			t.Errorf("%s.%s has no JSON name", name, f.Name)
+			continue
+		}
+		fields[tag] = f
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, tag)
+		}
+	}
+
+	for prop := range s.Properties {
+		if _, ok := fields[prop]; !ok {
+			t.Errorf("%s: property %s is not a field of %s", name, prop, typ)
+		}
+	}
+	for tag, f := range fields {
+		prop, ok := s.Properties[tag]
+		if !ok {
+			t.Errorf("%s: field %s is not documented", name, tag)
+			continue
+		}
+		want := schemaType(f.Type)
+		if got := prop.describe(); got != want {
+			t.Errorf("%s.%s: documented as %s, want %s", name, tag, got, want)
+		}
+	}
+	slices.Sort(required)
+	got := slices.Clone(s.Required)
+	slices.Sort(got)
+	if !slices.Equal(got, required) {
+		t.Errorf("%s: required properties are %v, want %v", name, got, required)
+	}
+}
+
+// describe summarizes the type of s, e.g. "array of string".
+func (s schema) describe() string {
+	switch {
+	case s.Ref != "":
+		return strings.TrimPrefix(s.Ref, "#/components/schemas/")
+	case s.Type == "array" && s.Items != nil:
+		return "array of " + s.Items.describe()
+	case s.Type == "object" && s.AdditionalProperties != nil:
+		return "map of " + s.AdditionalProperties.describe()
+	case s.Format == "uuid" || s.Format == "date-time":
+		return s.Format
+	}
+	return s.Type
+}
+
+// schemaType is the description of the schema that documents values of t.
+func schemaType(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf(uuid.UUID{}):
+		return "uuid"
+	case reflect.TypeOf(time.Time{}):
+		return "date-time"
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaType(t.Elem())
+	case reflect.Struct:
+		return t.Name()
+	case reflect.Slice:
+		return "array of " + schemaType(t.Elem())
+	case reflect.Map:
+		return "map of " + schemaType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	}
+	panic(fmt.Sprintf("no schema type for %s", t))
+}
+
+func TestSpecsMatchTypes(t *testing.T) {
+	types := map[string]reflect.Type{}
+	for _, v := range []any{Error{}, TaskRequest{}, Task{}, Owner{}, TaskList{}, Node{}, NodeList{}} {
+		types[reflect.TypeOf(v).Name()] = reflect.TypeOf(v)
+	}
+
+	specs := map[string][]byte{"manager": ManagerSpec, "worker": WorkerSpec}
+	for specName, spec := range specs {
+		for name, s := range loadSchemas(t, spec) {
+			typ, ok := types[name]
+			if !ok {
+				t.Errorf("%s: schema %s has no type", specName, name)
+				continue
+			}
+			checkSchema(t, specName+" "+name, s, typ)
+		}
+	}
+}
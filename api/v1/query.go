@@ -0,0 +1,209 @@
+package v1
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/task"
+)
+
+// TaskSortFields are the fields tasks can be sorted by. Ties are broken by
+// ID.
+var TaskSortFields = []string{"id", "name", "image", "node", "state", "started", "finished"}
+
+// TaskQuery selects tasks, and orders and pages the result. Zero fields
+// do not restrict the result.
+type TaskQuery struct {
+	Selector labels.Selector
+	// States lists the states tasks may be in.
+	States []task.State
+	// Node is the worker the tasks are placed on.
+	Node  string
+	Image string
+	// Name is part of the name of the tasks.
+	Name string
+
+	StartedAfter   time.Time
+	StartedBefore  time.Time
+	FinishedAfter  time.Time
+	FinishedBefore time.Time
+
+	// Sort is the field tasks are sorted by, prefixed with "-" for
+	// descending order. It defaults to "id".
+	Sort string
+	// Limit is the maximum number of tasks returned at once. Cursor
+	// continues a listing where the previous page ended.
+	Limit  int
+	Cursor string
+}
+
+// ParseTaskQuery parses the query parameters of a task listing.
+func ParseTaskQuery(v url.Values) (TaskQuery, error) {
+	var q TaskQuery
+	var err error
+
+	q.Selector, err = labels.Parse(v.Get("labelSelector"))
+	if err != nil {
+		return q, fmt.Errorf("invalid label selector: %v", err)
+	}
+	for _, s := range v["state"] {
+		for _, name := range strings.Split(s, ",") {
+			st, err := task.ParseState(strings.TrimSpace(name))
+			if err != nil {
+				return q, err
+			}
+			q.States = append(q.States, st)
+		}
+	}
+	q.Node = v.Get("node")
+	q.Image = v.Get("image")
+	q.Name = v.Get("name")
+
+	times := map[string]*time.Time{
+		"startedAfter":   &q.StartedAfter,
+		"startedBefore":  &q.StartedBefore,
+		"finishedAfter":  &q.FinishedAfter,
+		"finishedBefore": &q.FinishedBefore,
+	}
+	for param, t := range times {
+		if v.Get(param) == "" {
+			continue
+		}
+		*t, err = time.Parse(time.RFC3339, v.Get(param))
+		if err != nil {
+			return q, fmt.Errorf("invalid %s: %v", param, err)
+		}
+	}
+
+	q.Sort = v.Get("sort")
+	if !slices.Contains(TaskSortFields, strings.TrimPrefix(q.Sort, "-")) && q.Sort != "" {
+		return q, fmt.Errorf("cannot sort by %q", q.Sort)
+	}
+	if v.Get("limit") != "" {
+		q.Limit, err = strconv.Atoi(v.Get("limit"))
+		if err != nil || q.Limit < 0 {
+			return q, fmt.Errorf("invalid limit %q", v.Get("limit"))
+		}
+	}
+	q.Cursor = v.Get("cursor")
+	return q, nil
+}
+
+// Values encodes q as query parameters, the inverse of ParseTaskQuery.
+func (q TaskQuery) Values() url.Values {
+	v := url.Values{}
+	if !q.Selector.Empty() {
+		v.Set("labelSelector", q.Selector.String())
+	}
+	var states []string
+	for _, st := range q.States {
+		states = append(states, st.String())
+	}
+	if len(states) > 0 {
+		v.Set("state", strings.Join(states, ","))
+	}
+	params := map[string]string{
+		"node":   q.Node,
+		"image":  q.Image,
+		"name":   q.Name,
+		"sort":   q.Sort,
+		"cursor": q.Cursor,
+	}
+	for param, s := range params {
+		if s != "" {
+			v.Set(param, s)
+		}
+	}
+	times := map[string]time.Time{
+		"startedAfter":   q.StartedAfter,
+		"startedBefore":  q.StartedBefore,
+		"finishedAfter":  q.FinishedAfter,
+		"finishedBefore": q.FinishedBefore,
+	}
+	for param, t := range times {
+		if !t.IsZero() {
+			v.Set(param, t.Format(time.RFC3339))
+		}
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return v
+}
+
+// Matches reports whether t is selected by q.
+func (q TaskQuery) Matches(t *task.Task) bool {
+	switch {
+	case !q.Selector.Matches(t.Labels):
+		return false
+	case len(q.States) > 0 && !slices.Contains(q.States, t.State):
+		return false
+	case q.Node != "" && t.Worker != q.Node:
+		return false
+	case q.Image != "" && t.Image != q.Image:
+		return false
+	case q.Name != "" && !strings.Contains(t.Name, q.Name):
+		return false
+	}
+	return inRange(t.StartTime, q.StartedAfter, q.StartedBefore) &&
+		inRange(t.FinishTime, q.FinishedAfter, q.FinishedBefore)
+}
+
+// inRange reports whether t is within the range from after to before.
+// Zero bounds are open; a zero t is outside any range that is not.
+func inRange(t time.Time, after time.Time, before time.Time) bool {
+	if after.IsZero() && before.IsZero() {
+		return true
+	}
+	if t.IsZero() {
+		return false
+	}
+	return !t.Before(after) && (before.IsZero() || t.Before(before))
+}
+
+// LogOptions select the output of a task that is returned.
+type LogOptions struct {
+	// Follow keeps the response open and streams new output as the task
+	// writes it.
+	Follow bool
+	// Tail is the number of lines from the end of the output to return;
+	// zero returns all of it.
+	Tail int
+}
+
+// ParseLogOptions parses the query parameters of a logs request.
+func ParseLogOptions(v url.Values) (LogOptions, error) {
+	var o LogOptions
+	var err error
+
+	if v.Get("follow") != "" {
+		o.Follow, err = strconv.ParseBool(v.Get("follow"))
+		if err != nil {
+			return o, fmt.Errorf("invalid follow %q", v.Get("follow"))
+		}
+	}
+	if v.Get("tail") != "" {
+		o.Tail, err = strconv.Atoi(v.Get("tail"))
+		if err != nil || o.Tail < 0 {
+			return o, fmt.Errorf("invalid tail %q", v.Get("tail"))
+		}
+	}
+	return o, nil
+}
+
+// Values encodes o as query parameters, the inverse of ParseLogOptions.
+func (o LogOptions) Values() url.Values {
+	v := url.Values{}
+	if o.Follow {
+		v.Set("follow", "true")
+	}
+	if o.Tail > 0 {
+		v.Set("tail", strconv.Itoa(o.Tail))
+	}
+	return v
+}
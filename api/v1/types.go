@@ -0,0 +1,318 @@
+// Package v1 defines the requests and responses of version 1 of the HTTP
+// APIs of the manager and the worker, which are served under /v1. Unlike
+// the unversioned routes, which expose the internal types as they are, v1
+// only changes in ways that keep existing clients working.
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/task"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+)
+
+// Reason classifies an Error, so that clients can tell errors apart without
+// parsing messages.
+type Reason string
+
+const (
+	ReasonBadRequest           Reason = "BadRequest"
+	ReasonUnauthorized         Reason = "Unauthorized"
+	ReasonNotFound             Reason = "NotFound"
+	ReasonMethodNotAllowed     Reason = "MethodNotAllowed"
+	ReasonConflict             Reason = "Conflict"
+	ReasonIdempotencyKeyReused Reason = "IdempotencyKeyReused"
+	ReasonQuotaExceeded        Reason = "QuotaExceeded"
+	ReasonUnavailable          Reason = "Unavailable"
+	ReasonInternal             Reason = "Internal"
+)
+
+// Error is the body of every error response.
+type Error struct {
+	Status  int    `json:"status"`
+	Reason  Reason `json:"reason"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError returns the error with the given status, classified by it.
+func NewError(status int, message string) *Error {
+	e := &Error{Status: status, Message: message}
+	switch status {
+	case http.StatusBadRequest:
+		e.Reason = ReasonBadRequest
+	case http.StatusUnauthorized:
+		e.Reason = ReasonUnauthorized
+	case http.StatusNotFound:
+		e.Reason = ReasonNotFound
+	case http.StatusMethodNotAllowed:
+		e.Reason = ReasonMethodNotAllowed
+	case http.StatusConflict:
+		e.Reason = ReasonConflict
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		e.Reason = ReasonUnavailable
+	default:
+		e.Reason = ReasonInternal
+	}
+	return e
+}
+
+// Respond writes v as the JSON body of a response with the given status.
+func Respond(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// RespondError writes e as an error response.
+func RespondError(w http.ResponseWriter, e *Error) {
+	Respond(w, e.Status, e)
+}
+
+// TaskRequest submits a task. The manager generates the ID unless one is
+// given.
+type TaskRequest struct {
+	ID             uuid.UUID         `json:"id,omitempty"`
+	Name           string            `json:"name,omitempty"`
+	Image          string            `json:"image"`
+	Cmd            []string          `json:"cmd,omitempty"`
+	Env            []string          `json:"env,omitempty"`
+	Cpu            float64           `json:"cpu,omitempty"`
+	Memory         int64             `json:"memory,omitempty"`
+	Disk           int64             `json:"disk,omitempty"`
+	ExposedPorts   []string          `json:"exposedPorts,omitempty"`
+	PortBindings   map[string]string `json:"portBindings,omitempty"`
+	RestartPolicy  string            `json:"restartPolicy,omitempty"`
+	HealthCheck    string            `json:"healthCheck,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	NonPreemptible bool              `json:"nonPreemptible,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+}
+
+// Task is a task and its current state.
+type Task struct {
+	ID             uuid.UUID         `json:"id"`
+	Namespace      string            `json:"namespace"`
+	Name           string            `json:"name"`
+	Image          string            `json:"image"`
+	Cmd            []string          `json:"cmd,omitempty"`
+	Env            []string          `json:"env,omitempty"`
+	Cpu            float64           `json:"cpu,omitempty"`
+	Memory         int64             `json:"memory,omitempty"`
+	Disk           int64             `json:"disk,omitempty"`
+	ExposedPorts   []string          `json:"exposedPorts,omitempty"`
+	PortBindings   map[string]string `json:"portBindings,omitempty"`
+	RestartPolicy  string            `json:"restartPolicy,omitempty"`
+	HealthCheck    string            `json:"healthCheck,omitempty"`
+	Priority       int               `json:"priority,omitempty"`
+	NonPreemptible bool              `json:"nonPreemptible,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+	Owner          *Owner            `json:"owner,omitempty"`
+
+	State        string `json:"state"`
+	DesiredState string `json:"desiredState"`
+	// Node is the worker the task is placed on.
+	Node        string `json:"node,omitempty"`
+	ContainerID string `json:"containerId,omitempty"`
+	// HostPorts maps the exposed ports to the ports of the node they are
+	// published on.
+	HostPorts    map[string]string `json:"hostPorts,omitempty"`
+	RestartCount int               `json:"restartCount"`
+	StartTime    *time.Time        `json:"startTime,omitempty"`
+	FinishTime   *time.Time        `json:"finishTime,omitempty"`
+}
+
+// Owner is the resource that created a task.
+type Owner struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Revision int    `json:"revision,omitempty"`
+	Index    int    `json:"index,omitempty"`
+}
+
+// TaskList is a page of tasks. Next is the cursor of the following page;
+// it is empty on the last page.
+type TaskList struct {
+	Items []Task `json:"items"`
+	Next  string `json:"next,omitempty"`
+}
+
+// Node is a worker node as the manager sees it.
+type Node struct {
+	Name            string `json:"name"`
+	Api             string `json:"api"`
+	Role            string `json:"role"`
+	Status          string `json:"status"`
+	Cores           int    `json:"cores"`
+	Memory          int64  `json:"memory"`
+	MemoryAllocated int64  `json:"memoryAllocated"`
+	Disk            int64  `json:"disk"`
+	DiskAllocated   int64  `json:"diskAllocated"`
+	// Tasks is the number of tasks the node last reported running.
+	Tasks         int               `json:"tasks"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+	Unschedulable bool              `json:"unschedulable"`
+	Draining      bool              `json:"draining"`
+	LastHeartbeat *time.Time        `json:"lastHeartbeat,omitempty"`
+	LastSeen      *time.Time        `json:"lastSeen,omitempty"`
+}
+
+// NodeList lists nodes.
+type NodeList struct {
+	Items []Node `json:"items"`
+}
+
+// TaskSpec returns the task r submits, in state Pending.
+func (r TaskRequest) TaskSpec() task.Task {
+	t := task.Task{
+		ID:             r.ID,
+		Name:           r.Name,
+		Image:          r.Image,
+		Cmd:            r.Cmd,
+		Env:            r.Env,
+		Cpu:            r.Cpu,
+		Memory:         r.Memory,
+		Disk:           r.Disk,
+		PortBindings:   r.PortBindings,
+		RestartPolicy:  r.RestartPolicy,
+		HealthCheck:    r.HealthCheck,
+		Priority:       r.Priority,
+		NonPreemptible: r.NonPreemptible,
+		Labels:         r.Labels,
+		Annotations:    r.Annotations,
+	}
+	if len(r.ExposedPorts) > 0 {
+		t.ExposedPorts = nat.PortSet{}
+		for _, p := range r.ExposedPorts {
+			t.ExposedPorts[nat.Port(p)] = struct{}{}
+		}
+	}
+	return t
+}
+
+// NewTaskRequest returns the request that submits t.
+func NewTaskRequest(t task.Task) TaskRequest {
+	r := TaskRequest{
+		ID:             t.ID,
+		Name:           t.Name,
+		Image:          t.Image,
+		Cmd:            t.Cmd,
+		Env:            t.Env,
+		Cpu:            t.Cpu,
+		Memory:         t.Memory,
+		Disk:           t.Disk,
+		PortBindings:   t.PortBindings,
+		RestartPolicy:  t.RestartPolicy,
+		HealthCheck:    t.HealthCheck,
+		Priority:       t.Priority,
+		NonPreemptible: t.NonPreemptible,
+		Labels:         t.Labels,
+		Annotations:    t.Annotations,
+	}
+	for p := range t.ExposedPorts {
+		r.ExposedPorts = append(r.ExposedPorts, string(p))
+	}
+	slices.Sort(r.ExposedPorts)
+	return r
+}
+
+// FromTask returns the API form of t.
+func FromTask(t *task.Task) Task {
+	v := Task{
+		ID:             t.ID,
+		Namespace:      t.Namespace,
+		Name:           t.Name,
+		Image:          t.Image,
+		Cmd:            t.Cmd,
+		Env:            t.Env,
+		Cpu:            t.Cpu,
+		Memory:         t.Memory,
+		Disk:           t.Disk,
+		PortBindings:   t.PortBindings,
+		RestartPolicy:  t.RestartPolicy,
+		HealthCheck:    t.HealthCheck,
+		Priority:       t.Priority,
+		NonPreemptible: t.NonPreemptible,
+		Labels:         t.Labels,
+		Annotations:    t.Annotations,
+		State:          t.State.String(),
+		DesiredState:   t.DesiredState.String(),
+		Node:           t.Worker,
+		ContainerID:    t.ContainerID,
+		RestartCount:   t.RestartCount,
+		StartTime:      timePtr(t.StartTime),
+		FinishTime:     timePtr(t.FinishTime),
+	}
+	for p := range t.ExposedPorts {
+		v.ExposedPorts = append(v.ExposedPorts, string(p))
+	}
+	slices.Sort(v.ExposedPorts)
+	for p, bindings := range t.HostPorts {
+		if len(bindings) == 0 {
+			continue
+		}
+		if v.HostPorts == nil {
+			v.HostPorts = make(map[string]string)
+		}
+		v.HostPorts[string(p)] = bindings[0].HostPort
+	}
+	if t.Owner != nil {
+		v.Owner = &Owner{
+			Kind:     t.Owner.Kind,
+			Name:     t.Owner.Name,
+			Revision: t.Owner.Revision,
+			Index:    t.Owner.Index,
+		}
+	}
+	return v
+}
+
+// FromTasks returns the API form of tasks.
+func FromTasks(tasks []*task.Task) []Task {
+	items := []Task{}
+	for _, t := range tasks {
+		items = append(items, FromTask(t))
+	}
+	return items
+}
+
+// FromNode returns the API form of n.
+func FromNode(n *node.Node) Node {
+	return Node{
+		Name:            n.Name,
+		Api:             n.Api,
+		Role:            n.Role,
+		Status:          string(n.Status),
+		Cores:           n.Cores,
+		Memory:          n.Memory,
+		MemoryAllocated: n.MemoryAllocated,
+		Disk:            n.Disk,
+		DiskAllocated:   n.DiskAllocated,
+		Tasks:           n.Stats.TaskCount,
+		Labels:          n.Labels,
+		Annotations:     n.Annotations,
+		Unschedulable:   n.Unschedulable,
+		Draining:        n.Draining,
+		LastHeartbeat:   timePtr(n.LastHeartbeat),
+		LastSeen:        timePtr(n.LastSeen),
+	}
+}
+
+// timePtr returns nil for the zero time, which is omitted from responses.
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
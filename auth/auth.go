@@ -0,0 +1,144 @@
+// Package auth authenticates requests to the manager and worker APIs. A
+// request carries a secret as a bearer token in its Authorization header.
+// Clients use the secrets of tokens the manager issued; the manager, its
+// replicas and the workers use a cluster token they share among each
+// other.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// secretPrefix starts every secret the manager issues, so that leaked
+// secrets are easy to recognize.
+const secretPrefix = "cube_"
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Token is a credential the manager issued. Only a hash of its secret is
+// kept; the secret itself is shown once, when the token is created.
+type Token struct {
+	Name string
+	// Hash is left out when tokens are listed.
+	Hash      string `json:",omitempty"`
+	CreatedAt time.Time
+	// ExpiresAt is the time the token stops being accepted. The zero
+	// time never expires.
+	ExpiresAt time.Time
+}
+
+// NewToken returns a token with the given name that expires at expiresAt,
+// or never if it is zero, and its secret.
+func NewToken(name string, expiresAt time.Time) (*Token, string, error) {
+	if !validName.MatchString(name) {
+		return nil, "", fmt.Errorf("invalid token name %q", name)
+	}
+	now := time.Now().UTC()
+	if !expiresAt.IsZero() && !expiresAt.After(now) {
+		return nil, "", fmt.Errorf("token %s would expire at %v, which has passed", name, expiresAt)
+	}
+
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, "", err
+	}
+	secret := secretPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	t := &Token{
+		Name:      name,
+		Hash:      Hash(secret),
+		CreatedAt: now,
+		ExpiresAt: expiresAt.UTC(),
+	}
+	return t, secret, nil
+}
+
+// Hash returns the hash of secret that is stored in place of it.
+func Hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify reports whether secret is the secret of t and t has not expired
+// at now.
+func (t *Token) Verify(secret string, now time.Time) bool {
+	if t.Expired(now) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(Hash(secret)), []byte(t.Hash)) == 1
+}
+
+// Expired reports whether t is no longer accepted at now.
+func (t *Token) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && !now.Before(t.ExpiresAt)
+}
+
+// Equal reports whether secret is the given shared secret, in constant
+// time.
+func Equal(secret string, shared string) bool {
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(shared)) == 1
+}
+
+// BearerToken returns the bearer token of r, or "" if it has none.
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// SetBearerToken makes r carry token.
+func SetBearerToken(r *http.Request, token string) {
+	r.Header.Set("Authorization", "Bearer "+token)
+}
+
+// Middleware lets requests through whose bearer token allow accepts. Other
+// requests are answered by deny, which should respond with status 401.
+func Middleware(allow func(r *http.Request, token string) bool, deny http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := BearerToken(r)
+			if token == "" || !allow(r, token) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="cube"`)
+				deny(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Transport adds a bearer token to the requests it sends that carry no
+// Authorization header yet. Requests a proxy passes on keep the token of
+// their sender.
+type Transport struct {
+	Token string
+	// Base sends the requests. It defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if t.Token == "" || r.Header.Get("Authorization") != "" {
+		return base.RoundTrip(r)
+	}
+
+	// A RoundTripper must not change the request it is given.
+	r = r.Clone(r.Context())
+	SetBearerToken(r, t.Token)
+	return base.RoundTrip(r)
+}
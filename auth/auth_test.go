@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToken(t *testing.T) {
+	tk, secret, err := NewToken("ci", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, secretPrefix) || strings.Contains(tk.Hash, secret) {
+		t.Errorf("got secret %q and hash %q", secret, tk.Hash)
+	}
+
+	now := time.Now()
+	if !tk.Verify(secret, now) {
+		t.Error("the token's own secret was rejected")
+	}
+	if tk.Verify(secret+"x", now) {
+		t.Error("a wrong secret was accepted")
+	}
+	if tk.Verify(secret, now.Add(2*time.Hour)) {
+		t.Error("the secret of an expired token was accepted")
+	}
+
+	for _, name := range []string{"", "a b", "-ci"} {
+		_, _, err := NewToken(name, time.Time{})
+		if err == nil {
+			t.Errorf("token name %q was accepted", name)
+		}
+	}
+	_, _, err = NewToken("old", now.Add(-time.Minute))
+	if err == nil {
+		t.Error("a token expiring in the past was created")
+	}
+}
+
+func TestMiddlewareAndTransport(t *testing.T) {
+	deny := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	allow := func(r *http.Request, token string) bool {
+		return Equal(token, "shared")
+	}
+	handler := Middleware(allow, deny)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		status int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"transport token", "shared", "", http.StatusNoContent},
+		{"wrong token", "wrong", "", http.StatusUnauthorized},
+		// The transport keeps the token the request was sent with.
+		{"request token", "wrong", "Bearer shared", http.StatusNoContent},
+		{"other scheme", "", "Basic shared", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		c := &http.Client{Transport: &Transport{Token: tt.token}}
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+		if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header", tt.name)
+		}
+	}
+}
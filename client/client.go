@@ -0,0 +1,325 @@
+// Package client talks to the API of a Cube manager. Tasks and nodes are
+// read and changed through version 1 of the API; see the v1 package for the
+// types it exchanges.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/auth"
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/watch"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultRetries is how often a failed request is repeated.
+	DefaultRetries = 3
+	// DefaultBackoff is the wait before the first repeat. It doubles with
+	// every further one.
+	DefaultBackoff = 500 * time.Millisecond
+)
+
+// ErrWatchExpired is returned by Watch when the manager no longer has the
+// events since the requested resource version. The watch must be started
+// again from the current state.
+var ErrWatchExpired = errors.New("watch expired")
+
+// Client is a client of a manager. Its fields must not be changed while
+// requests are made.
+type Client struct {
+	// Addr is the base URL of the manager, e.g. http://localhost:5555.
+	Addr string
+	// Namespace is the namespace tasks are submitted to and looked up in.
+	Namespace string
+	// Token is the secret sent with every request, if the manager
+	// requires authentication.
+	Token string
+	// HTTPClient sends the requests.
+	HTTPClient *http.Client
+	// Retries is how often a request is repeated after it failed on the
+	// way or because the manager was unavailable, waiting Backoff before
+	// the first repeat and twice as long before each further one.
+	Retries int
+	Backoff time.Duration
+}
+
+// New returns a client of the manager at addr, which is a host:port or a
+// URL, working in the default namespace.
+func New(addr string) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{
+		Addr:       strings.TrimSuffix(addr, "/"),
+		Namespace:  namespace.Default,
+		HTTPClient: http.DefaultClient,
+		Retries:    DefaultRetries,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+// SubmitTask submits a task. Repeated submissions with the same key add the
+// task only once; an empty key is replaced by a new one, so that the
+// client's own retries are safe.
+func (c *Client) SubmitTask(ctx context.Context, req v1.TaskRequest, key string) (*v1.Task, error) {
+	if key == "" {
+		key = uuid.NewString()
+	}
+	header := http.Header{"Idempotency-Key": {key}}
+
+	var t v1.Task
+	err := c.doJSON(ctx, http.MethodPost, c.tasksPath(), nil, header, req, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTask returns the task with the given ID.
+func (c *Client) GetTask(ctx context.Context, id uuid.UUID) (*v1.Task, error) {
+	var t v1.Task
+	err := c.doJSON(ctx, http.MethodGet, c.taskPath(id), nil, nil, nil, &t)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTasks returns the page of tasks selected by q. The Next cursor of the
+// result continues the listing.
+func (c *Client) ListTasks(ctx context.Context, q v1.TaskQuery) (*v1.TaskList, error) {
+	var list v1.TaskList
+	err := c.doJSON(ctx, http.MethodGet, c.tasksPath(), q.Values(), nil, nil, &list)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// StopTask stops the task with the given ID.
+func (c *Client) StopTask(ctx context.Context, id uuid.UUID) error {
+	return c.doJSON(ctx, http.MethodDelete, c.taskPath(id), nil, nil, nil, nil)
+}
+
+// ListNodes returns the worker nodes whose labels match sel.
+func (c *Client) ListNodes(ctx context.Context, sel labels.Selector) (*v1.NodeList, error) {
+	var query url.Values
+	if !sel.Empty() {
+		query = url.Values{"labelSelector": {sel.String()}}
+	}
+
+	var list v1.NodeList
+	err := c.doJSON(ctx, http.MethodGet, "/v1/nodes", query, nil, nil, &list)
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetNode returns the named worker node.
+func (c *Client) GetNode(ctx context.Context, name string) (*v1.Node, error) {
+	var n v1.Node
+	err := c.doJSON(ctx, http.MethodGet, "/v1/nodes/"+url.PathEscape(name), nil, nil, nil, &n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// Logs streams the output of the task with the given ID. The caller must
+// close the returned reader; with opts.Follow set it ends when ctx is done
+// or the task's container exits.
+func (c *Client) Logs(ctx context.Context, id uuid.UUID, opts v1.LogOptions) (io.ReadCloser, error) {
+	resp, err := c.do(ctx, http.MethodGet, c.taskPath(id)+"/logs", opts.Values(), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Watcher reads the events of a watch.
+type Watcher struct {
+	body io.ReadCloser
+	d    *watch.Decoder
+}
+
+// Watch follows the changes of resources of the given kind after resource
+// version rv; with rv 0 the manager starts with the current state. Tasks
+// are watched in the client's namespace. It returns ErrWatchExpired when rv
+// is too old.
+func (c *Client) Watch(ctx context.Context, kind watch.Kind, rv uint64) (*Watcher, error) {
+	query := url.Values{"kind": {string(kind)}, "namespace": {c.Namespace}}
+	if rv > 0 {
+		query.Set("resourceVersion", strconv.FormatUint(rv, 10))
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "/watch", query, nil, nil)
+	var e *v1.Error
+	if errors.As(err, &e) && e.Status == http.StatusGone {
+		return nil, ErrWatchExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Watcher{body: resp.Body, d: watch.NewDecoder(resp.Body)}, nil
+}
+
+// Next blocks until the next event arrives. It returns an error once the
+// stream ends.
+func (w *Watcher) Next() (*watch.Event, error) {
+	return w.d.Decode()
+}
+
+// Close stops the watch.
+func (w *Watcher) Close() error {
+	return w.body.Close()
+}
+
+// IsNotFound reports whether err is the manager's answer that a resource
+// does not exist.
+func IsNotFound(err error) bool {
+	var e *v1.Error
+	return errors.As(err, &e) && e.Status == http.StatusNotFound
+}
+
+// IsConflict reports whether err is the manager's answer that a resource
+// already exists.
+func IsConflict(err error) bool {
+	var e *v1.Error
+	return errors.As(err, &e) && e.Status == http.StatusConflict
+}
+
+func (c *Client) tasksPath() string {
+	return fmt.Sprintf("/v1/namespaces/%s/tasks", url.PathEscape(c.Namespace))
+}
+
+func (c *Client) taskPath(id uuid.UUID) string {
+	return fmt.Sprintf("%s/%s", c.tasksPath(), id)
+}
+
+// doJSON sends body as JSON and decodes the response into out, if it is
+// not nil.
+func (c *Client) doJSON(ctx context.Context, method string, path string, query url.Values, header http.Header, body any, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		header = header.Clone()
+		if header == nil {
+			header = http.Header{}
+		}
+		header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.do(ctx, method, path, query, header, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	err = json.NewDecoder(resp.Body).Decode(out)
+	if err != nil {
+		return fmt.Errorf("error decoding response of %s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// do sends a request, repeating it while it fails on the way or the manager
+// is unavailable. A response with an error status is returned as a
+// *v1.Error.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, header http.Header, body []byte) (*http.Response, error) {
+	u := c.Addr + path
+	if len(query) > 0 {
+		u = fmt.Sprintf("%s?%s", u, query.Encode())
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, u, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		if c.Token != "" {
+			auth.SetBearerToken(req, c.Token)
+		}
+
+		resp, err := hc.Do(req)
+		if err == nil && resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+		if err == nil {
+			err = responseError(resp)
+			resp.Body.Close()
+			if !retryable(resp.StatusCode) {
+				return nil, err
+			}
+		}
+		if attempt >= c.Retries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// retryable reports whether a request that failed with status may succeed
+// when repeated.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// responseError turns an error response into a *v1.Error. Responses that
+// are not from the v1 API, such as those of the watch endpoint, are
+// classified by their status.
+func responseError(resp *http.Response) *v1.Error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	var e v1.Error
+	if json.Unmarshal(data, &e) == nil && e.Reason != "" {
+		return &e
+	}
+
+	var legacy struct{ Message string }
+	if json.Unmarshal(data, &legacy) == nil && legacy.Message != "" {
+		return v1.NewError(resp.StatusCode, strings.TrimSpace(legacy.Message))
+	}
+	if msg := strings.TrimSpace(string(data)); msg != "" && !strings.HasPrefix(msg, "{") {
+		return v1.NewError(resp.StatusCode, msg)
+	}
+	return v1.NewError(resp.StatusCode, http.StatusText(resp.StatusCode))
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/watch"
+	"github.com/google/uuid"
+)
+
+func newTestClient(t *testing.T, h http.HandlerFunc) *Client {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	c := New(srv.URL)
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestSubmitTaskRetries(t *testing.T) {
+	var keys []string
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/namespaces/default/tasks" {
+			t.Errorf("got request %s %s", r.Method, r.URL.Path)
+		}
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) < 3 {
+			v1.RespondError(w, v1.NewError(http.StatusServiceUnavailable, "no leader"))
+			return
+		}
+
+		var req v1.TaskRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		v1.Respond(w, http.StatusCreated, v1.Task{ID: uuid.New(), Image: req.Image, State: "Pending"})
+	})
+
+	got, err := c.SubmitTask(context.Background(), v1.TaskRequest{Image: "alpine"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Image != "alpine" || got.State != "Pending" {
+		t.Errorf("got task %+v, want the submitted Pending task", got)
+	}
+	if len(keys) != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] {
+		t.Errorf("got idempotency keys %q, want the same generated key on every attempt", keys)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	var requests int
+	c := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/watch":
+			w.WriteHeader(http.StatusGone)
+			json.NewEncoder(w).Encode(map[string]any{"HTTPStatusCode": http.StatusGone, "Message": "too old"})
+		case "/v1/nodes":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			v1.RespondError(w, v1.NewError(http.StatusNotFound, "no task"))
+		}
+	})
+	ctx := context.Background()
+
+	_, err := c.GetTask(ctx, uuid.New())
+	if !IsNotFound(err) || IsConflict(err) || err.Error() != "no task" {
+		t.Errorf("get unknown task: got %v, want the not found error", err)
+	}
+	if requests != 1 {
+		t.Errorf("got %d requests, want a client error not to be repeated", requests)
+	}
+
+	requests = 0
+	_, err = c.ListNodes(ctx, nil)
+	var e *v1.Error
+	if !errors.As(err, &e) || e.Reason != v1.ReasonUnavailable {
+		t.Errorf("list nodes: got %v, want an Unavailable error", err)
+	}
+	if requests != DefaultRetries+1 {
+		t.Errorf("got %d requests, want %d", requests, DefaultRetries+1)
+	}
+
+	_, err = c.Watch(ctx, watch.KindTask, 7)
+	if !errors.Is(err, ErrWatchExpired) {
+		t.Errorf("watch: got %v, want ErrWatchExpired", err)
+	}
+}
@@ -0,0 +1,105 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type ErrResponse struct {
+	HTTPStatusCode int
+	Message        string
+}
+
+// Handler serves the endpoints members use to talk to each other and the
+// member's status. The manager mounts it at /cluster.
+func (c *Cluster) Handler() http.Handler {
+	r := chi.NewRouter()
+	r.Get("/", c.StatusHandler)
+	r.Post("/vote", c.VoteHandler)
+	r.Post("/heartbeat", c.HeartbeatHandler)
+	r.Get("/log", c.LogHandler)
+	return r
+}
+
+func (c *Cluster) StatusHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, c.Status())
+}
+
+func (c *Cluster) VoteHandler(w http.ResponseWriter, r *http.Request) {
+	var req VoteRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, c.Vote(req))
+}
+
+func (c *Cluster) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	var req HeartbeatRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, c.Heartbeat(req))
+}
+
+// LogHandler serves the writes a follower is missing. The follower passes
+// its position as term and seq, and how long to wait for new writes as
+// wait.
+func (c *Cluster) LogHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var pos Position
+	var err error
+	pos.Term, err = strconv.ParseUint(q.Get("term"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid term")
+		return
+	}
+	pos.Seq, err = strconv.ParseUint(q.Get("seq"), 10, 64)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid seq")
+		return
+	}
+	var wait time.Duration
+	if s := q.Get("wait"); s != "" {
+		wait, err = time.ParseDuration(s)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid wait")
+			return
+		}
+	}
+
+	resp, err := c.Entries(r.Context(), pos, min(wait, pullWait))
+	if errors.Is(err, ErrNotLeader) {
+		respondError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, resp)
+}
+
+func respondJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, ErrResponse{
+		HTTPStatusCode: status,
+		Message:        message,
+	})
+}
@@ -0,0 +1,473 @@
+// Package cluster lets several managers run as one: the members elect a
+// leader, which runs the control loops, and the followers keep replicas of
+// the leader's stores so that one of them can take over when it fails.
+//
+// Election follows Raft: a follower that has not heard from a leader for
+// the election timeout becomes a candidate for the next term and asks its
+// peers for their votes. A member votes once per term, and only for
+// candidates whose replica is at least as recent as its own; the term and
+// vote are kept in a store so that a restarted member does not vote twice.
+// The candidate that gets a majority leads until it can no longer reach a
+// majority.
+//
+// Replication is asynchronous. Followers pull the leader's recent writes
+// and fall back to a full snapshot when they are too far behind or follow
+// a new leader, so writes acknowledged by a leader that fails before its
+// followers pulled them are lost.
+package cluster
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dev6699/cube/store"
+)
+
+const (
+	DefaultHeartbeatInterval = 500 * time.Millisecond
+	DefaultElectionTimeout   = 2 * time.Second
+)
+
+type Role string
+
+const (
+	Follower  Role = "Follower"
+	Candidate Role = "Candidate"
+	Leader    Role = "Leader"
+)
+
+// ballotKey is the key of the member's ballot in its store.
+const ballotKey = "ballot"
+
+// Ballot is the latest term a member knows and the candidate it voted for
+// in it.
+type Ballot struct {
+	Term     uint64
+	VotedFor string
+}
+
+// Status describes a member as seen by itself.
+type Status struct {
+	ID       string
+	Role     Role
+	Term     uint64
+	Leader   string
+	Peers    []string
+	Position Position
+}
+
+// Position is how far a replica has got: the term of the leader whose
+// writes it holds and the sequence number of the last of those writes.
+type Position struct {
+	Term uint64
+	Seq  uint64
+}
+
+// Less reports whether p is older than o.
+func (p Position) Less(o Position) bool {
+	if p.Term != o.Term {
+		return p.Term < o.Term
+	}
+	return p.Seq < o.Seq
+}
+
+// Cluster is the local member of a cluster of managers.
+type Cluster struct {
+	// ID is the address the other members reach this one at, host:port.
+	ID    string
+	Peers []string
+
+	// The leader sends heartbeats every HeartbeatInterval. A follower
+	// that has not heard from it for between one and two
+	// ElectionTimeouts starts an election. A leader that could not reach
+	// a majority for ElectionTimeout less two HeartbeatIntervals steps
+	// down, so that it has stopped leading before another member can be
+	// elected. ElectionTimeout must be more than two HeartbeatIntervals.
+	HeartbeatInterval time.Duration
+	ElectionTimeout   time.Duration
+
+	// Token is sent with the requests to the other members.
+	Token string
+
+	// Ballots keeps the member's Ballot across restarts. New sets it to
+	// an in-memory store; it must not be changed once Run is called.
+	Ballots store.Store[*Ballot]
+
+	mu            sync.Mutex
+	role          Role
+	term          uint64
+	votedFor      string
+	leader        string
+	lastHeartbeat time.Time
+	deadline      time.Time
+	// lease is when the last heartbeats or vote requests that reached a
+	// majority were sent.
+	lease time.Time
+
+	// rmu guards the replication state and serializes writes to the
+	// replicated stores, so that the log orders them like the stores.
+	rmu     sync.Mutex
+	buckets map[string]bucket
+	applied Position
+	log     replicationLog
+
+	client *http.Client
+}
+
+func New(id string, peers []string) *Cluster {
+	return &Cluster{
+		ID:                id,
+		Peers:             peers,
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		ElectionTimeout:   DefaultElectionTimeout,
+		Ballots:           store.NewInMemoryStore[*Ballot](),
+		role:              Follower,
+		buckets:           make(map[string]bucket),
+		log:               newReplicationLog(),
+		client:            &http.Client{},
+	}
+}
+
+// Status returns the member's view of the cluster.
+func (c *Cluster) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Status{
+		ID:       c.ID,
+		Role:     c.role,
+		Term:     c.term,
+		Leader:   c.leader,
+		Peers:    c.Peers,
+		Position: c.position(),
+	}
+}
+
+// Leader returns the address of the current leader, or "" while there is
+// none, and whether it is this member.
+func (c *Cluster) Leader() (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.leader, c.role == Leader
+}
+
+// Run takes part in elections and replication until ctx is done. While
+// this member leads, lead runs with a context that is cancelled when it
+// steps down; Run waits for lead to return before following again.
+func (c *Cluster) Run(ctx context.Context, lead func(ctx context.Context)) {
+	c.mu.Lock()
+	err := c.loadBallot()
+	if err != nil {
+		log.Printf("[cluster] error loading the ballot of %s: %v\n", c.ID, err)
+	}
+	c.resetDeadline()
+	c.mu.Unlock()
+
+	go c.replicate(ctx)
+
+	ticker := time.NewTicker(c.HeartbeatInterval)
+	defer ticker.Stop()
+
+	var l *leadership
+	defer func() { l.stop() }()
+
+	for {
+		c.mu.Lock()
+		role := c.role
+		expired := time.Now().After(c.deadline)
+		c.mu.Unlock()
+
+		switch {
+		case role == Leader:
+			c.sendHeartbeats(ctx)
+		case expired:
+			c.campaign(ctx)
+		}
+
+		_, leading := c.Leader()
+		if leading && l == nil {
+			l = startLeading(ctx, lead)
+		}
+		if !leading && l != nil {
+			l.stop()
+			l = nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// leadership is a running lead function.
+type leadership struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func startLeading(ctx context.Context, lead func(ctx context.Context)) *leadership {
+	ctx, cancel := context.WithCancel(ctx)
+	l := &leadership{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(l.done)
+		lead(ctx)
+	}()
+	return l
+}
+
+// stop cancels the lead function and waits for it to return.
+func (l *leadership) stop() {
+	if l == nil {
+		return
+	}
+	l.cancel()
+	<-l.done
+}
+
+// campaign runs for leader in the next term.
+func (c *Cluster) campaign(ctx context.Context) {
+	c.mu.Lock()
+	c.role = Candidate
+	c.term++
+	c.votedFor = c.ID
+	c.leader = ""
+	c.resetDeadline()
+	err := c.saveBallot()
+	if err != nil {
+		c.mu.Unlock()
+		log.Printf("[cluster] error saving the ballot of %s: %v\n", c.ID, err)
+		return
+	}
+	req := VoteRequest{
+		Term:      c.term,
+		Candidate: c.ID,
+		Position:  c.position(),
+	}
+	c.mu.Unlock()
+	sent := time.Now()
+	log.Printf("[cluster] %s is running for leader in term %d\n", c.ID, req.Term)
+
+	votes := 1 + c.broadcast(ctx, func(ctx context.Context, peer string) bool {
+		var resp VoteResponse
+		err := c.call(ctx, peer, "/cluster/vote", req, &resp)
+		if err != nil {
+			return false
+		}
+		c.observeTerm(resp.Term)
+		return resp.Granted
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.role != Candidate || c.term != req.Term || !c.majority(votes) {
+		return
+	}
+
+	log.Printf("[cluster] %s is leader in term %d\n", c.ID, c.term)
+	c.role = Leader
+	c.leader = c.ID
+	c.lease = sent
+	c.startLog(c.term)
+}
+
+// sendHeartbeats asserts the leadership of this member. It steps down when
+// its lease expired.
+func (c *Cluster) sendHeartbeats(ctx context.Context) {
+	c.mu.Lock()
+	if c.leaseExpired() {
+		c.mu.Unlock()
+		return
+	}
+	req := HeartbeatRequest{
+		Term:   c.term,
+		Leader: c.ID,
+	}
+	c.mu.Unlock()
+	sent := time.Now()
+
+	acks := 1 + c.broadcast(ctx, func(ctx context.Context, peer string) bool {
+		var resp HeartbeatResponse
+		err := c.call(ctx, peer, "/cluster/heartbeat", req, &resp)
+		if err != nil {
+			return false
+		}
+		c.observeTerm(resp.Term)
+		return resp.Success
+	})
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.role != Leader || c.term != req.Term {
+		return
+	}
+	if c.majority(acks) {
+		c.lease = sent
+		return
+	}
+	c.leaseExpired()
+}
+
+// leaseExpired steps down if the leader has not reached a majority for
+// ElectionTimeout less two HeartbeatIntervals, and reports whether it did.
+// The followers that acknowledged the last heartbeats do not vote for
+// another member until ElectionTimeout after they got them, and the leader
+// notices within a heartbeat interval, so it has stepped down by then.
+// c.mu must be held.
+func (c *Cluster) leaseExpired() bool {
+	if time.Since(c.lease) <= c.ElectionTimeout-2*c.HeartbeatInterval {
+		return false
+	}
+	log.Printf("[cluster] %s lost contact with the majority, stepping down\n", c.ID)
+	c.follow(c.term)
+	return true
+}
+
+// broadcast calls f for every peer in parallel and returns how many calls
+// returned true. The calls are given one heartbeat interval to complete.
+func (c *Cluster) broadcast(ctx context.Context, f func(ctx context.Context, peer string) bool) int {
+	ctx, cancel := context.WithTimeout(ctx, c.HeartbeatInterval)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	n := 0
+	for _, peer := range c.Peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if f(ctx, peer) {
+				mu.Lock()
+				n++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return n
+}
+
+// Vote answers a candidate's request for a vote.
+func (c *Cluster) Vote(req VoteRequest) VoteResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A follower that hears from a live leader does not let a member
+	// that merely lost contact with it start a new term.
+	if c.role == Follower && c.leader != "" &&
+		time.Since(c.lastHeartbeat) < c.ElectionTimeout {
+		return VoteResponse{Term: c.term}
+	}
+	if req.Term < c.term {
+		return VoteResponse{Term: c.term}
+	}
+	if req.Term > c.term {
+		c.follow(req.Term)
+	}
+
+	if (c.votedFor != "" && c.votedFor != req.Candidate) || req.Position.Less(c.position()) {
+		return VoteResponse{Term: c.term}
+	}
+	c.votedFor = req.Candidate
+	err := c.saveBallot()
+	if err != nil {
+		log.Printf("[cluster] error saving the ballot of %s: %v\n", c.ID, err)
+		c.votedFor = ""
+		return VoteResponse{Term: c.term}
+	}
+	c.resetDeadline()
+	return VoteResponse{Term: c.term, Granted: true}
+}
+
+// Heartbeat accepts the leadership of the sender if its term is current.
+func (c *Cluster) Heartbeat(req HeartbeatRequest) HeartbeatResponse {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if req.Term < c.term {
+		return HeartbeatResponse{Term: c.term}
+	}
+	if req.Term > c.term || c.role != Follower {
+		c.follow(req.Term)
+	}
+	if c.leader != req.Leader {
+		log.Printf("[cluster] %s follows leader %s in term %d\n", c.ID, req.Leader, req.Term)
+	}
+
+	c.leader = req.Leader
+	c.lastHeartbeat = time.Now()
+	c.resetDeadline()
+	return HeartbeatResponse{Term: c.term, Success: true}
+}
+
+// observeTerm makes this member a follower if a peer reports a newer term.
+func (c *Cluster) observeTerm(term uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if term > c.term {
+		c.follow(term)
+	}
+}
+
+// follow makes this member a follower in term, without a known leader.
+// c.mu must be held.
+func (c *Cluster) follow(term uint64) {
+	if c.role == Leader {
+		c.stopLog()
+	}
+	if term > c.term {
+		c.term = term
+		c.votedFor = ""
+		err := c.saveBallot()
+		if err != nil {
+			log.Printf("[cluster] error saving the ballot of %s: %v\n", c.ID, err)
+		}
+	}
+	c.role = Follower
+	c.leader = ""
+	c.resetDeadline()
+}
+
+// loadBallot restores the term and vote saved by a previous run. c.mu must
+// be held.
+func (c *Cluster) loadBallot() error {
+	b, err := c.Ballots.Get(ballotKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	c.term = b.Term
+	c.votedFor = b.VotedFor
+	return nil
+}
+
+// saveBallot saves the term and vote. It is called before the member acts
+// on them, so that it never votes twice in a term. c.mu must be held.
+func (c *Cluster) saveBallot() error {
+	return c.Ballots.Put(ballotKey, &Ballot{Term: c.term, VotedFor: c.votedFor})
+}
+
+// resetDeadline picks a random point between one and two election
+// timeouts from now, so that members rarely run for leader at the same
+// time. c.mu must be held.
+func (c *Cluster) resetDeadline() {
+	jitter := time.Duration(rand.Int63n(int64(c.ElectionTimeout)))
+	c.deadline = time.Now().Add(c.ElectionTimeout + jitter)
+}
+
+func (c *Cluster) majority(n int) bool {
+	return n > (len(c.Peers)+1)/2
+}
@@ -0,0 +1,277 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/store"
+	"github.com/go-chi/chi/v5"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+type member struct {
+	c      *Cluster
+	srv    *httptest.Server
+	store  store.Store[string]
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	leading bool
+}
+
+func (m *member) isLeading() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.leading
+}
+
+func (m *member) stop() {
+	m.cancel()
+	<-m.done
+	m.srv.CloseClientConnections()
+	m.srv.Close()
+}
+
+// startCluster starts n members that talk to each other over HTTP.
+func startCluster(t *testing.T, n int) []*member {
+	var members []*member
+	var addrs []string
+	for i := 0; i < n; i++ {
+		srv := httptest.NewUnstartedServer(nil)
+		members = append(members, &member{srv: srv})
+		addrs = append(addrs, srv.Listener.Addr().String())
+	}
+
+	for i, m := range members {
+		var peers []string
+		for j, addr := range addrs {
+			if j != i {
+				peers = append(peers, addr)
+			}
+		}
+		m.c = New(addrs[i], peers)
+		m.c.HeartbeatInterval = 50 * time.Millisecond
+		m.c.ElectionTimeout = 250 * time.Millisecond
+		m.store = Replicate(m.c, "values", store.Store[string](store.NewInMemoryStore[string]()))
+		r := chi.NewRouter()
+		r.Mount("/cluster", m.c.Handler())
+		m.srv.Config.Handler = r
+		m.srv.Start()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		m.cancel = cancel
+		m.done = make(chan struct{})
+		go func() {
+			defer close(m.done)
+			m.c.Run(ctx, func(ctx context.Context) {
+				m.mu.Lock()
+				m.leading = true
+				m.mu.Unlock()
+				<-ctx.Done()
+				m.mu.Lock()
+				m.leading = false
+				m.mu.Unlock()
+			})
+		}()
+	}
+
+	t.Cleanup(func() {
+		for _, m := range members {
+			if m.c != nil {
+				m.stop()
+			}
+		}
+	})
+	return members
+}
+
+// waitForLeader waits until exactly one of members leads and all others
+// follow it.
+func waitForLeader(t *testing.T, members []*member) *member {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var leader *member
+		agreed := true
+		for _, m := range members {
+			if m.isLeading() {
+				if leader != nil {
+					agreed = false
+				}
+				leader = m
+			}
+		}
+		if leader != nil && agreed {
+			for _, m := range members {
+				if l, _ := m.c.Leader(); l != leader.c.ID {
+					agreed = false
+				}
+			}
+		}
+		if leader != nil && agreed {
+			return leader
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no leader was elected")
+	return nil
+}
+
+// waitForValues waits until the store of m holds exactly want.
+func waitForValues(t *testing.T, m *member, want []string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := m.store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		slices.Sort(got)
+		if slices.Equal(got, want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: got %d values, want %d", m.c.ID, len(got), len(want))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestElectionAndReplication(t *testing.T) {
+	members := startCluster(t, 3)
+	leader := waitForLeader(t, members)
+
+	var want []string
+	for i := 0; i < 100; i++ {
+		v := fmt.Sprintf("value-%03d", i)
+		if err := leader.store.Put(v, v); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, v)
+	}
+	if err := leader.store.Delete(want[0]); err != nil {
+		t.Fatal(err)
+	}
+	want = want[1:]
+
+	for _, m := range members {
+		waitForValues(t, m, want)
+	}
+
+	// The leader fails; one of the others takes over with all the writes
+	// and replicates to the remaining follower.
+	leader.stop()
+	leader.c = nil
+	var rest []*member
+	for _, m := range members {
+		if m != leader {
+			rest = append(rest, m)
+		}
+	}
+
+	start := time.Now()
+	newLeader := waitForLeader(t, rest)
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("took %v to elect a new leader", d)
+	}
+	waitForValues(t, newLeader, want)
+
+	if err := newLeader.store.Put("after-failover", "after-failover"); err != nil {
+		t.Fatal(err)
+	}
+	want = append(want, "after-failover")
+	slices.Sort(want)
+	for _, m := range rest {
+		waitForValues(t, m, want)
+	}
+}
+
+func TestFollowerIsBehindLeaderLog(t *testing.T) {
+	c := New("a", nil)
+	s := Replicate(c, "values", store.Store[string](store.NewInMemoryStore[string]()))
+	c.startLog(1)
+	for i := 0; i < maxLogEntries+10; i++ {
+		if err := s.Put(fmt.Sprint(i), "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	resp, err := c.Entries(ctx, Position{Term: 1, Seq: 20}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != maxLogEntries-10 || resp.Snapshot != nil {
+		t.Errorf("got %d entries and snapshot %t, want %d entries", len(resp.Entries), resp.Snapshot != nil, maxLogEntries-10)
+	}
+
+	resp, err = c.Entries(ctx, Position{Term: 1, Seq: 5}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Snapshot["values"]) != maxLogEntries+10 {
+		t.Errorf("got snapshot of %d values, want %d", len(resp.Snapshot["values"]), maxLogEntries+10)
+	}
+
+	resp, err = c.Entries(ctx, Position{Term: 1, Seq: maxLogEntries + 10}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Entries) != 0 || resp.Snapshot != nil || resp.Seq != maxLogEntries+10 {
+		t.Errorf("got %d entries and seq %d for an up to date follower", len(resp.Entries), resp.Seq)
+	}
+}
+
+func TestLeaderStepsDownBeforeElection(t *testing.T) {
+	c := New("a", []string{"127.0.0.1:1", "127.0.0.1:2"})
+	c.HeartbeatInterval = 50 * time.Millisecond
+	c.ElectionTimeout = 250 * time.Millisecond
+	c.role = Leader
+	c.leader = c.ID
+	c.term = 1
+	c.startLog(1)
+
+	// The followers may elect another member ElectionTimeout after the
+	// last heartbeats reached them; this leader could not have noticed
+	// before its next round.
+	c.lease = time.Now().Add(-(c.ElectionTimeout - c.HeartbeatInterval))
+	c.sendHeartbeats(context.Background())
+	if _, leading := c.Leader(); leading {
+		t.Error("the leader did not step down before a new one could be elected")
+	}
+}
+
+func TestBallotSurvivesRestart(t *testing.T) {
+	ballots := store.NewInMemoryStore[*Ballot]()
+	c := New("a", []string{"b", "c"})
+	c.Ballots = ballots
+	if resp := c.Vote(VoteRequest{Term: 3, Candidate: "b"}); !resp.Granted {
+		t.Fatalf("got %+v, want the vote", resp)
+	}
+
+	c = New("a", []string{"b", "c"})
+	c.Ballots = ballots
+	c.mu.Lock()
+	err := c.loadBallot()
+	c.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := c.Vote(VoteRequest{Term: 3, Candidate: "c"}); resp.Granted || resp.Term != 3 {
+		t.Errorf("got %+v after a restart, want no second vote in term 3", resp)
+	}
+	if resp := c.Vote(VoteRequest{Term: 3, Candidate: "b"}); !resp.Granted {
+		t.Errorf("got %+v, want the vote repeated", resp)
+	}
+}
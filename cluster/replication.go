@@ -0,0 +1,411 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dev6699/cube/store"
+)
+
+const (
+	// maxLogEntries is how many recent writes the leader keeps for its
+	// followers to catch up from. Followers further behind are sent a
+	// snapshot.
+	maxLogEntries = 10000
+
+	// pullWait is how long the leader holds a follower's request for
+	// writes when there are none yet.
+	pullWait = 5 * time.Second
+)
+
+// ErrNotLeader is returned when a request that only the leader can answer
+// reaches another member.
+var ErrNotLeader = errors.New("not the leader")
+
+// Entry is a write to a replicated store.
+type Entry struct {
+	Seq    uint64
+	Bucket string
+	Key    string
+	// Value is the JSON encoded value, or null if the key was deleted.
+	Value json.RawMessage
+}
+
+// LogResponse carries the writes a follower is missing: either the entries
+// after its position or, when the leader no longer has them, a snapshot of
+// every replicated store. Applying it brings the follower to Term and Seq.
+type LogResponse struct {
+	Term     uint64
+	Seq      uint64
+	Entries  []Entry
+	Snapshot map[string]map[string]json.RawMessage `json:",omitempty"`
+}
+
+type replicationLog struct {
+	term    uint64
+	seq     uint64
+	entries []Entry
+	changed chan struct{}
+}
+
+func newReplicationLog() replicationLog {
+	return replicationLog{changed: make(chan struct{})}
+}
+
+// bucket is a store whose writes are replicated.
+type bucket interface {
+	apply(key string, value json.RawMessage) error
+	values() (map[string]any, error)
+	load(values map[string]json.RawMessage) error
+}
+
+// Replicate registers s under name and returns a store that records the
+// writes made on the leader for its followers. On followers the leader's
+// writes are applied to s directly.
+func Replicate[T any](c *Cluster, name string, s store.Store[T]) store.Store[T] {
+	r := &replicatedStore[T]{
+		Store: s,
+		c:     c,
+		name:  name,
+	}
+
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.buckets[name] = r
+	return r
+}
+
+type replicatedStore[T any] struct {
+	store.Store[T]
+	c    *Cluster
+	name string
+}
+
+func (s *replicatedStore[T]) Put(key string, value T) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.c.rmu.Lock()
+	defer s.c.rmu.Unlock()
+
+	err = s.Store.Put(key, value)
+	if err != nil {
+		return err
+	}
+	s.c.record(Entry{Bucket: s.name, Key: key, Value: buf})
+	return nil
+}
+
+func (s *replicatedStore[T]) Delete(key string) error {
+	s.c.rmu.Lock()
+	defer s.c.rmu.Unlock()
+
+	err := s.Store.Delete(key)
+	if err != nil {
+		return err
+	}
+	s.c.record(Entry{Bucket: s.name, Key: key})
+	return nil
+}
+
+func (s *replicatedStore[T]) apply(key string, value json.RawMessage) error {
+	if value == nil || string(value) == "null" {
+		err := s.Store.Delete(key)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var v T
+	err := json.Unmarshal(value, &v)
+	if err != nil {
+		return err
+	}
+	return s.Store.Put(key, v)
+}
+
+// values returns a copy of every value in the store.
+func (s *replicatedStore[T]) values() (map[string]any, error) {
+	keys, err := s.Store.Keys()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]any)
+	for _, k := range keys {
+		v, err := s.Store.Get(k)
+		if err != nil {
+			return nil, err
+		}
+		values[k] = v
+	}
+	return values, nil
+}
+
+func (s *replicatedStore[T]) load(values map[string]json.RawMessage) error {
+	keys, err := s.Store.Keys()
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if _, ok := values[k]; ok {
+			continue
+		}
+		err := s.Store.Delete(k)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+
+	for k, v := range values {
+		err := s.apply(k, v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record appends e to the log if this member leads. c.rmu must be held.
+func (c *Cluster) record(e Entry) {
+	if c.log.term == 0 {
+		return
+	}
+
+	c.log.seq++
+	e.Seq = c.log.seq
+	c.log.entries = append(c.log.entries, e)
+	if len(c.log.entries) > maxLogEntries {
+		c.log.entries = slices.Clone(c.log.entries[len(c.log.entries)-maxLogEntries:])
+	}
+	c.notifyLog()
+}
+
+// notifyLog wakes the followers waiting for writes. c.rmu must be held.
+func (c *Cluster) notifyLog() {
+	close(c.log.changed)
+	c.log.changed = make(chan struct{})
+}
+
+// startLog starts recording writes for the followers as leader of term.
+func (c *Cluster) startLog(term uint64) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	c.log.term = term
+	c.log.seq = 0
+	c.log.entries = nil
+	c.notifyLog()
+}
+
+// stopLog stops recording writes. The local stores hold everything that
+// was recorded, so the replica is as recent as the log.
+func (c *Cluster) stopLog() {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	c.applied = Position{Term: c.log.term, Seq: c.log.seq}
+	c.log.term = 0
+	c.log.entries = nil
+	c.notifyLog()
+}
+
+// position returns how recent the local replica is.
+func (c *Cluster) position() Position {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if c.log.term != 0 {
+		return Position{Term: c.log.term, Seq: c.log.seq}
+	}
+	return c.applied
+}
+
+// Entries returns the writes a follower at pos is missing. If there are
+// none it waits up to wait for new ones.
+func (c *Cluster) Entries(ctx context.Context, pos Position, wait time.Duration) (LogResponse, error) {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		c.rmu.Lock()
+		l := c.log
+		if l.term == 0 {
+			c.rmu.Unlock()
+			return LogResponse{}, ErrNotLeader
+		}
+
+		missing := pos.Term != l.term || pos.Seq > l.seq ||
+			(pos.Seq < l.seq && (len(l.entries) == 0 || pos.Seq+1 < l.entries[0].Seq))
+		if missing {
+			snap, err := c.snapshot()
+			c.rmu.Unlock()
+			if err != nil {
+				return LogResponse{}, err
+			}
+			return snap.encode()
+		}
+		if pos.Seq < l.seq {
+			i := pos.Seq + 1 - l.entries[0].Seq
+			resp := LogResponse{
+				Term:    l.term,
+				Seq:     l.seq,
+				Entries: slices.Clone(l.entries[i:]),
+			}
+			c.rmu.Unlock()
+			return resp, nil
+		}
+		c.rmu.Unlock()
+
+		select {
+		case <-l.changed:
+		case <-timeout.C:
+			return LogResponse{Term: l.term, Seq: l.seq}, nil
+		case <-ctx.Done():
+			return LogResponse{}, ctx.Err()
+		}
+	}
+}
+
+// snapshot is a copy of every replicated store at a position of the log.
+type snapshot struct {
+	term    uint64
+	seq     uint64
+	buckets map[string]map[string]any
+}
+
+// snapshot copies every replicated store. c.rmu must be held; the copy is
+// encoded after it is released, so that writes only wait for the copy.
+func (c *Cluster) snapshot() (snapshot, error) {
+	snap := snapshot{
+		term:    c.log.term,
+		seq:     c.log.seq,
+		buckets: make(map[string]map[string]any),
+	}
+	for name, b := range c.buckets {
+		values, err := b.values()
+		if err != nil {
+			return snapshot{}, fmt.Errorf("bucket %s: %w", name, err)
+		}
+		snap.buckets[name] = values
+	}
+	return snap, nil
+}
+
+// encode turns the snapshot into the response to a follower.
+func (s snapshot) encode() (LogResponse, error) {
+	resp := LogResponse{
+		Term:     s.term,
+		Seq:      s.seq,
+		Snapshot: make(map[string]map[string]json.RawMessage),
+	}
+	for name, values := range s.buckets {
+		encoded := make(map[string]json.RawMessage, len(values))
+		for k, v := range values {
+			buf, err := json.Marshal(v)
+			if err != nil {
+				return LogResponse{}, fmt.Errorf("bucket %s: %w", name, err)
+			}
+			encoded[k] = buf
+		}
+		resp.Snapshot[name] = encoded
+	}
+	return resp, nil
+}
+
+// replicate keeps the local stores in sync with the leader's while this
+// member follows.
+func (c *Cluster) replicate(ctx context.Context) {
+	for ctx.Err() == nil {
+		leader, self := c.Leader()
+		var err error
+		if leader != "" && !self {
+			err = c.pull(ctx, leader)
+			if err == nil {
+				continue
+			}
+			log.Printf("[cluster] error replicating from %s: %v\n", leader, err)
+		}
+
+		select {
+		case <-time.After(c.HeartbeatInterval):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// pull fetches and applies the writes this member is missing from leader.
+func (c *Cluster) pull(ctx context.Context, leader string) error {
+	c.rmu.Lock()
+	pos := c.applied
+	c.rmu.Unlock()
+
+	url := fmt.Sprintf("http://%s/cluster/log?term=%d&seq=%d&wait=%s", leader, pos.Term, pos.Seq, pullWait)
+	ctx, cancel := context.WithTimeout(ctx, pullWait+c.ElectionTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("invalid status code: %d", resp.StatusCode)
+	}
+
+	var lr LogResponse
+	err = json.NewDecoder(resp.Body).Decode(&lr)
+	if err != nil {
+		return err
+	}
+	return c.apply(lr)
+}
+
+// apply writes the leader's changes to the local stores.
+func (c *Cluster) apply(lr LogResponse) error {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	// Became leader while the response was on its way.
+	if c.log.term != 0 {
+		return nil
+	}
+
+	if lr.Snapshot != nil {
+		for name, b := range c.buckets {
+			err := b.load(lr.Snapshot[name])
+			if err != nil {
+				return fmt.Errorf("bucket %s: %w", name, err)
+			}
+		}
+		log.Printf("[cluster] %s loaded a snapshot at term %d, seq %d\n", c.ID, lr.Term, lr.Seq)
+		c.applied = Position{Term: lr.Term, Seq: lr.Seq}
+		return nil
+	}
+
+	for _, e := range lr.Entries {
+		b, ok := c.buckets[e.Bucket]
+		if !ok {
+			return fmt.Errorf("unknown bucket %s", e.Bucket)
+		}
+		err := b.apply(e.Key, e.Value)
+		if err != nil {
+			return fmt.Errorf("bucket %s: %w", e.Bucket, err)
+		}
+		c.applied = Position{Term: lr.Term, Seq: e.Seq}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/dev6699/cube/auth"
+)
+
+// VoteRequest asks a member to vote for Candidate as leader of Term.
+type VoteRequest struct {
+	Term      uint64
+	Candidate string
+	Position  Position
+}
+
+type VoteResponse struct {
+	Term    uint64
+	Granted bool
+}
+
+// HeartbeatRequest tells a member that Leader leads Term.
+type HeartbeatRequest struct {
+	Term   uint64
+	Leader string
+}
+
+type HeartbeatResponse struct {
+	Term    uint64
+	Success bool
+}
+
+// call posts req to path on peer and decodes the response into resp.
+func (c *Cluster) call(ctx context.Context, peer string, path string, req any, resp any) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("http://%s%s", peer, path)
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+
+	res, err := c.do(r)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("invalid status code: %d", res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(resp)
+}
+
+// do sends a request to another member with the cluster's token.
+func (c *Cluster) do(r *http.Request) (*http.Response, error) {
+	if c.Token != "" {
+		auth.SetBearerToken(r, c.Token)
+	}
+	return c.client.Do(r)
+}
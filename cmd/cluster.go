@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dev6699/cube/cluster"
+	"github.com/spf13/cobra"
+)
+
+// clusterCmd represents the cluster command
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Cluster command to show the state of a manager replica.",
+	Long: `cube cluster command.
+
+Shows how the manager given with -m sees its cluster: its role, the current
+term and leader, and how far its replica of the stores has got. Only managers
+started with --peers are part of a cluster.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := cmd.Flags().GetString("manager")
+		if err != nil {
+			return err
+		}
+
+		var s cluster.Status
+		err = sendRequest(http.MethodGet, fmt.Sprintf("http://%s/cluster", manager), nil, http.StatusOK, &s)
+		if err != nil {
+			return err
+		}
+
+		leader := s.Leader
+		if leader == "" {
+			leader = "-"
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "ID\tROLE\tTERM\tLEADER\tPOSITION\tPEERS\t")
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d/%d\t%s\t\n", s.ID, s.Role, s.Term, leader, s.Position.Term, s.Position.Seq, strings.Join(s.Peers, ","))
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+	clusterCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+// tokenEnv and configEnv are the environment variables that hold the token
+// the CLI sends and the path of its config file. clusterTokenEnv holds the
+// token managers and workers share.
+const (
+	tokenEnv        = "CUBE_TOKEN"
+	configEnv       = "CUBE_CONFIG"
+	clusterTokenEnv = "CUBE_CLUSTER_TOKEN"
+)
+
+// token is the secret sent to the manager with every request. It is looked
+// up before each command runs.
+var token string
+
+// config is the CLI's config file.
+type config struct {
+	Token string `json:"token"`
+}
+
+// configPath returns the path of the config file, $CUBE_CONFIG or
+// ~/.cube/config.json.
+func configPath() (string, error) {
+	if path := os.Getenv(configEnv); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".cube", "config.json"), nil
+}
+
+// loadToken returns the token given with --token, in $CUBE_TOKEN or in the
+// config file, in that order.
+func loadToken(cmd *cobra.Command) (string, error) {
+	t, err := cmd.Flags().GetString("token")
+	if err != nil || t != "" {
+		return t, err
+	}
+	if t := os.Getenv(tokenEnv); t != "" {
+		return t, nil
+	}
+
+	path, err := configPath()
+	if err != nil {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var c config
+	err = json.Unmarshal(data, &c)
+	if err != nil {
+		return "", fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return c.Token, nil
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/cronjob"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+// cronjobCmd represents the cronjob command
+var cronjobCmd = &cobra.Command{
+	Use:   "cronjob",
+	Short: "CronJob command to manage recurring tasks.",
+	Long: `cube cronjob command.
+
+A cron job creates a fresh task from its template every time its cron schedule fires,
+subject to its concurrency policy, and keeps the latest successful and failed runs.`,
+}
+
+var cronjobCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new cron job.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendCronJobSpec(cmd, http.MethodPost, http.StatusCreated)
+	},
+}
+
+var cronjobUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update an existing cron job.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendCronJobSpec(cmd, http.MethodPut, http.StatusOK)
+	},
+}
+
+var cronjobLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List cron jobs.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var cronJobs []*cronjob.CronJob
+		url := fmt.Sprintf("http://%s/cronjobs", manager)
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &cronJobs)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tSCHEDULE\tTIMEZONE\tSUSPEND\tACTIVE\tLAST SCHEDULE\tNEXT SCHEDULE\t")
+		for _, c := range cronJobs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%d\t%s\t%s\t\n", c.Name, c.Schedule, c.TimeZone, c.Suspend,
+				len(c.Status.Active), humanTime(c.Status.LastScheduleTime), humanTime(c.Status.NextScheduleTime))
+		}
+
+		return w.Flush()
+	},
+}
+
+var cronjobRunsCmd = &cobra.Command{
+	Use:   "runs <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "List the runs of a cron job.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var runs []*task.Task
+		url := fmt.Sprintf("http://%s/cronjobs/%s/runs", manager, args[0])
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &runs)
+		if err != nil {
+			return err
+		}
+
+		return printTasks(os.Stdout, v1.FromTasks(runs))
+	},
+}
+
+var cronjobRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Remove a cron job and stop its active runs.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/cronjobs/%s", manager, args[0])
+		err = sendRequest(http.MethodDelete, url, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Cron job %s has been removed.", args[0])
+
+		return nil
+	},
+}
+
+func sendCronJobSpec(cmd *cobra.Command, method string, status int) error {
+	manager, err := namespacedManager(cmd)
+	if err != nil {
+		return err
+	}
+	filename, err := cmd.Flags().GetString("filename")
+	if err != nil {
+		return err
+	}
+
+	if !fileExists(filename) {
+		return fmt.Errorf("file %s does not exist", filename)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	var spec cronjob.CronJob
+	err = json.Unmarshal(data, &spec)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("http://%s/cronjobs", manager)
+	if method == http.MethodPut {
+		url = fmt.Sprintf("%s/%s", url, spec.Name)
+	}
+
+	var c cronjob.CronJob
+	err = sendRequest(method, url, spec, status, &c)
+	if err != nil {
+		return err
+	}
+	log.Printf("Cron job %s scheduled \"%s\" (%s), next run at %v.", c.Name, c.Schedule, c.TimeZone, c.Status.NextScheduleTime)
+
+	return nil
+}
+
+func humanTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	now := time.Now().UTC()
+	if t.After(now) {
+		return fmt.Sprintf("in %s", units.HumanDuration(t.Sub(now)))
+	}
+	return fmt.Sprintf("%s ago", units.HumanDuration(now.Sub(t)))
+}
+
+func init() {
+	rootCmd.AddCommand(cronjobCmd)
+	cronjobCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	cronjobCmd.PersistentFlags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	cronjobCmd.AddCommand(cronjobCreateCmd)
+	cronjobCreateCmd.Flags().StringP("filename", "f", "cronjob.json", "Cron job specification file")
+	cronjobCmd.AddCommand(cronjobUpdateCmd)
+	cronjobUpdateCmd.Flags().StringP("filename", "f", "cronjob.json", "Cron job specification file")
+	cronjobCmd.AddCommand(cronjobLsCmd)
+	cronjobCmd.AddCommand(cronjobRunsCmd)
+	cronjobCmd.AddCommand(cronjobRmCmd)
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/spf13/cobra"
+)
+
+// deadLetterCmd represents the deadletter command
+var deadLetterCmd = &cobra.Command{
+	Use:   "deadletter",
+	Short: "Deadletter command to manage tasks that could not be delivered.",
+	Long: `cube deadletter command.
+
+The manager offers a task to other workers when a worker rejects it or does
+not start it within the lease it accepted it with. After --max-dispatch-attempts
+failures the task is marked Failed and kept as a dead letter, until it is
+retried or removed.`,
+}
+
+var deadLetterLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List dead letters.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var letters []*task.DeadLetter
+		url := fmt.Sprintf("http://%s/deadletters", manager)
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &letters)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "TASK\tNAME\tATTEMPTS\tWORKERS\tAGE\tERROR\t")
+		for _, dl := range letters {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\t%s\t\n", dl.Task.ID, dl.Task.Name, dl.Attempts,
+				strings.Join(dl.FailedWorkers, ","), humanTime(dl.Timestamp), dl.Error)
+		}
+
+		return w.Flush()
+	},
+}
+
+var deadLetterRetryCmd = &cobra.Command{
+	Use:   "retry <taskID>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Schedule a dead letter task again.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/deadletters/%s/retry", manager, args[0])
+		err = sendRequest(http.MethodPost, url, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Task %s has been scheduled again.", args[0])
+
+		return nil
+	},
+}
+
+var deadLetterRmCmd = &cobra.Command{
+	Use:   "rm <taskID>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Remove a dead letter. The task stays Failed.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/deadletters/%s", manager, args[0])
+		err = sendRequest(http.MethodDelete, url, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Dead letter of task %s has been removed.", args[0])
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(deadLetterCmd)
+	deadLetterCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	deadLetterCmd.PersistentFlags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	deadLetterCmd.AddCommand(deadLetterLsCmd)
+	deadLetterCmd.AddCommand(deadLetterRetryCmd)
+	deadLetterCmd.AddCommand(deadLetterRmCmd)
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/dev6699/cube/auth"
+	"github.com/dev6699/cube/client"
+	"github.com/dev6699/cube/manager"
+	"github.com/spf13/cobra"
+)
+
+// sendRequest sends body as JSON and decodes the response into out when the
+// manager answers with the expected status.
+func sendRequest(method string, url string, body any, status int, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		auth.SetBearerToken(req, token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != status {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// namespacedManager returns the manager address followed by the path of
+// the namespace selected with --namespace, so that resource URLs can be
+// built from it as from a plain address.
+func namespacedManager(cmd *cobra.Command) (string, error) {
+	manager, err := cmd.Flags().GetString("manager")
+	if err != nil {
+		return "", err
+	}
+	ns, err := cmd.Flags().GetString("namespace")
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s/namespaces/%s", manager, url.PathEscape(ns)), nil
+}
+
+// newClient returns a client of the manager given with --manager, working
+// in the namespace given with --namespace if the command has that flag.
+func newClient(cmd *cobra.Command) (*client.Client, error) {
+	manager, err := cmd.Flags().GetString("manager")
+	if err != nil {
+		return nil, err
+	}
+
+	c := client.New(manager)
+	c.Token = token
+	if cmd.Flags().Lookup("namespace") != nil {
+		c.Namespace, err = cmd.Flags().GetString("namespace")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// responseError turns an error response from the manager into an error.
+func responseError(resp *http.Response) error {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var e manager.ErrResponse
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		return fmt.Errorf("manager returned %d: %s", resp.StatusCode, e.Message)
+	}
+	return fmt.Errorf("manager returned %d", resp.StatusCode)
+}
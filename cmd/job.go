@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dev6699/cube/job"
+	"github.com/dev6699/cube/namespace"
+	"github.com/spf13/cobra"
+)
+
+// jobCmd represents the job command
+var jobCmd = &cobra.Command{
+	Use:   "job",
+	Short: "Job command to run parallel batch jobs.",
+	Long: `cube job command.
+
+A job runs its task template until the requested number of completions have
+succeeded, with at most parallelism tasks at a time. Every task receives its
+completion index in the CUBE_JOB_INDEX environment variable. Failed indexes are
+retried until the backoff limit is exceeded. A job still running after its
+active deadline fails and its tasks are stopped.`,
+}
+
+var jobCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new job.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+		filename, err := cmd.Flags().GetString("filename")
+		if err != nil {
+			return err
+		}
+
+		if !fileExists(filename) {
+			return fmt.Errorf("file %s does not exist", filename)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		var spec job.Job
+		err = json.Unmarshal(data, &spec)
+		if err != nil {
+			return err
+		}
+
+		var j job.Job
+		url := fmt.Sprintf("http://%s/jobs", manager)
+		err = sendRequest(http.MethodPost, url, spec, http.StatusCreated, &j)
+		if err != nil {
+			return err
+		}
+		log.Printf("Job %s created with %d completions and parallelism %d.", j.Name, j.Completions, j.Parallelism)
+
+		return nil
+	},
+}
+
+var jobLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List jobs.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var jobs []*job.Job
+		url := fmt.Sprintf("http://%s/jobs", manager)
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &jobs)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tSTATE\tCOMPLETIONS\tACTIVE\tFAILED\tAGE\t")
+		for _, j := range jobs {
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%d\t%s\t\n", j.Name, j.Status.State, j.Status.Succeeded, j.Completions,
+				j.Status.Active, j.Status.Failed, humanTime(j.CreatedAt))
+		}
+
+		return w.Flush()
+	},
+}
+
+var jobStatusCmd = &cobra.Command{
+	Use:   "status <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Show the progress of every index of a job.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var j job.Job
+		url := fmt.Sprintf("http://%s/jobs/%s", manager, args[0])
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &j)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Job:         %s\n", j.Name)
+		fmt.Printf("State:       %s\n", j.Status.State)
+		fmt.Printf("Completions: %d/%d (%d active, %d failed, backoff limit %d)\n",
+			j.Status.Succeeded, j.Completions, j.Status.Active, j.Status.Failed, *j.BackoffLimit)
+		if j.Status.Message != "" {
+			fmt.Printf("Message:     %s\n", j.Status.Message)
+		}
+		fmt.Println()
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "INDEX\tSUCCEEDED\tFAILURES\tTASK\t")
+		for _, idx := range j.Status.Indexes {
+			taskID := "-"
+			if idx.TaskID.ID() != 0 {
+				taskID = idx.TaskID.String()
+			}
+			fmt.Fprintf(w, "%d\t%t\t%d\t%s\t\n", idx.Index, idx.Succeeded, idx.Failures, taskID)
+		}
+
+		return w.Flush()
+	},
+}
+
+var jobRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Remove a job and stop its active tasks.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/jobs/%s", manager, args[0])
+		err = sendRequest(http.MethodDelete, url, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Job %s has been removed.", args[0])
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(jobCmd)
+	jobCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	jobCmd.PersistentFlags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	jobCmd.AddCommand(jobCreateCmd)
+	jobCreateCmd.Flags().StringP("filename", "f", "job.json", "Job specification file")
+	jobCmd.AddCommand(jobLsCmd)
+	jobCmd.AddCommand(jobStatusCmd)
+	jobCmd.AddCommand(jobRmCmd)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/namespace"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+// logsCmd represents the logs command
+var logsCmd = &cobra.Command{
+	Use:   "logs <taskID>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Print the output of a task.",
+	Long: `cube logs command.
+
+The logs command prints what a task's container wrote to stdout and stderr. The
+manager fetches it from the worker the task runs on. With -f the command keeps
+printing new output until the container exits or the command is interrupted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		id, err := uuid.Parse(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid task ID %s: %v", args[0], err)
+		}
+		follow, err := cmd.Flags().GetBool("follow")
+		if err != nil {
+			return err
+		}
+		tail, err := cmd.Flags().GetInt("tail")
+		if err != nil {
+			return err
+		}
+
+		logs, err := c.Logs(cmd.Context(), id, v1.LogOptions{Follow: follow, Tail: tail})
+		if err != nil {
+			return err
+		}
+		defer logs.Close()
+
+		_, err = io.Copy(os.Stdout, logs)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd)
+	logsCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	logsCmd.Flags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	logsCmd.Flags().BoolP("follow", "f", false, "Keep printing new output")
+	logsCmd.Flags().Int("tail", 0, "Number of lines to print from the end of the output (0 for all)")
+}
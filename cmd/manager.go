@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"os"
 
+	"github.com/dev6699/cube/cluster"
 	"github.com/dev6699/cube/manager"
+	"github.com/dev6699/cube/store"
 	"github.com/spf13/cobra"
 )
 
@@ -17,7 +22,66 @@ The manager controls the orchestration system and is responsible for:
 - Accepting tasks from users
 - Scheduling tasks onto worker nodes
 - Rescheduling tasks in the event of a node failure
-- Periodically polling workers to get task updates`,
+- Periodically polling workers to get task updates
+
+Workers are either listed with --workers or join at runtime by starting them
+with --manager pointing at this manager.
+
+A node that fails a poll becomes NotReady. When it has not answered for
+--node-grace-period its tasks are rescheduled onto Ready nodes, and the old
+copies are stopped if the node comes back.
+
+Submitted tasks are dispatched as soon as they arrive. At most
+--dispatch-concurrency requests to workers are in flight at once, and each
+worker is sent at most --dispatch-rate tasks per second, in bursts of up to
+--dispatch-burst.
+
+A task is only placed on a worker once the worker accepted it, promising to
+start it within a lease. Requests that time out are repeated; the worker
+recognizes a task it already accepted. A task the worker rejects, or does not
+start within the lease, is offered to another worker. After
+--max-dispatch-attempts failures it is marked Failed and listed by
+"cube deadletter ls".
+
+Every --reconcile-interval the manager compares the tasks the workers report
+with what it wants them to run. A task its worker does not know, e.g. because
+the request starting it was lost, is scheduled again; a task that should have
+been stopped, or that the manager placed elsewhere, is stopped. Tasks the
+manager does not know at all are only reported, since a manager that lost its
+memory stores or a replica that just took over may not know every task it
+placed; --stop-unknown-tasks stops them as well. Differences are only corrected
+once they have lasted for a whole interval, so that requests still on their way
+are not repeated.
+
+Tasks and events submitted without an ID are given one. A task submitted with
+the ID of a task the manager knows is rejected. Submissions carrying an
+Idempotency-Key header return the task the key created first when repeated,
+for --idempotency-key-ttl after the first one.
+
+With --dbType bolt, tasks that were accepted but not dispatched yet are kept
+in pending.db and dispatched once the manager is started again. The worker
+each task was placed on is stored with the task; on startup the manager
+checks with those workers before it schedules anything new.
+
+With --peers the manager is one replica of a cluster. The replicas elect a
+leader, which runs the control loops; the others copy its stores and proxy
+every request they get to it. When the leader fails, another replica takes
+over within a few seconds. With --dbType bolt each replica keeps its term and
+vote in cluster.db, so that it does not vote twice after a restart. List the
+other replicas as they reach each other, and set --advertise to the address
+the others reach this one at. To try it on one machine, run each replica on
+its own port and, with --dbType bolt, in its own working directory:
+
+  cube manager -p 5555 --peers localhost:5565,localhost:5575
+  cube manager -p 5565 --peers localhost:5555,localhost:5575
+  cube manager -p 5575 --peers localhost:5555,localhost:5565
+  cube worker -m localhost:5555,localhost:5565,localhost:5575
+
+With --auth every request must carry the secret of a token as a bearer token;
+see "cube token". The manager, its replicas and its workers authenticate each
+other with the --cluster-token they share, which defaults to the
+CUBE_CLUSTER_TOKEN environment variable. Workers started with it reject
+requests that do not carry it.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		host, err := cmd.Flags().GetString("host")
 		if err != nil {
@@ -40,28 +104,154 @@ The manager controls the orchestration system and is responsible for:
 			return err
 		}
 
+		gracePeriod, err := cmd.Flags().GetDuration("node-grace-period")
+		if err != nil {
+			return err
+		}
+		concurrency, err := cmd.Flags().GetInt("dispatch-concurrency")
+		if err != nil {
+			return err
+		}
+		rate, err := cmd.Flags().GetFloat64("dispatch-rate")
+		if err != nil {
+			return err
+		}
+		burst, err := cmd.Flags().GetInt("dispatch-burst")
+		if err != nil {
+			return err
+		}
+		maxAttempts, err := cmd.Flags().GetInt("max-dispatch-attempts")
+		if err != nil {
+			return err
+		}
+		reconcileInterval, err := cmd.Flags().GetDuration("reconcile-interval")
+		if err != nil {
+			return err
+		}
+		if reconcileInterval <= 0 {
+			return fmt.Errorf("--reconcile-interval must be positive")
+		}
+		stopUnknown, err := cmd.Flags().GetBool("stop-unknown-tasks")
+		if err != nil {
+			return err
+		}
+		keyTTL, err := cmd.Flags().GetDuration("idempotency-key-ttl")
+		if err != nil {
+			return err
+		}
+		if keyTTL <= 0 {
+			return fmt.Errorf("--idempotency-key-ttl must be positive")
+		}
+		peers, err := cmd.Flags().GetStringSlice("peers")
+		if err != nil {
+			return err
+		}
+		advertise, err := cmd.Flags().GetString("advertise")
+		if err != nil {
+			return err
+		}
+		requireAuth, err := cmd.Flags().GetBool("auth")
+		if err != nil {
+			return err
+		}
+		clusterToken, err := cmd.Flags().GetString("cluster-token")
+		if err != nil {
+			return err
+		}
+		if clusterToken == "" {
+			clusterToken = os.Getenv(clusterTokenEnv)
+		}
+		if requireAuth && clusterToken == "" {
+			return fmt.Errorf("--auth requires a --cluster-token for the workers and replicas")
+		}
+
 		ctx := cmd.Context()
 		m, err := manager.New(workers, scheduler, dbType)
 		if err != nil {
 			return err
 		}
+		m.NodeGracePeriod = gracePeriod
+		m.DispatchConcurrency = concurrency
+		m.DispatchRate = rate
+		m.DispatchBurst = burst
+		m.MaxDispatchAttempts = maxAttempts
+		m.ReconcileInterval = reconcileInterval
+		m.StopUnknownTasks = stopUnknown
+		m.IdempotencyKeyTTL = keyTTL
+		m.UseClusterToken(clusterToken)
 
 		api := manager.NewApi(host, port, m)
-		go m.ProcessTasks(ctx)
-		go m.UpdateTasks(ctx)
-		go m.DoHealthChecks(ctx)
-		go m.UpdateNodeStats(ctx)
+		api.Auth = requireAuth
+		api.ClusterToken = clusterToken
+		if len(peers) == 0 {
+			if requireAuth {
+				bootstrapToken(m)
+			}
+			go m.Run(ctx)
+		} else {
+			if advertise == "" {
+				advertise, err = advertiseAddress(host, port)
+				if err != nil {
+					return err
+				}
+			}
+			c := cluster.New(advertise, peers)
+			c.Token = clusterToken
+			if dbType == "bolt" {
+				c.Ballots, err = store.NewBoltStore[*cluster.Ballot]("cluster.db", 0600, "ballots")
+				if err != nil {
+					return err
+				}
+			}
+			m.Replicate(c)
+			api.Cluster = c
+			go c.Run(ctx, func(ctx context.Context) {
+				err := m.Restore()
+				if err != nil {
+					log.Printf("[manager] error restoring state: %v\n", err)
+				}
+				if requireAuth {
+					bootstrapToken(m)
+				}
+				m.Run(ctx)
+			})
+		}
 
 		log.Printf("[manager] listening on http://%s:%d", host, port)
 		return api.Start()
 	},
 }
 
+// bootstrapToken creates the first token of a manager that requires
+// authentication and logs its secret.
+func bootstrapToken(m *manager.Manager) {
+	secret, err := m.BootstrapToken()
+	if err != nil {
+		log.Printf("[manager] error creating the first token: %v\n", err)
+		return
+	}
+	if secret != "" {
+		log.Printf("[manager] created token admin, pass it to the CLI with --token or %s: %s\n", tokenEnv, secret)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(managerCmd)
 	managerCmd.Flags().StringP("host", "H", "0.0.0.0", "Hostname or IP address")
 	managerCmd.Flags().IntP("port", "p", 5555, "Port on which to listen")
-	managerCmd.Flags().StringSliceP("workers", "w", []string{"localhost:5556"}, "List of workers on which the manager will schedule tasks.")
+	managerCmd.Flags().StringSliceP("workers", "w", []string{"localhost:5556"}, "List of workers on which the manager will schedule tasks, in addition to those that register themselves.")
 	managerCmd.Flags().StringP("scheduler", "s", "roundrobin", "Nameof scheduler to use. (\"roundrobin\" or \"epvm\")")
+	managerCmd.Flags().Duration("node-grace-period", manager.DefaultNodeGracePeriod, "How long a node may be unreachable before its tasks are rescheduled")
+	managerCmd.Flags().Int("dispatch-concurrency", manager.DefaultDispatchConcurrency, "Maximum number of requests sent to workers at the same time")
+	managerCmd.Flags().Float64("dispatch-rate", manager.DefaultDispatchRate, "Maximum number of tasks per second sent to a single worker (0 for no limit)")
+	managerCmd.Flags().Int("dispatch-burst", manager.DefaultDispatchBurst, "Number of tasks a worker may be sent at once before --dispatch-rate applies")
+	managerCmd.Flags().Int("max-dispatch-attempts", manager.DefaultMaxDispatchAttempts, "Number of workers a task is offered to before it becomes a dead letter")
+	managerCmd.Flags().Duration("reconcile-interval", manager.DefaultReconcileInterval, "How often the tasks workers run are compared with the desired state")
+	managerCmd.Flags().Bool("stop-unknown-tasks", false, "Stop the tasks workers run that the manager does not know")
+	managerCmd.Flags().Duration("idempotency-key-ttl", manager.DefaultIdempotencyKeyTTL, "How long repeated submissions with the same Idempotency-Key return the task it created")
+	managerCmd.Flags().StringSlice("peers", nil, "Other replicas of a manager cluster (e.g. host2:5555,host3:5555)")
+	managerCmd.Flags().String("advertise", "", "Address the other replicas reach this one at (defaults to hostname:port)")
+	managerCmd.Flags().Bool("auth", false, "Require a token on every request")
+	managerCmd.Flags().String("cluster-token", "", "Secret the manager, its replicas and its workers authenticate each other with (defaults to $CUBE_CLUSTER_TOKEN)")
 	managerCmd.Flags().StringP("dbType", "d", "memory", "Type of datastore to use for events and tasks (\"memory\" or \"persistent\")")
 }
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+// namespaceCmd represents the namespace command
+var namespaceCmd = &cobra.Command{
+	Use:   "namespace",
+	Short: "Namespace command to manage namespaces and their quotas.",
+	Long: `cube namespace command.
+
+Every task belongs to a namespace. A namespace quota caps the number of active
+tasks and the total CPU and memory they request; submissions beyond it are
+rejected. A quota value of zero means unlimited.`,
+}
+
+var namespaceCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Create a namespace.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendNamespace(cmd, http.MethodPost, args[0])
+	},
+}
+
+var namespaceUpdateCmd = &cobra.Command{
+	Use:   "update <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Replace the quota of a namespace.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return sendNamespace(cmd, http.MethodPut, args[0])
+	},
+}
+
+var namespaceLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List namespaces with their usage and quota.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := cmd.Flags().GetString("manager")
+		if err != nil {
+			return err
+		}
+
+		var namespaces []*namespace.Namespace
+		url := fmt.Sprintf("http://%s/namespaces", manager)
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &namespaces)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tTASKS\tCPU\tMEMORY\tAGE\t")
+		for _, n := range namespaces {
+			used, quota := n.Status.Used, n.Quota
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", n.Name,
+				formatQuota(fmt.Sprint(used.Tasks), quota.Tasks == 0, fmt.Sprint(quota.Tasks)),
+				formatQuota(fmt.Sprintf("%g", used.Cpu), quota.Cpu == 0, fmt.Sprintf("%g", quota.Cpu)),
+				formatQuota(units.BytesSize(float64(used.Memory)), quota.Memory == 0, units.BytesSize(float64(quota.Memory))),
+				humanTime(n.CreatedAt))
+		}
+
+		return w.Flush()
+	},
+}
+
+var namespaceRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Remove an empty namespace.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := cmd.Flags().GetString("manager")
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/namespaces/%s", manager, args[0])
+		err = sendRequest(http.MethodDelete, url, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Namespace %s has been removed.", args[0])
+
+		return nil
+	},
+}
+
+// sendNamespace creates or updates the named namespace with the quota given
+// by the command's flags.
+func sendNamespace(cmd *cobra.Command, method string, name string) error {
+	manager, err := cmd.Flags().GetString("manager")
+	if err != nil {
+		return err
+	}
+	tasks, err := cmd.Flags().GetInt("tasks")
+	if err != nil {
+		return err
+	}
+	cpu, err := cmd.Flags().GetFloat64("cpu")
+	if err != nil {
+		return err
+	}
+	memory, err := cmd.Flags().GetString("memory")
+	if err != nil {
+		return err
+	}
+
+	n := namespace.Namespace{
+		Name: name,
+		Quota: namespace.Resources{
+			Tasks: tasks,
+			Cpu:   cpu,
+		},
+	}
+	if memory != "" {
+		n.Quota.Memory, err = units.RAMInBytes(memory)
+		if err != nil {
+			return err
+		}
+	}
+
+	url := fmt.Sprintf("http://%s/namespaces", manager)
+	status := http.StatusCreated
+	if method == http.MethodPut {
+		url = fmt.Sprintf("%s/%s", url, name)
+		status = http.StatusOK
+	}
+
+	err = sendRequest(method, url, n, status, nil)
+	if err != nil {
+		return err
+	}
+	log.Printf("Namespace %s has been saved.", name)
+
+	return nil
+}
+
+// formatQuota renders usage against a limit, e.g. "3/10" or "3/-" when
+// unlimited.
+func formatQuota(used string, unlimited bool, limit string) string {
+	if unlimited {
+		limit = "-"
+	}
+	return used + "/" + limit
+}
+
+func init() {
+	rootCmd.AddCommand(namespaceCmd)
+	namespaceCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	for _, c := range []*cobra.Command{namespaceCreateCmd, namespaceUpdateCmd} {
+		c.Flags().Int("tasks", 0, "Maximum number of active tasks (0 for unlimited)")
+		c.Flags().Float64("cpu", 0, "Maximum total CPU of active tasks (0 for unlimited)")
+		c.Flags().String("memory", "", "Maximum total memory of active tasks, e.g. 4g (empty for unlimited)")
+		namespaceCmd.AddCommand(c)
+	}
+	namespaceCmd.AddCommand(namespaceLsCmd)
+	namespaceCmd.AddCommand(namespaceRmCmd)
+}
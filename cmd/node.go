@@ -1,13 +1,18 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
+	"log"
 	"net/http"
+	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/labels"
 	"github.com/dev6699/cube/node"
 	"github.com/spf13/cobra"
 )
@@ -18,42 +23,248 @@ var nodeCmd = &cobra.Command{
 	Short: "Node command to list nodes.",
 	Long: `cube node command.
 
-The node command allows a user to get the information about the nodes in the cluster.`,
+The node command allows a user to get the information about the nodes in the cluster.
+Nodes can be selected by label with -l, e.g. -l zone=eu-west.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		manager, err := cmd.Flags().GetString("manager")
+		c, err := newClient(cmd)
 		if err != nil {
 			return err
 		}
-
-		url := fmt.Sprintf("http://%s/nodes", manager)
-		resp, err := http.Get(url)
+		selector, err := cmd.Flags().GetString("selector")
 		if err != nil {
 			return err
 		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
+		sel, err := labels.Parse(selector)
 		if err != nil {
 			return err
 		}
 
-		var nodes []*node.Node
-		err = json.Unmarshal(body, &nodes)
+		nodes, err := c.ListNodes(cmd.Context(), sel)
 		if err != nil {
 			return err
 		}
 
 		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
-		fmt.Fprintln(w, "NAME\tMEMORY (MiB)\tDISK (GiB)\tROLE\tTASKS\t")
-		for _, node := range nodes {
-			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\t\n", node.Name, node.Memory/1000, node.Disk/1000/1000/1000, node.Role, node.Stats.TaskCount)
+		fmt.Fprintln(w, "NAME\tSTATUS\tMEMORY (MiB)\tDISK (GiB)\tROLE\tTASKS\tHEARTBEAT\tLABELS\t")
+		for _, node := range nodes.Items {
+			heartbeat := "-"
+			if node.LastHeartbeat != nil {
+				heartbeat = humanTime(*node.LastHeartbeat)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\t%d\t%s\t%s\t\n", node.Name, nodeStatus(node), node.Memory/1000, node.Disk/1000/1000/1000, node.Role, node.Tasks, heartbeat, formatLabels(node.Labels))
 		}
 
 		return w.Flush()
 	},
 }
 
+var nodeLabelCmd = &cobra.Command{
+	Use:   "label <name> <key=value|key->...",
+	Args:  cobra.MinimumNArgs(2),
+	Short: "Set or remove labels of a node.",
+	Long: `cube node label command.
+
+Sets labels given as key=value and removes labels given as key-.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := parseMetadataArgs(args[1:])
+		if err != nil {
+			return err
+		}
+		return patchNode(cmd, args[0], node.Patch{Labels: m})
+	},
+}
+
+var nodeAnnotateCmd = &cobra.Command{
+	Use:   "annotate <name> <key=value|key->...",
+	Args:  cobra.MinimumNArgs(2),
+	Short: "Set or remove annotations of a node.",
+	Long: `cube node annotate command.
+
+Sets annotations given as key=value and removes annotations given as key-.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		m, err := parseMetadataArgs(args[1:])
+		if err != nil {
+			return err
+		}
+		return patchNode(cmd, args[0], node.Patch{Annotations: m})
+	},
+}
+
+var nodeCordonCmd = &cobra.Command{
+	Use:   "cordon <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Stop scheduling new tasks on a node.",
+	Long: `cube node cordon command.
+
+Marks a node unschedulable. Tasks already running on it are left alone.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := nodeAction(cmd, args[0], "cordon", http.StatusOK)
+		if err != nil {
+			return err
+		}
+		log.Printf("Node %s has been cordoned.", args[0])
+		return nil
+	},
+}
+
+var nodeUncordonCmd = &cobra.Command{
+	Use:   "uncordon <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Allow scheduling new tasks on a node again.",
+	Long: `cube node uncordon command.
+
+Marks a node schedulable again and cancels a drain in progress.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		_, err := nodeAction(cmd, args[0], "uncordon", http.StatusOK)
+		if err != nil {
+			return err
+		}
+		log.Printf("Node %s has been uncordoned.", args[0])
+		return nil
+	},
+}
+
+var nodeDrainCmd = &cobra.Command{
+	Use:   "drain <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Move all tasks off a node.",
+	Long: `cube node drain command.
+
+Cordons a node and moves its tasks to other nodes. Tasks of a service are moved
+only as fast as the service's disruption budget allows. With --wait the command
+returns once the node is empty; run cube node uncordon when maintenance is done.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wait, err := cmd.Flags().GetBool("wait")
+		if err != nil {
+			return err
+		}
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		n, err := nodeAction(cmd, name, "drain", http.StatusAccepted)
+		if err != nil {
+			return err
+		}
+		log.Printf("Node %s is draining.", name)
+		if !wait {
+			return nil
+		}
+
+		c, err := newClient(cmd)
+		if err != nil {
+			return err
+		}
+		deadline := time.Now().Add(timeout)
+		draining := n.Draining
+		for draining {
+			if timeout > 0 && time.Now().After(deadline) {
+				return fmt.Errorf("node %s not drained after %v", name, timeout)
+			}
+			time.Sleep(2 * time.Second)
+
+			n, err := c.GetNode(cmd.Context(), name)
+			if err != nil {
+				return err
+			}
+			draining = n.Draining
+			if !n.Unschedulable {
+				return fmt.Errorf("node %s was uncordoned while draining", name)
+			}
+		}
+		log.Printf("Node %s has been drained.", name)
+		return nil
+	},
+}
+
+// nodeAction posts to one of the node's action endpoints and returns the
+// updated node.
+func nodeAction(cmd *cobra.Command, name string, action string, status int) (*node.Node, error) {
+	manager, err := cmd.Flags().GetString("manager")
+	if err != nil {
+		return nil, err
+	}
+
+	var n node.Node
+	u := fmt.Sprintf("http://%s/nodes/%s/%s", manager, url.PathEscape(name), action)
+	err = sendRequest(http.MethodPost, u, nil, status, &n)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// nodeStatus renders the health of a node along with its scheduling state.
+func nodeStatus(n v1.Node) string {
+	status := n.Status
+	if n.Draining {
+		return status + ",Draining"
+	}
+	if n.Unschedulable {
+		return status + ",SchedulingDisabled"
+	}
+	return status
+}
+
+func patchNode(cmd *cobra.Command, name string, p node.Patch) error {
+	manager, err := cmd.Flags().GetString("manager")
+	if err != nil {
+		return err
+	}
+
+	u := fmt.Sprintf("http://%s/nodes/%s", manager, name)
+	err = sendRequest(http.MethodPatch, u, p, http.StatusOK, nil)
+	if err != nil {
+		return err
+	}
+	log.Printf("Node %s has been updated.", name)
+
+	return nil
+}
+
+// parseMetadataArgs turns key=value arguments into set operations and key-
+// arguments into removals.
+func parseMetadataArgs(args []string) (map[string]*string, error) {
+	m := make(map[string]*string)
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			m[key] = &value
+			continue
+		}
+		if key, ok := strings.CutSuffix(arg, "-"); ok && key != "" {
+			m[key] = nil
+			continue
+		}
+		return nil, fmt.Errorf("invalid argument %q, expected key=value or key-", arg)
+	}
+	return m, nil
+}
+
+// formatLabels renders labels as a sorted key=value list.
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return "-"
+	}
+
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
 func init() {
 	rootCmd.AddCommand(nodeCmd)
-	nodeCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	nodeCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	nodeCmd.Flags().StringP("selector", "l", "", "Label selector to filter nodes (e.g. zone=eu-west)")
+	nodeCmd.AddCommand(nodeLabelCmd)
+	nodeCmd.AddCommand(nodeAnnotateCmd)
+	nodeCmd.AddCommand(nodeCordonCmd)
+	nodeCmd.AddCommand(nodeUncordonCmd)
+	nodeCmd.AddCommand(nodeDrainCmd)
+	nodeDrainCmd.Flags().Bool("wait", false, "Wait until all tasks have been moved off the node")
+	nodeDrainCmd.Flags().Duration("timeout", 0, "Give up waiting after this duration (0 waits forever)")
 }
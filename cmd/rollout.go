@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/docker/go-units"
+	"github.com/spf13/cobra"
+)
+
+// rolloutCmd represents the rollout command
+var rolloutCmd = &cobra.Command{
+	Use:   "rollout",
+	Short: "Rollout command to manage service updates.",
+	Long: `cube rollout command.
+
+The rollout command shows the progress and revision history of service updates,
+and pauses, resumes or undoes them.`,
+}
+
+var rolloutStatusCmd = &cobra.Command{
+	Use:   "status <service>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Show the update status of a service.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var s service.Service
+		url := fmt.Sprintf("http://%s/services/%s", manager, args[0])
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &s)
+		if err != nil {
+			return err
+		}
+
+		state := s.Status.UpdateState
+		if state == "" {
+			state = service.UpdateCompleted
+		}
+		fmt.Printf("Service:  %s\n", s.Name)
+		fmt.Printf("Revision: %d\n", s.Revision)
+		fmt.Printf("State:    %s\n", state)
+		fmt.Printf("Replicas: %d desired, %d updated, %d running, %d pending\n",
+			s.Replicas, s.Status.UpdatedReplicas, s.Status.RunningReplicas, s.Status.PendingReplicas)
+		if s.Status.Message != "" {
+			fmt.Printf("Message:  %s\n", s.Status.Message)
+		}
+
+		return nil
+	},
+}
+
+var rolloutHistoryCmd = &cobra.Command{
+	Use:   "history <service>",
+	Args:  cobra.ExactArgs(1),
+	Short: "List the revisions of a service.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var revisions []service.Revision
+		url := fmt.Sprintf("http://%s/services/%s/revisions", manager, args[0])
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &revisions)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "REVISION\tCREATED\tIMAGE\t")
+		for _, r := range revisions {
+			created := fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(r.CreatedAt)))
+			fmt.Fprintf(w, "%d\t%s\t%s\t\n", r.Number, created, r.Template.Image)
+		}
+
+		return w.Flush()
+	},
+}
+
+var rolloutUndoCmd = &cobra.Command{
+	Use:   "undo <service>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Roll a service back to a previous revision.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+		revision, err := cmd.Flags().GetInt("to-revision")
+		if err != nil {
+			return err
+		}
+
+		var s service.Service
+		url := fmt.Sprintf("http://%s/services/%s/rollback", manager, args[0])
+		err = sendRequest(http.MethodPost, url, service.RollbackRequest{Revision: revision}, http.StatusOK, &s)
+		if err != nil {
+			return err
+		}
+		log.Printf("Service %s: %s.", s.Name, s.Status.Message)
+
+		return nil
+	},
+}
+
+var rolloutPauseCmd = &cobra.Command{
+	Use:   "pause <service>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Pause the update of a service.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return postRolloutAction(cmd, args[0], "pause")
+	},
+}
+
+var rolloutResumeCmd = &cobra.Command{
+	Use:   "resume <service>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Resume a paused update of a service.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return postRolloutAction(cmd, args[0], "resume")
+	},
+}
+
+func postRolloutAction(cmd *cobra.Command, name string, action string) error {
+	manager, err := namespacedManager(cmd)
+	if err != nil {
+		return err
+	}
+
+	var s service.Service
+	url := fmt.Sprintf("http://%s/services/%s/%s", manager, name, action)
+	err = sendRequest(http.MethodPost, url, nil, http.StatusOK, &s)
+	if err != nil {
+		return err
+	}
+	log.Printf("Service %s: %s.", s.Name, s.Status.Message)
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(rolloutCmd)
+	rolloutCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	rolloutCmd.PersistentFlags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	rolloutCmd.AddCommand(rolloutStatusCmd)
+	rolloutCmd.AddCommand(rolloutHistoryCmd)
+	rolloutCmd.AddCommand(rolloutUndoCmd)
+	rolloutUndoCmd.Flags().Int("to-revision", 0, "Revision to roll back to (default: the previous revision)")
+	rolloutCmd.AddCommand(rolloutPauseCmd)
+	rolloutCmd.AddCommand(rolloutResumeCmd)
+}
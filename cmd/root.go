@@ -16,6 +16,11 @@ With Cube, you can efficiently manage, monitor, and scale tasks across multiple
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		token, err = loadToken(cmd)
+		return err
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -33,6 +38,7 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.cube.yaml)")
+	rootCmd.PersistentFlags().String("token", "", "Token to authenticate with the manager (defaults to $CUBE_TOKEN, then the token in $CUBE_CONFIG or ~/.cube/config.json)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
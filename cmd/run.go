@@ -2,13 +2,17 @@ package cmd
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
 	"log"
-	"net/http"
 	"os"
 
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
@@ -17,9 +21,12 @@ var runCmd = &cobra.Command{
 	Use:   "run",
 	Short: "Run a new task.",
 	Long: `cube run command.
-The run command starts a new task.`,
+The run command starts a new task. The file holds either a task request of the
+v1 API or, as before, a task event. A task without an ID is given one by the
+manager. Failed requests are repeated with the same --idempotency-key, which
+defaults to a new key on every run, so the task is added at most once.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		manager, err := cmd.Flags().GetString("manager")
+		c, err := newClient(cmd)
 		if err != nil {
 			return err
 		}
@@ -36,24 +43,54 @@ The run command starts a new task.`,
 		if err != nil {
 			return err
 		}
+		req, err := parseTaskRequest(data)
+		if err != nil {
+			return fmt.Errorf("invalid task in %s: %v", filename, err)
+		}
 
-		log.Printf("Data: %v\n", string(data))
-		url := fmt.Sprintf("http://%s/tasks", manager)
-		resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+		key, err := cmd.Flags().GetString("idempotency-key")
 		if err != nil {
 			return err
 		}
+		if key == "" {
+			key = uuid.New().String()
+		}
 
-		if resp.StatusCode != http.StatusCreated {
-			log.Printf("Error sending request: %v", resp.StatusCode)
+		log.Printf("Data: %v\n", string(data))
+		t, err := c.SubmitTask(cmd.Context(), req, key)
+		if err != nil {
+			return err
 		}
-		defer resp.Body.Close()
-		log.Println("Successfully sent task request to manager")
+		log.Printf("Successfully sent task request to manager, task %s is %s\n", t.ID, t.State)
 
 		return nil
 	},
 }
 
+// parseTaskRequest reads a task request, or the task of a task event, which
+// is recognized by its Task field.
+func parseTaskRequest(data []byte) (v1.TaskRequest, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return v1.TaskRequest{}, err
+	}
+	if _, ok := fields["Task"]; ok {
+		var te task.TaskEvent
+		err := json.Unmarshal(data, &te)
+		if err != nil {
+			return v1.TaskRequest{}, err
+		}
+		return v1.NewTaskRequest(te.Task), nil
+	}
+
+	var req v1.TaskRequest
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	err = d.Decode(&req)
+	return req, err
+}
+
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return !errors.Is(err, fs.ErrNotExist)
@@ -62,5 +99,7 @@ func fileExists(filename string) bool {
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	runCmd.Flags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
 	runCmd.Flags().StringP("filename", "f", "task.json", "Task specification file")
+	runCmd.Flags().String("idempotency-key", "", "Key that makes repeated runs add the task only once (defaults to a new key)")
 }
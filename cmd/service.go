@@ -0,0 +1,278 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/spf13/cobra"
+)
+
+// serviceCmd represents the service command
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Service command to manage replicated services.",
+	Long: `cube service command.
+
+A service keeps a desired number of replicas of a task template running. The manager
+creates, replaces and removes tasks until the running count matches the service.`,
+}
+
+var serviceCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new service.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+		filename, err := cmd.Flags().GetString("filename")
+		if err != nil {
+			return err
+		}
+
+		spec, err := readServiceSpec(filename)
+		if err != nil {
+			return err
+		}
+
+		var s service.Service
+		url := fmt.Sprintf("http://%s/services", manager)
+		err = sendRequest(http.MethodPost, url, spec, http.StatusCreated, &s)
+		if err != nil {
+			return err
+		}
+		log.Printf("Service %s created with %d replicas.", s.Name, s.Replicas)
+
+		return nil
+	},
+}
+
+var serviceUpdateCmd = &cobra.Command{
+	Use:   "update",
+	Short: "Update a service, rolling out a new revision if its template changed.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+		filename, err := cmd.Flags().GetString("filename")
+		if err != nil {
+			return err
+		}
+
+		spec, err := readServiceSpec(filename)
+		if err != nil {
+			return err
+		}
+
+		var s service.Service
+		url := fmt.Sprintf("http://%s/services/%s", manager, spec.Name)
+		err = sendRequest(http.MethodPut, url, spec, http.StatusOK, &s)
+		if err != nil {
+			return err
+		}
+		log.Printf("Service %s is at revision %d.", s.Name, s.Revision)
+
+		return nil
+	},
+}
+
+var serviceLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List services.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		var services []*service.Service
+		url := fmt.Sprintf("http://%s/services", manager)
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &services)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tIMAGE\tREPLICAS\tPENDING\tREVISION\tUPDATE\tAUTOSCALE\t")
+		for _, s := range services {
+			autoscale := "-"
+			if a := s.Autoscale; a != nil {
+				autoscale = fmt.Sprintf("%d-%d (%s %d%%/%d%%)", a.MinReplicas, a.MaxReplicas, a.Metric, s.Status.Utilization, a.TargetUtilization)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%d\t%d\t%s\t%s\t\n", s.Name, s.Template.Image, s.Status.RunningReplicas, s.Replicas, s.Status.PendingReplicas, s.Revision, s.Status.UpdateState, autoscale)
+		}
+
+		return w.Flush()
+	},
+}
+
+var serviceScaleCmd = &cobra.Command{
+	Use:   "scale <name> <replicas>",
+	Args:  cobra.ExactArgs(2),
+	Short: "Change the number of replicas of a service.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		replicas, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid replicas %s: %v", args[1], err)
+		}
+
+		url := fmt.Sprintf("http://%s/services/%s/scale", manager, args[0])
+		err = sendRequest(http.MethodPut, url, service.ScaleRequest{Replicas: replicas}, http.StatusOK, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Service %s scaled to %d replicas.", args[0], replicas)
+
+		return nil
+	},
+}
+
+var serviceRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Remove a service and stop its tasks.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/services/%s", manager, args[0])
+		err = sendRequest(http.MethodDelete, url, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Service %s has been removed.", args[0])
+
+		return nil
+	},
+}
+
+var serviceAutoscaleCmd = &cobra.Command{
+	Use:   "autoscale <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Scale a service automatically from task CPU or memory utilization.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+		disable, err := cmd.Flags().GetBool("disable")
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/services/%s/autoscale", manager, args[0])
+		if disable {
+			err = sendRequest(http.MethodDelete, url, nil, http.StatusNoContent, nil)
+			if err != nil {
+				return err
+			}
+			log.Printf("Autoscaling of service %s disabled.", args[0])
+			return nil
+		}
+
+		minReplicas, err := cmd.Flags().GetInt("min")
+		if err != nil {
+			return err
+		}
+		maxReplicas, err := cmd.Flags().GetInt("max")
+		if err != nil {
+			return err
+		}
+		cpuPercent, err := cmd.Flags().GetInt("cpu-percent")
+		if err != nil {
+			return err
+		}
+		memoryPercent, err := cmd.Flags().GetInt("memory-percent")
+		if err != nil {
+			return err
+		}
+		upWindow, err := cmd.Flags().GetDuration("scale-up-window")
+		if err != nil {
+			return err
+		}
+		downWindow, err := cmd.Flags().GetDuration("scale-down-window")
+		if err != nil {
+			return err
+		}
+
+		downSeconds := int(downWindow.Seconds())
+		a := service.Autoscale{
+			MinReplicas:                   minReplicas,
+			MaxReplicas:                   maxReplicas,
+			Metric:                        service.MetricCpu,
+			TargetUtilization:             cpuPercent,
+			ScaleUpStabilizationSeconds:   int(upWindow.Seconds()),
+			ScaleDownStabilizationSeconds: &downSeconds,
+		}
+		if memoryPercent > 0 {
+			if cmd.Flags().Changed("cpu-percent") {
+				return fmt.Errorf("--cpu-percent and --memory-percent are mutually exclusive")
+			}
+			a.Metric = service.MetricMemory
+			a.TargetUtilization = memoryPercent
+		}
+
+		err = sendRequest(http.MethodPut, url, a, http.StatusOK, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Service %s autoscales between %d and %d replicas at %d%% %s.", args[0], a.MinReplicas, a.MaxReplicas, a.TargetUtilization, a.Metric)
+
+		return nil
+	},
+}
+
+func readServiceSpec(filename string) (*service.Service, error) {
+	if !fileExists(filename) {
+		return nil, fmt.Errorf("file %s does not exist", filename)
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var s service.Service
+	err = json.Unmarshal(data, &s)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func init() {
+	rootCmd.AddCommand(serviceCmd)
+	serviceCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	serviceCmd.PersistentFlags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	serviceCmd.AddCommand(serviceCreateCmd)
+	serviceCreateCmd.Flags().StringP("filename", "f", "service.json", "Service specification file")
+	serviceCmd.AddCommand(serviceUpdateCmd)
+	serviceUpdateCmd.Flags().StringP("filename", "f", "service.json", "Service specification file")
+	serviceCmd.AddCommand(serviceLsCmd)
+	serviceCmd.AddCommand(serviceScaleCmd)
+	serviceCmd.AddCommand(serviceRmCmd)
+	serviceCmd.AddCommand(serviceAutoscaleCmd)
+	serviceAutoscaleCmd.Flags().Int("min", 1, "Minimum number of replicas")
+	serviceAutoscaleCmd.Flags().Int("max", 1, "Maximum number of replicas")
+	serviceAutoscaleCmd.Flags().Int("cpu-percent", 80, "Target average CPU utilization in percent of each task's CPU")
+	serviceAutoscaleCmd.Flags().Int("memory-percent", 0, "Target average memory utilization in percent of each task's memory")
+	serviceAutoscaleCmd.Flags().Duration("scale-up-window", 0, "Stabilization window for scaling up")
+	serviceAutoscaleCmd.Flags().Duration("scale-down-window", 5*time.Minute, "Stabilization window for scaling down")
+	serviceAutoscaleCmd.Flags().Bool("disable", false, "Disable autoscaling")
+}
@@ -1,69 +1,339 @@
 package cmd
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"io"
-	"net/http"
+	"log"
 	"os"
+	"slices"
+	"sort"
 	"text/tabwriter"
 	"time"
 
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/client"
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
 	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/watch"
 	"github.com/docker/go-units"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
 // statusCmd represents the status command
 var statusCmd = &cobra.Command{
-	Use:   "status",
+	Use:   "status [taskID...]",
 	Short: "Status command to list tasks.",
 	Long: `cube status command.
 
-The status command allows a user to get the status of tasks from the Cube manager.`,
+The status command allows a user to get the status of tasks from the Cube manager.
+With --watch the table is updated in place as tasks change. Combined with --until,
+the command exits once every given task reaches the requested state, which lets
+scripts block until a task is Running. Tasks can be selected by label with
+-l, e.g. -l app=billing or -l 'env in (prod,staging)', and by --state, --node,
+--image, --name and the time they started or finished.
+
+Without --watch at most --limit tasks are listed, ordered by --sort. When more
+tasks match, the command prints the --cursor that lists the next page:
+
+  cube status --state Failed --sort -finished --limit 20`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		manager, err := cmd.Flags().GetString("manager")
+		c, err := newClient(cmd)
 		if err != nil {
 			return err
 		}
-
-		url := fmt.Sprintf("http://%s/tasks", manager)
-		resp, err := http.Get(url)
+		watchTasks, err := cmd.Flags().GetBool("watch")
 		if err != nil {
 			return err
 		}
-
-		body, err := io.ReadAll(resp.Body)
+		until, err := cmd.Flags().GetString("until")
+		if err != nil {
+			return err
+		}
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			return err
+		}
+		q, err := taskQuery(cmd)
 		if err != nil {
 			return err
 		}
 
-		defer resp.Body.Close()
+		var ids []uuid.UUID
+		for _, arg := range args {
+			id, err := uuid.Parse(arg)
+			if err != nil {
+				return fmt.Errorf("invalid task ID %s: %v", arg, err)
+			}
+			ids = append(ids, id)
+		}
+
+		if watchTasks || until != "" {
+			var target *task.State
+			if until != "" {
+				if len(ids) == 0 {
+					return fmt.Errorf("--until requires at least one task ID")
+				}
+				s, err := task.ParseState(until)
+				if err != nil {
+					return err
+				}
+				target = &s
+			}
+			return watchStatus(cmd.Context(), c, ids, q, target, !watchTasks, timeout)
+		}
+
+		if len(ids) > 0 {
+			var tasks []v1.Task
+			for _, id := range ids {
+				t, err := c.GetTask(cmd.Context(), id)
+				if err != nil {
+					return err
+				}
+				tasks = append(tasks, *t)
+			}
+			return printTasks(os.Stdout, tasks)
+		}
 
-		var tasks []*task.Task
-		err = json.Unmarshal(body, &tasks)
+		list, err := c.ListTasks(cmd.Context(), q)
 		if err != nil {
 			return err
 		}
+		err = printTasks(os.Stdout, list.Items)
+		if err != nil {
+			return err
+		}
+		if list.Next != "" {
+			fmt.Fprintf(os.Stderr, "More tasks are listed with --cursor %s\n", list.Next)
+		}
+		return nil
+	},
+}
+
+// taskQuery builds the query selecting the tasks to list from the flags of
+// cmd.
+func taskQuery(cmd *cobra.Command) (v1.TaskQuery, error) {
+	var q v1.TaskQuery
+
+	selector, err := cmd.Flags().GetString("selector")
+	if err != nil {
+		return q, err
+	}
+	q.Selector, err = labels.Parse(selector)
+	if err != nil {
+		return q, err
+	}
+	states, err := cmd.Flags().GetStringSlice("state")
+	if err != nil {
+		return q, err
+	}
+	for _, s := range states {
+		st, err := task.ParseState(s)
+		if err != nil {
+			return q, err
+		}
+		q.States = append(q.States, st)
+	}
+
+	texts := map[string]*string{
+		"node":   &q.Node,
+		"image":  &q.Image,
+		"name":   &q.Name,
+		"sort":   &q.Sort,
+		"cursor": &q.Cursor,
+	}
+	for flag, s := range texts {
+		*s, err = cmd.Flags().GetString(flag)
+		if err != nil {
+			return q, err
+		}
+	}
+	q.Limit, err = cmd.Flags().GetInt("limit")
+	if err != nil {
+		return q, err
+	}
+
+	times := map[string]*time.Time{
+		"started-after":   &q.StartedAfter,
+		"started-before":  &q.StartedBefore,
+		"finished-after":  &q.FinishedAfter,
+		"finished-before": &q.FinishedBefore,
+	}
+	for flag, t := range times {
+		s, err := cmd.Flags().GetString(flag)
+		if err != nil {
+			return q, err
+		}
+		*t, err = parseTime(s)
+		if err != nil {
+			return q, fmt.Errorf("invalid --%s: %v", flag, err)
+		}
+	}
+	return q, nil
+}
+
+// parseTime parses s as an RFC 3339 time, or as a duration that far in the
+// past. An empty s is the zero time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+func printTasks(out io.Writer, tasks []v1.Task) error {
+	w := tabwriter.NewWriter(out, 0, 0, 5, ' ', tabwriter.TabIndent)
+	fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATE\tCONTAINERNAME\tIMAGE\t")
+	for _, task := range tasks {
+		var start string
+		if task.StartTime == nil {
+			start = fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(time.Now().UTC())))
+		} else {
+			start = fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(*task.StartTime)))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", task.ID.String(), task.Name, start, task.State, task.Name, task.Image)
+	}
+
+	return w.Flush()
+}
+
+func filterTasks(tasks []*task.Task, ids []uuid.UUID, q v1.TaskQuery) []*task.Task {
+	var filtered []*task.Task
+	for _, t := range tasks {
+		if !q.Matches(t) {
+			continue
+		}
+		if len(ids) == 0 || slices.Contains(ids, t.ID) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
-		fmt.Fprintln(w, "ID\tNAME\tCREATED\tSTATE\tCONTAINERNAME\tIMAGE\t")
-		for _, task := range tasks {
-			var start string
-			if task.StartTime.IsZero() {
-				start = fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(time.Now().UTC())))
-			} else {
-				start = fmt.Sprintf("%s ago", units.HumanDuration(time.Now().UTC().Sub(task.StartTime)))
+// watchStatus follows the manager's watch stream, reconnecting from the last
+// seen resource version when the connection drops. With a target state it
+// returns once every task in ids has reached it; with quiet set the table is
+// not printed.
+func watchStatus(ctx context.Context, c *client.Client, ids []uuid.UUID, q v1.TaskQuery, target *task.State, quiet bool, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	tasks := make(map[uuid.UUID]*task.Task)
+	var rv uint64
+	for {
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %v", timeout)
+		}
+
+		done, err := followWatch(ctx, c, &rv, tasks, func() (bool, error) {
+			var list []*task.Task
+			for _, t := range tasks {
+				if t.Namespace == c.Namespace {
+					list = append(list, t)
+				}
+			}
+			list = filterTasks(list, ids, q)
+			sort.Slice(list, func(i, j int) bool {
+				return list[i].ID.String() < list[j].ID.String()
+			})
+
+			if !quiet {
+				fmt.Print("\033[H\033[2J")
+				printTasks(os.Stdout, v1.FromTasks(list))
+			}
+			if target == nil || len(list) < len(ids) {
+				return false, nil
 			}
-			state := task.State.String()
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", task.ID.String(), task.Name, start, state, task.Name, task.Image)
+
+			for _, t := range list {
+				if t.State == *target {
+					continue
+				}
+				if t.State == task.Completed || t.State == task.Failed {
+					return false, fmt.Errorf("task %s is %s", t.ID, t.State)
+				}
+				return false, nil
+			}
+			return true, nil
+		})
+		if done {
+			return err
 		}
 
-		return w.Flush()
-	},
+		if errors.Is(err, client.ErrWatchExpired) {
+			rv = 0
+			clear(tasks)
+			continue
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Printf("watch interrupted: %v", err)
+		}
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// followWatch consumes one watch connection. It returns done when update
+// reports completion or a terminal error.
+func followWatch(ctx context.Context, c *client.Client, rv *uint64, tasks map[uuid.UUID]*task.Task, update func() (bool, error)) (bool, error) {
+	w, err := c.Watch(ctx, watch.KindTask, *rv)
+	if err != nil {
+		return false, err
+	}
+	defer w.Close()
+
+	for {
+		e, err := w.Next()
+		if err != nil {
+			return false, err
+		}
+
+		*rv = e.ResourceVersion
+		if e.Task == nil {
+			continue
+		}
+		if e.Type == watch.Deleted {
+			delete(tasks, e.Task.ID)
+		} else {
+			tasks[e.Task.ID] = e.Task
+		}
+
+		done, err := update()
+		if done || err != nil {
+			return true, err
+		}
+	}
 }
 
 func init() {
 	rootCmd.AddCommand(statusCmd)
 	statusCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	statusCmd.Flags().BoolP("watch", "w", false, "Watch for changes and update the table in place")
+	statusCmd.Flags().String("until", "", "Exit once all given tasks reach this state (e.g. \"Running\")")
+	statusCmd.Flags().Duration("timeout", 0, "Give up waiting after this duration (0 waits forever)")
+	statusCmd.Flags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	statusCmd.Flags().StringP("selector", "l", "", "Label selector to filter tasks (e.g. app=billing)")
+	statusCmd.Flags().StringSlice("state", nil, "Only list tasks in these states (e.g. Running,Failed)")
+	statusCmd.Flags().String("node", "", "Only list tasks placed on this node")
+	statusCmd.Flags().String("image", "", "Only list tasks running this image")
+	statusCmd.Flags().String("name", "", "Only list tasks whose name contains this")
+	statusCmd.Flags().String("started-after", "", "Only list tasks started after this time (RFC 3339, or a duration ago such as 1h)")
+	statusCmd.Flags().String("started-before", "", "Only list tasks started before this time")
+	statusCmd.Flags().String("finished-after", "", "Only list tasks finished after this time")
+	statusCmd.Flags().String("finished-before", "", "Only list tasks finished before this time")
+	statusCmd.Flags().String("sort", "", "Field to sort by: id, name, image, node, state, started or finished; prefix with - for descending order")
+	statusCmd.Flags().Int("limit", 100, "Maximum number of tasks to list (0 for all)")
+	statusCmd.Flags().String("cursor", "", "Continue a listing where the previous page ended")
 }
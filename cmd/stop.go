@@ -3,40 +3,83 @@ package cmd
 import (
 	"fmt"
 	"log"
-	"net/http"
 
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 )
 
 // stopCmd represents the stop command
 var stopCmd = &cobra.Command{
-	Use:   "stop",
-	Args:  cobra.MinimumNArgs(1),
+	Use:   "stop [taskID...]",
 	Short: "Stop a running task.",
 	Long: `cube stop command.
 
-The stop command stops a running task.`,
+The stop command stops running tasks, given either by ID or, with -l, by a
+label selector such as app=billing.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		manager, err := cmd.Flags().GetString("manager")
+		c, err := newClient(cmd)
 		if err != nil {
 			return err
 		}
-
-		url := fmt.Sprintf("http://%s/tasks/%s", manager, args[0])
-		client := &http.Client{}
-		req, err := http.NewRequest("DELETE", url, nil)
+		selector, err := cmd.Flags().GetString("selector")
 		if err != nil {
 			return err
 		}
-		resp, err := client.Do(req)
-		if err != nil {
-			return err
+
+		if len(args) == 0 && selector == "" {
+			return fmt.Errorf("requires at least one task ID or a label selector")
+		}
+		if len(args) > 0 && selector != "" {
+			return fmt.Errorf("task IDs and a label selector cannot be combined")
 		}
-		if resp.StatusCode != http.StatusNoContent {
-			log.Printf("Error sending request: %v", err)
+
+		var ids []uuid.UUID
+		for _, arg := range args {
+			id, err := uuid.Parse(arg)
+			if err != nil {
+				return fmt.Errorf("invalid task ID %s: %v", arg, err)
+			}
+			ids = append(ids, id)
+		}
+		if selector != "" {
+			sel, err := labels.Parse(selector)
+			if err != nil {
+				return err
+			}
+			list, err := c.ListTasks(cmd.Context(), v1.TaskQuery{Selector: sel})
+			if err != nil {
+				return err
+			}
+			for _, t := range list.Items {
+				if t.DesiredState == task.Completed.String() || t.State == task.Completed.String() || t.State == task.Failed.String() {
+					continue
+				}
+				ids = append(ids, t.ID)
+			}
+			if len(ids) == 0 {
+				log.Printf("No running tasks match %q.", selector)
+				return nil
+			}
 		}
-		log.Printf("Task %v has been stopped.", args[0])
 
+		var failed int
+		for _, id := range ids {
+			err = c.StopTask(cmd.Context(), id)
+			if err != nil {
+				log.Printf("Error stopping task %v: %v", id, err)
+				failed++
+				continue
+			}
+			log.Printf("Task %v has been stopped.", id)
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("failed to stop %d of %d tasks", failed, len(ids))
+		}
 		return nil
 	},
 }
@@ -44,4 +87,6 @@ The stop command stops a running task.`,
 func init() {
 	rootCmd.AddCommand(stopCmd)
 	stopCmd.Flags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	stopCmd.Flags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	stopCmd.Flags().StringP("selector", "l", "", "Stop all running tasks matching this label selector")
 }
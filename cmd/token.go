@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dev6699/cube/auth"
+	"github.com/spf13/cobra"
+)
+
+// tokenCmd represents the token command
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Token command to manage the tokens clients authenticate with.",
+	Long: `cube token command.
+
+A manager started with --auth only answers requests that carry the secret of
+one of its tokens. On its first start it creates a token named admin and logs
+its secret. The CLI sends the secret given with --token, in the CUBE_TOKEN
+environment variable, or in the config file ($CUBE_CONFIG, by default
+~/.cube/config.json):
+
+  {"token": "cube_..."}`,
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Create a token and print its secret.",
+	Long: `cube token create command.
+
+Creates a token and prints its secret, which cannot be shown again. With --ttl
+the token expires after the given duration.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := cmd.Flags().GetString("manager")
+		if err != nil {
+			return err
+		}
+		ttl, err := cmd.Flags().GetDuration("ttl")
+		if err != nil {
+			return err
+		}
+		if ttl < 0 {
+			return fmt.Errorf("--ttl must not be negative")
+		}
+
+		spec := struct {
+			Name      string
+			ExpiresAt time.Time
+		}{Name: args[0]}
+		if ttl > 0 {
+			spec.ExpiresAt = time.Now().Add(ttl).UTC()
+		}
+
+		var t struct {
+			Name   string
+			Secret string
+		}
+		url := fmt.Sprintf("http://%s/tokens", manager)
+		err = sendRequest(http.MethodPost, url, spec, http.StatusCreated, &t)
+		if err != nil {
+			return err
+		}
+		log.Printf("Token %s has been created. Its secret is shown only once:", t.Name)
+		fmt.Println(t.Secret)
+
+		return nil
+	},
+}
+
+var tokenLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List tokens.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := cmd.Flags().GetString("manager")
+		if err != nil {
+			return err
+		}
+
+		var tokens []*auth.Token
+		url := fmt.Sprintf("http://%s/tokens", manager)
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &tokens)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tAGE\tEXPIRES\t")
+		for _, t := range tokens {
+			expires := "never"
+			switch {
+			case t.Expired(now):
+				expires = "expired"
+			case !t.ExpiresAt.IsZero():
+				expires = t.ExpiresAt.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t\n", t.Name, humanTime(t.CreatedAt), expires)
+		}
+
+		return w.Flush()
+	},
+}
+
+var tokenRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Revoke a token.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := cmd.Flags().GetString("manager")
+		if err != nil {
+			return err
+		}
+
+		u := fmt.Sprintf("http://%s/tokens/%s", manager, url.PathEscape(args[0]))
+		err = sendRequest(http.MethodDelete, u, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Token %s has been revoked.", args[0])
+
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	tokenCmd.AddCommand(tokenCreateCmd)
+	tokenCmd.AddCommand(tokenLsCmd)
+	tokenCmd.AddCommand(tokenRmCmd)
+	tokenCreateCmd.Flags().Duration("ttl", 0, "Lifetime of the token (0 never expires)")
+}
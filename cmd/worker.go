@@ -3,6 +3,9 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"net"
+	"os"
+	"strconv"
 
 	"github.com/dev6699/cube/worker"
 	"github.com/google/uuid"
@@ -15,7 +18,15 @@ var workerCmd = &cobra.Command{
 	Short: "Worker command to operate a Cube worker node.",
 	Long: `cube worker command.
 
-The worker runs tasks and responds to the manager's requests about task state.`,
+The worker runs tasks and responds to the manager's requests about task state.
+With --manager the worker registers itself with a running manager and keeps
+sending heartbeats, so nodes can join without restarting the manager. Pass
+every replica of a manager cluster to --manager and the worker switches to
+another replica when the one it talks to fails.
+
+With --cluster-token, which defaults to the CUBE_CLUSTER_TOKEN environment
+variable, the worker only answers requests that carry the token, and sends it
+to the manager. Give the manager the same token.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		host, err := cmd.Flags().GetString("host")
 		if err != nil {
@@ -33,27 +44,74 @@ The worker runs tasks and responds to the manager's requests about task state.`,
 		if err != nil {
 			return err
 		}
+		managers, err := cmd.Flags().GetStringSlice("manager")
+		if err != nil {
+			return err
+		}
+		advertise, err := cmd.Flags().GetString("advertise")
+		if err != nil {
+			return err
+		}
+		labels, err := cmd.Flags().GetStringToString("label")
+		if err != nil {
+			return err
+		}
+		clusterToken, err := cmd.Flags().GetString("cluster-token")
+		if err != nil {
+			return err
+		}
+		if clusterToken == "" {
+			clusterToken = os.Getenv(clusterTokenEnv)
+		}
 
 		w, err := worker.New(name, dbType)
 		if err != nil {
 			return err
 		}
+		w.ClusterToken = clusterToken
 
 		ctx := cmd.Context()
 		api := worker.NewApi(host, port, w)
+		api.Token = clusterToken
 		go w.RunTasks(ctx)
 		go w.CollectStats(ctx)
 		go w.UpdateTasks(ctx)
+		if len(managers) > 0 {
+			if advertise == "" {
+				advertise, err = advertiseAddress(host, port)
+				if err != nil {
+					return err
+				}
+			}
+			go w.Join(ctx, managers, advertise, labels)
+		}
 
 		log.Printf("[worker] listening on http://%s:%d\n", host, port)
 		return api.Start()
 	},
 }
 
+// advertiseAddress returns host:port, using the machine's hostname when
+// the worker listens on all interfaces.
+func advertiseAddress(host string, port int) (string, error) {
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		var err error
+		host, err = os.Hostname()
+		if err != nil {
+			return "", err
+		}
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port)), nil
+}
+
 func init() {
 	rootCmd.AddCommand(workerCmd)
 	workerCmd.Flags().StringP("host", "H", "0.0.0.0", "Hostname or IP address")
 	workerCmd.Flags().IntP("port", "p", 5556, "Port on which to listen")
 	workerCmd.Flags().StringP("name", "n", fmt.Sprintf("worker-%s", uuid.New().String()), "Name of the worker")
 	workerCmd.Flags().StringP("dbtype", "d", "memory", "Type of datastore to use for tasks (\"memory\" or \"bolt\")")
+	workerCmd.Flags().StringSliceP("manager", "m", nil, "Manager to register with (e.g. localhost:5555), or every replica of a manager cluster")
+	workerCmd.Flags().String("advertise", "", "Address the manager should use to reach this worker (defaults to hostname:port)")
+	workerCmd.Flags().String("cluster-token", "", "Secret the manager and the worker authenticate each other with (defaults to $CUBE_CLUSTER_TOKEN)")
+	workerCmd.Flags().StringToStringP("label", "l", nil, "Node labels to register with (e.g. -l zone=eu-west)")
 }
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/workflow"
+	"github.com/spf13/cobra"
+)
+
+// workflowCmd represents the workflow command
+var workflowCmd = &cobra.Command{
+	Use:   "workflow",
+	Short: "Workflow command to run task graphs.",
+	Long: `cube workflow command.
+
+A workflow is a set of steps connected by DependsOn edges. The manager only starts a
+step once all of its dependencies have completed and skips the steps downstream of a
+failed one.`,
+}
+
+var workflowRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Submit a workflow.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+		filename, err := cmd.Flags().GetString("filename")
+		if err != nil {
+			return err
+		}
+		wait, err := cmd.Flags().GetBool("wait")
+		if err != nil {
+			return err
+		}
+
+		if !fileExists(filename) {
+			return fmt.Errorf("file %s does not exist", filename)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+
+		var spec workflow.Workflow
+		err = json.Unmarshal(data, &spec)
+		if err != nil {
+			return err
+		}
+
+		var wf workflow.Workflow
+		url := fmt.Sprintf("http://%s/workflows", manager)
+		err = sendRequest(http.MethodPost, url, spec, http.StatusCreated, &wf)
+		if err != nil {
+			return err
+		}
+		log.Printf("Workflow %s submitted with %d steps.", wf.Name, len(wf.Steps))
+
+		if !wait {
+			return nil
+		}
+
+		url = fmt.Sprintf("http://%s/workflows/%s", manager, wf.Name)
+		for !wf.Finished() {
+			time.Sleep(2 * time.Second)
+			err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &wf)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = printWorkflow(&wf)
+		if err != nil {
+			return err
+		}
+		if wf.Status.State != workflow.Succeeded {
+			return fmt.Errorf("workflow %s %s", wf.Name, wf.Status.State)
+		}
+		return nil
+	},
+}
+
+var workflowStatusCmd = &cobra.Command{
+	Use:   "status [name]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "List workflows, or show the step graph of one workflow.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		if len(args) == 1 {
+			var wf workflow.Workflow
+			url := fmt.Sprintf("http://%s/workflows/%s", manager, args[0])
+			err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &wf)
+			if err != nil {
+				return err
+			}
+			return printWorkflow(&wf)
+		}
+
+		var workflows []*workflow.Workflow
+		url := fmt.Sprintf("http://%s/workflows", manager)
+		err = sendRequest(http.MethodGet, url, nil, http.StatusOK, &workflows)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+		fmt.Fprintln(w, "NAME\tSTATE\tSTEPS\tAGE\t")
+		for _, wf := range workflows {
+			done := 0
+			for _, s := range wf.Status.Steps {
+				if s.State == workflow.StepCompleted {
+					done++
+				}
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d/%d\t%s\t\n", wf.Name, wf.Status.State, done, len(wf.Steps), humanTime(wf.CreatedAt))
+		}
+
+		return w.Flush()
+	},
+}
+
+var workflowRmCmd = &cobra.Command{
+	Use:   "rm <name>",
+	Args:  cobra.ExactArgs(1),
+	Short: "Remove a workflow and stop its running steps.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manager, err := namespacedManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		url := fmt.Sprintf("http://%s/workflows/%s", manager, args[0])
+		err = sendRequest(http.MethodDelete, url, nil, http.StatusNoContent, nil)
+		if err != nil {
+			return err
+		}
+		log.Printf("Workflow %s has been removed.", args[0])
+
+		return nil
+	},
+}
+
+// printWorkflow prints the steps of wf level by level, so that every step
+// appears below the steps it depends on.
+func printWorkflow(wf *workflow.Workflow) error {
+	fmt.Printf("Workflow: %s (%s)\n\n", wf.Name, wf.Status.State)
+
+	levels := wf.Levels()
+	idx := make([]int, len(wf.Steps))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return levels[wf.Steps[idx[a]].Name] < levels[wf.Steps[idx[b]].Name]
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 5, ' ', tabwriter.TabIndent)
+	fmt.Fprintln(w, "STEP\tSTATE\tDEPENDS ON\tTASK\tMESSAGE\t")
+	for _, i := range idx {
+		step := wf.Steps[i]
+		var ss workflow.StepStatus
+		if i < len(wf.Status.Steps) {
+			ss = wf.Status.Steps[i]
+		}
+
+		deps := "-"
+		if len(step.DependsOn) > 0 {
+			deps = strings.Join(step.DependsOn, ",")
+		}
+		taskID := "-"
+		if ss.TaskID.ID() != 0 {
+			taskID = ss.TaskID.String()
+		}
+		name := strings.Repeat("  ", levels[step.Name]) + step.Name
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", name, ss.State, deps, taskID, ss.Message)
+	}
+
+	return w.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(workflowCmd)
+	workflowCmd.PersistentFlags().StringP("manager", "m", "localhost:5555", "Manager to talk to")
+	workflowCmd.PersistentFlags().StringP("namespace", "n", namespace.Default, "Namespace to operate in")
+	workflowCmd.AddCommand(workflowRunCmd)
+	workflowRunCmd.Flags().StringP("filename", "f", "workflow.json", "Workflow specification file")
+	workflowRunCmd.Flags().Bool("wait", false, "Wait for the workflow to finish")
+	workflowCmd.AddCommand(workflowStatusCmd)
+	workflowCmd.AddCommand(workflowRmCmd)
+}
@@ -0,0 +1,90 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// 2026-01-01 is a Thursday.
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(month time.Month, day, hour, min int) time.Time {
+		return time.Date(2026, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, at(1, 1, 0, 1)},
+		{"* * * * *", from.Add(30 * time.Second), at(1, 1, 0, 1)},
+		{"*/15 * * * *", from, at(1, 1, 0, 15)},
+		{"0,30 * * * *", from, at(1, 1, 0, 30)},
+		{"5-10/2 * * * *", at(1, 1, 0, 5), at(1, 1, 0, 7)},
+		{"10/20 * * * *", at(1, 1, 0, 10), at(1, 1, 0, 30)},
+		{"0 9-17 * * MON-FRI", from, at(1, 1, 9, 0)},
+		{"0 9-17 * * MON-FRI", at(1, 2, 17, 0), at(1, 5, 9, 0)},
+		{"30 2 15 * *", from, at(1, 15, 2, 30)},
+		{"0 12 * 2-3 *", from, at(2, 1, 12, 0)},
+		{"0 12 * feb,Mar *", from, at(2, 1, 12, 0)},
+		{"0 0 * * sun", from, at(1, 4, 0, 0)},
+		{"0 0 * * 0", from, at(1, 4, 0, 0)},
+		{"0 0 * * 7", from, at(1, 4, 0, 0)},
+		// With both day fields restricted either of them matches.
+		{"0 0 13 * fri", from, at(1, 2, 0, 0)},
+		{"0 0 13 * *", from, at(1, 13, 0, 0)},
+		{"0 0 ? * fri", from, at(1, 2, 0, 0)},
+		{"@hourly", from, at(1, 1, 1, 0)},
+		{"@daily", from, at(1, 2, 0, 0)},
+		{"@weekly", from, at(1, 4, 0, 0)},
+		{"@MONTHLY", from, at(2, 1, 0, 0)},
+		{"@yearly", from, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", from, time.Time{}},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		got := s.Next(tt.from)
+		if !got.Equal(tt.want) {
+			t.Errorf("%q after %v: got %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@every 5m",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"-1 * * * *",
+		"*/0 * * * *",
+		"*/-5 * * * *",
+		"*/x * * * *",
+		"5-1 * * * *",
+		"1- * * * *",
+		"1,,2 * * * *",
+		"a * * * *",
+		"* * * foo *",
+		"* * * * mon-funday",
+		"* * * mon * ",
+	}
+	for _, expr := range tests {
+		_, err := Parse(expr)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
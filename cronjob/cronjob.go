@@ -0,0 +1,153 @@
+package cronjob
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"time"
+
+	"github.com/dev6699/cube/cron"
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// Kind is the owner kind recorded on tasks created for a cron job.
+const Kind = "CronJob"
+
+type ConcurrencyPolicy string
+
+const (
+	// Allow starts a new run even if previous runs are still active.
+	Allow ConcurrencyPolicy = "Allow"
+	// Forbid skips a run while a previous one is still active.
+	Forbid ConcurrencyPolicy = "Forbid"
+	// Replace stops active runs before starting a new one.
+	Replace ConcurrencyPolicy = "Replace"
+)
+
+const (
+	defaultSuccessfulRunsHistoryLimit = 3
+	defaultFailedRunsHistoryLimit     = 1
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// CronJob creates a task from Template every time Schedule fires in
+// TimeZone. Only the latest finished runs are kept, as configured by the
+// history limits. UID is assigned when the cron job is created and
+// recorded on its runs.
+type CronJob struct {
+	Name                       string
+	Namespace                  string
+	UID                        uuid.UUID
+	Schedule                   string
+	TimeZone                   string
+	ConcurrencyPolicy          ConcurrencyPolicy
+	Suspend                    bool
+	SuccessfulRunsHistoryLimit int
+	FailedRunsHistoryLimit     int
+	Template                   task.Task
+	CreatedAt                  time.Time
+	Status                     Status
+}
+
+type Status struct {
+	Active             []uuid.UUID
+	LastScheduleTime   time.Time
+	LastSuccessfulTime time.Time
+	NextScheduleTime   time.Time
+}
+
+func (c *CronJob) SetDefaults() {
+	if c.TimeZone == "" {
+		c.TimeZone = "UTC"
+	}
+	if c.ConcurrencyPolicy == "" {
+		c.ConcurrencyPolicy = Allow
+	}
+	if c.SuccessfulRunsHistoryLimit == 0 {
+		c.SuccessfulRunsHistoryLimit = defaultSuccessfulRunsHistoryLimit
+	}
+	if c.FailedRunsHistoryLimit == 0 {
+		c.FailedRunsHistoryLimit = defaultFailedRunsHistoryLimit
+	}
+}
+
+func (c *CronJob) Validate() error {
+	if !validName.MatchString(c.Name) {
+		return fmt.Errorf("invalid cron job name %q", c.Name)
+	}
+	if c.Template.Image == "" {
+		return fmt.Errorf("template image is required")
+	}
+	err := labels.Validate(c.Template.Labels)
+	if err != nil {
+		return err
+	}
+	if c.SuccessfulRunsHistoryLimit < 0 || c.FailedRunsHistoryLimit < 0 {
+		return fmt.Errorf("history limits must not be negative")
+	}
+	switch c.ConcurrencyPolicy {
+	case Allow, Forbid, Replace:
+	default:
+		return fmt.Errorf("invalid concurrency policy %q", c.ConcurrencyPolicy)
+	}
+
+	_, err = cron.Parse(c.Schedule)
+	if err != nil {
+		return err
+	}
+	_, err = time.LoadLocation(c.TimeZone)
+	return err
+}
+
+// Next returns the first scheduled time strictly after t.
+func (c *CronJob) Next(t time.Time) (time.Time, error) {
+	s, err := cron.Parse(c.Schedule)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := time.LoadLocation(c.TimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return s.Next(t.In(loc)), nil
+}
+
+// NewTask returns a fresh task for the run scheduled at the given time.
+func (c *CronJob) NewTask(scheduled time.Time) task.Task {
+	t := c.Template
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%d", c.Name, scheduled.Unix())
+	t.State = task.Pending
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.StartTime = time.Time{}
+	t.FinishTime = time.Time{}
+	t.RestartCount = 0
+	t.Labels = maps.Clone(c.Template.Labels)
+	t.Annotations = maps.Clone(c.Template.Annotations)
+	t.Namespace = c.Namespace
+	t.DesiredState = task.Running
+	t.Owner = &task.Owner{
+		Kind: Kind,
+		Name: c.Name,
+		UID:  c.UID,
+	}
+	return t
+}
+
+// Owns reports whether t is a run of the cron job, and not of an earlier
+// cron job of the same name.
+func (c *CronJob) Owns(t *task.Task) bool {
+	return t.Owner != nil && t.Owner.Kind == Kind && t.Owner.Name == c.Name &&
+		t.Owner.UID == c.UID && t.Namespace == c.Namespace
+}
+
+// Key returns the store key of the cron job, which is unique across namespaces.
+func (c *CronJob) Key() string {
+	return namespace.Key(c.Namespace, c.Name)
+}
@@ -0,0 +1,165 @@
+package job
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"time"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// Kind is the owner kind recorded on tasks created for a job.
+const Kind = "Job"
+
+// IndexEnv is the environment variable holding a task's completion index.
+const IndexEnv = "CUBE_JOB_INDEX"
+
+const (
+	defaultBackoffLimit = 6
+	baseBackoff         = 10 * time.Second
+	maxBackoff          = 6 * time.Minute
+)
+
+type State string
+
+const (
+	Running  State = "Running"
+	Complete State = "Complete"
+	Failed   State = "Failed"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Job runs Template until Completions indexes have succeeded, with at most
+// Parallelism tasks at a time. Failed indexes are retried with an
+// exponential backoff; the job fails once more than BackoffLimit tasks
+// have failed. A nil BackoffLimit defaults to 6; 0 fails the job on the
+// first failed task. A job still running ActiveDeadlineSeconds after it
+// started fails and its tasks are stopped; 0 means no deadline. UID is
+// assigned when the job is created and recorded on its tasks.
+type Job struct {
+	Name                  string
+	Namespace             string
+	UID                   uuid.UUID
+	Completions           int
+	Parallelism           int
+	BackoffLimit          *int
+	ActiveDeadlineSeconds int
+	Template              task.Task
+	CreatedAt             time.Time
+	Status                Status
+}
+
+type Status struct {
+	State          State
+	Active         int
+	Succeeded      int
+	Failed         int
+	Indexes        []IndexStatus
+	StartTime      time.Time
+	CompletionTime time.Time
+	Message        string
+}
+
+type IndexStatus struct {
+	Index     int
+	Succeeded bool
+	Failures  int
+	TaskID    uuid.UUID
+}
+
+func (j *Job) SetDefaults() {
+	if j.Completions == 0 {
+		j.Completions = 1
+	}
+	if j.Parallelism == 0 {
+		j.Parallelism = 1
+	}
+	if j.BackoffLimit == nil {
+		limit := defaultBackoffLimit
+		j.BackoffLimit = &limit
+	}
+}
+
+func (j *Job) Validate() error {
+	if !validName.MatchString(j.Name) {
+		return fmt.Errorf("invalid job name %q", j.Name)
+	}
+	if j.Template.Image == "" {
+		return fmt.Errorf("template image is required")
+	}
+	err := labels.Validate(j.Template.Labels)
+	if err != nil {
+		return err
+	}
+	if j.Completions < 1 || j.Parallelism < 1 {
+		return fmt.Errorf("completions and parallelism must be positive")
+	}
+	if j.BackoffLimit != nil && *j.BackoffLimit < 0 {
+		return fmt.Errorf("backoff limit must not be negative")
+	}
+	if j.ActiveDeadlineSeconds < 0 {
+		return fmt.Errorf("active deadline must not be negative")
+	}
+	return nil
+}
+
+// Finished reports whether the job has completed or failed.
+func (j *Job) Finished() bool {
+	return j.Status.State == Complete || j.Status.State == Failed
+}
+
+// NewTask returns a fresh task for the given completion index.
+func (j *Job) NewTask(index int) task.Task {
+	t := j.Template
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%d-%s", j.Name, index, t.ID.String()[:8])
+	t.Env = append(append([]string{}, j.Template.Env...), fmt.Sprintf("%s=%d", IndexEnv, index))
+	t.State = task.Pending
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.StartTime = time.Time{}
+	t.FinishTime = time.Time{}
+	t.RestartCount = 0
+	t.Labels = maps.Clone(j.Template.Labels)
+	t.Annotations = maps.Clone(j.Template.Annotations)
+	t.Namespace = j.Namespace
+	t.DesiredState = task.Running
+	t.Owner = &task.Owner{
+		Kind:  Kind,
+		Name:  j.Name,
+		UID:   j.UID,
+		Index: index,
+	}
+	return t
+}
+
+// Owns reports whether t was created for the job, and not for an earlier
+// job of the same name.
+func (j *Job) Owns(t *task.Task) bool {
+	return t.Owner != nil && t.Owner.Kind == Kind && t.Owner.Name == j.Name &&
+		t.Owner.UID == j.UID && t.Namespace == j.Namespace
+}
+
+// Backoff returns how long to wait before retrying an index that has
+// failed the given number of times.
+func Backoff(failures int) time.Duration {
+	if failures == 0 {
+		return 0
+	}
+
+	d := baseBackoff
+	for i := 1; i < failures && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// Key returns the store key of the job, which is unique across namespaces.
+func (j *Job) Key() string {
+	return namespace.Key(j.Namespace, j.Name)
+}
@@ -0,0 +1,235 @@
+// Package labels implements label selectors used to pick tasks and nodes by
+// their labels.
+//
+// A selector is a comma-separated list of requirements, all of which must
+// match:
+//
+//	app=billing            equality (== is accepted too)
+//	tier!=frontend         inequality
+//	env in (prod,staging)  set membership
+//	env notin (dev)        set exclusion
+//	canary                 the key exists
+//	!canary                the key does not exist
+package labels
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is a single condition on the value of one label key.
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+// Matches reports whether labels satisfy the requirement. Inequality and
+// exclusion match when the key is absent.
+func (r Requirement) Matches(labels map[string]string) bool {
+	v, ok := labels[r.Key]
+	switch r.Operator {
+	case Equals, In:
+		return ok && contains(r.Values, v)
+	case NotEquals, NotIn:
+		return !ok || !contains(r.Values, v)
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case Equals, NotEquals:
+		return r.Key + string(r.Operator) + r.Values[0]
+	case In, NotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	case DoesNotExist:
+		return "!" + r.Key
+	}
+	return r.Key
+}
+
+// Selector matches labels satisfying all of its requirements. The empty
+// selector matches everything.
+type Selector []Requirement
+
+// Matches reports whether labels satisfy every requirement of s.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s {
+		if !r.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty reports whether s has no requirements.
+func (s Selector) Empty() bool {
+	return len(s) == 0
+}
+
+func (s Selector) String() string {
+	parts := make([]string, len(s))
+	for i, r := range s {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// Parse parses a selector expression such as "app=billing,env in (prod)".
+func Parse(expr string) (Selector, error) {
+	var s Selector
+	for _, part := range split(expr) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("empty requirement in selector %q", expr)
+		}
+
+		r, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+		s = append(s, r)
+	}
+	return s, nil
+}
+
+// split splits expr on the commas that are not inside a value set.
+func split(expr string) []string {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+
+	var parts []string
+	depth, start := 0, 0
+	for i, c := range expr {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, expr[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, expr[start:])
+}
+
+func parseRequirement(s string) (Requirement, error) {
+	if i := strings.Index(s, "("); i >= 0 {
+		fields := strings.Fields(s[:i])
+		if len(fields) != 2 || !strings.HasSuffix(s, ")") {
+			return Requirement{}, fmt.Errorf("invalid set requirement %q", s)
+		}
+
+		op := Operator(fields[1])
+		if op != In && op != NotIn {
+			return Requirement{}, fmt.Errorf("invalid operator %q in %q", fields[1], s)
+		}
+
+		var values []string
+		for _, v := range strings.Split(s[i+1:len(s)-1], ",") {
+			v = strings.TrimSpace(v)
+			if err := validate(v, true); err != nil {
+				return Requirement{}, err
+			}
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		return newRequirement(fields[0], op, values)
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		if key, value, ok := strings.Cut(s, op); ok {
+			value = strings.TrimSpace(value)
+			if err := validate(value, true); err != nil {
+				return Requirement{}, err
+			}
+			o := Operator(op)
+			if o == "==" {
+				o = Equals
+			}
+			return newRequirement(strings.TrimSpace(key), o, []string{value})
+		}
+	}
+
+	if strings.ContainsAny(s, " \t") {
+		return Requirement{}, fmt.Errorf("invalid requirement %q", s)
+	}
+	if strings.HasPrefix(s, "!") {
+		return newRequirement(strings.TrimSpace(s[1:]), DoesNotExist, nil)
+	}
+	return newRequirement(s, Exists, nil)
+}
+
+func newRequirement(key string, op Operator, values []string) (Requirement, error) {
+	if err := validate(key, false); err != nil {
+		return Requirement{}, err
+	}
+	return Requirement{Key: key, Operator: op, Values: values}, nil
+}
+
+// Validate checks that the keys and values of labels can be used in a
+// selector.
+func Validate(labels map[string]string) error {
+	for k, v := range labels {
+		if err := validate(k, false); err != nil {
+			return err
+		}
+		if err := validate(v, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate accepts alphanumerics and '-', '_', '.' and '/'. Only values may
+// be empty.
+func validate(s string, value bool) error {
+	if s == "" {
+		if value {
+			return nil
+		}
+		return fmt.Errorf("label key must not be empty")
+	}
+	if len(s) > 253 {
+		return fmt.Errorf("label %q is longer than 253 characters", s)
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == '/':
+		default:
+			return fmt.Errorf("invalid character %q in label %q", c, s)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+package labels
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"", ""},
+		{"app=billing", "app=billing"},
+		{"app == billing", "app=billing"},
+		{"tier!=frontend", "tier!=frontend"},
+		{"env in (staging, prod)", "env in (prod,staging)"},
+		{"env notin (dev)", "env notin (dev)"},
+		{"canary", "canary"},
+		{"!canary", "!canary"},
+		{"app=billing, env in (prod,staging), !canary", "app=billing,env in (prod,staging),!canary"},
+		{"example.com/team=infra", "example.com/team=infra"},
+		{"app=", "app="},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.String(); got != tt.want {
+			t.Errorf("Parse(%q): got %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		",",
+		"app=billing,",
+		"app=billing,,tier=web",
+		"=billing",
+		"app=bil ling",
+		"app=b=c",
+		"app billing",
+		"!",
+		"! canary",
+		"env in prod",
+		"env in (prod",
+		"env within (prod)",
+		"in (prod)",
+		"env in (pr*d)",
+		"app$=billing",
+	}
+	for _, expr := range tests {
+		_, err := Parse(expr)
+		if err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", expr)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	labels := map[string]string{"app": "billing", "env": "prod", "canary": ""}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"app=billing", true},
+		{"app=web", false},
+		{"app!=web", true},
+		{"app!=billing", false},
+		{"tier!=frontend", true},
+		{"env in (prod,staging)", true},
+		{"env in (dev)", false},
+		{"tier in (frontend)", false},
+		{"env notin (dev)", true},
+		{"env notin (prod)", false},
+		{"tier notin (frontend)", true},
+		{"canary", true},
+		{"tier", false},
+		{"!tier", true},
+		{"!canary", false},
+		{"canary=", true},
+		{"app=billing,env=prod", true},
+		{"app=billing,env=dev", false},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := s.Matches(labels); got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		labels map[string]string
+		valid  bool
+	}{
+		{map[string]string{"app": "billing", "example.com/team": ""}, true},
+		{map[string]string{"": "billing"}, false},
+		{map[string]string{"app name": "billing"}, false},
+		{map[string]string{"app": "bill,ing"}, false},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.labels)
+		if (err == nil) != tt.valid {
+			t.Errorf("Validate(%v): got error %v, want valid %v", tt.labels, err, tt.valid)
+		}
+	}
+}
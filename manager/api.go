@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"github.com/dev6699/cube/cluster"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -12,6 +13,14 @@ type Api struct {
 	Port    int
 	Manager *Manager
 	Router  *chi.Mux
+	// Cluster is set when the manager is one of several replicas.
+	// Requests to a follower are then proxied to the leader.
+	Cluster *cluster.Cluster
+	// Auth makes every request carry the secret of one of the manager's
+	// tokens. Workers and the other replicas present ClusterToken, which
+	// is only accepted on the routes they use.
+	Auth         bool
+	ClusterToken string
 }
 
 func NewApi(address string, port int, manager *Manager) *Api {
@@ -30,10 +39,93 @@ func (a *Api) Start() error {
 
 func (a *Api) initRouter() {
 	a.Router = chi.NewRouter()
-	a.Router.Route("/tasks", func(r chi.Router) {
+	if a.Auth {
+		a.Router.Use(a.authenticate)
+	}
+	if a.Cluster != nil {
+		a.Router.Mount("/cluster", a.Cluster.Handler())
+	}
+
+	a.Router.Group(func(r chi.Router) {
+		r.Use(a.forwardToLeader)
+		// Namespaced resources are served both under /namespaces/{namespace}
+		// and, for the default namespace, at the top level.
+		a.namespacedRoutes(r)
+		r.Route("/namespaces", func(r chi.Router) {
+			r.Post("/", a.CreateNamespaceHandler)
+			r.Get("/", a.GetNamespacesHandler)
+			r.Route("/{namespace}", func(r chi.Router) {
+				r.Use(a.namespaceCtx)
+				r.Get("/", a.GetNamespaceHandler)
+				r.Put("/", a.UpdateNamespaceHandler)
+				r.Delete("/", a.DeleteNamespaceHandler)
+				a.namespacedRoutes(r)
+			})
+		})
+		r.Route("/nodes", func(r chi.Router) {
+			r.Post("/", a.RegisterNodeHandler)
+			r.Get("/", a.GetNodesHandler)
+			r.Patch("/{name}", a.PatchNodeHandler)
+			r.Put("/{name}/heartbeat", a.HeartbeatHandler)
+			r.Post("/{name}/cordon", a.CordonNodeHandler)
+			r.Post("/{name}/uncordon", a.UncordonNodeHandler)
+			r.Post("/{name}/drain", a.DrainNodeHandler)
+		})
+		r.Route("/tokens", func(r chi.Router) {
+			r.Post("/", a.CreateTokenHandler)
+			r.Get("/", a.GetTokensHandler)
+			r.Delete("/{name}", a.DeleteTokenHandler)
+		})
+		r.Get("/watch", a.WatchHandler)
+		r.Route("/v1", a.v1Routes)
+	})
+}
+
+func (a *Api) namespacedRoutes(r chi.Router) {
+	r.Route("/tasks", func(r chi.Router) {
 		r.Post("/", a.StartTaskHandler)
 		r.Get("/", a.GetTasksHandler)
+		r.Get("/{taskID}", a.GetTaskHandler)
 		r.Delete("/{taskID}", a.StopTaskHandler)
 	})
-	a.Router.Get("/nodes", a.GetNodesHandler)
+	r.Route("/deadletters", func(r chi.Router) {
+		r.Get("/", a.GetDeadLettersHandler)
+		r.Post("/{taskID}/retry", a.RetryDeadLetterHandler)
+		r.Delete("/{taskID}", a.DeleteDeadLetterHandler)
+	})
+	r.Route("/services", func(r chi.Router) {
+		r.Post("/", a.CreateServiceHandler)
+		r.Get("/", a.GetServicesHandler)
+		r.Get("/{name}", a.GetServiceHandler)
+		r.Put("/{name}", a.UpdateServiceHandler)
+		r.Put("/{name}/scale", a.ScaleServiceHandler)
+		r.Delete("/{name}", a.DeleteServiceHandler)
+		r.Get("/{name}/revisions", a.GetServiceRevisionsHandler)
+		r.Post("/{name}/rollback", a.RollbackServiceHandler)
+		r.Post("/{name}/pause", a.PauseServiceHandler)
+		r.Post("/{name}/resume", a.ResumeServiceHandler)
+		r.Put("/{name}/autoscale", a.SetServiceAutoscaleHandler)
+		r.Delete("/{name}/autoscale", a.DeleteServiceAutoscaleHandler)
+	})
+	r.Route("/cronjobs", func(r chi.Router) {
+		r.Post("/", a.CreateCronJobHandler)
+		r.Get("/", a.GetCronJobsHandler)
+		r.Get("/{name}", a.GetCronJobHandler)
+		r.Put("/{name}", a.UpdateCronJobHandler)
+		r.Delete("/{name}", a.DeleteCronJobHandler)
+		r.Get("/{name}/runs", a.GetCronJobRunsHandler)
+	})
+	r.Route("/jobs", func(r chi.Router) {
+		r.Post("/", a.CreateJobHandler)
+		r.Get("/", a.GetJobsHandler)
+		r.Get("/{name}", a.GetJobHandler)
+		r.Delete("/{name}", a.DeleteJobHandler)
+		r.Get("/{name}/tasks", a.GetJobTasksHandler)
+	})
+	r.Route("/workflows", func(r chi.Router) {
+		r.Post("/", a.CreateWorkflowHandler)
+		r.Get("/", a.GetWorkflowsHandler)
+		r.Get("/{name}", a.GetWorkflowHandler)
+		r.Delete("/{name}", a.DeleteWorkflowHandler)
+	})
 }
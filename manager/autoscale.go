@@ -0,0 +1,220 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// autoscaleTolerance is how far the utilization ratio may drift from 1
+// before the autoscaler recommends a change.
+const autoscaleTolerance = 0.1
+
+type recommendation struct {
+	replicas int
+	time     time.Time
+}
+
+// SetServiceAutoscale enables autoscaling of a service, or disables it when
+// a is nil.
+func (m *Manager) SetServiceAutoscale(ns string, name string, a *service.Autoscale) (*service.Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if a != nil {
+		a.SetDefaults()
+		err := a.Validate()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := m.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Autoscale = a
+	delete(m.recommendations, s.Key())
+	err = m.ServiceDb.Put(s.Key(), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Autoscale periodically samples task usage from the workers and adjusts
+// the replicas of autoscaled services.
+func (m *Manager) Autoscale(ctx context.Context) error {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			log.Println("[manager] autoscaling services")
+			m.collectTaskUsage()
+			m.autoscaleServices()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Manager) collectTaskUsage() {
+	usage := make(map[uuid.UUID]task.Usage)
+	for _, n := range m.GetNodes(labels.Selector{}) {
+		url := fmt.Sprintf("%s/tasks/stats", n.Api)
+		resp, err := m.Client.Get(url)
+		if err != nil {
+			log.Printf("[manager] error collecting task usage from %s: %v\n", n.Name, err)
+			continue
+		}
+
+		var samples []task.Usage
+		if resp.StatusCode == http.StatusOK {
+			err = json.NewDecoder(resp.Body).Decode(&samples)
+		} else {
+			err = fmt.Errorf("invalid status code: %v", resp.StatusCode)
+		}
+		resp.Body.Close()
+		if err != nil {
+			log.Printf("[manager] error collecting task usage from %s: %v\n", n.Name, err)
+			continue
+		}
+
+		for _, u := range samples {
+			usage[u.TaskID] = u
+		}
+	}
+
+	m.mu.Lock()
+	m.taskUsage = usage
+	m.mu.Unlock()
+}
+
+func (m *Manager) autoscaleServices() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := m.GetTasks()
+	services, err := m.ServiceDb.List()
+	if err != nil {
+		log.Printf("[manager] error listing services: %v\n", err)
+		return
+	}
+	for _, s := range services {
+		if s.Autoscale == nil {
+			continue
+		}
+
+		err := m.autoscaleService(s, tasks, time.Now())
+		if err != nil {
+			log.Printf("[manager] error autoscaling service %s: %v\n", s.Name, err)
+		}
+	}
+}
+
+func (m *Manager) autoscaleService(s *service.Service, tasks []*task.Task, now time.Time) error {
+	a := s.Autoscale
+
+	var total float64
+	samples := 0
+	for _, t := range tasks {
+		if !s.Owns(t) || t.DesiredState == task.Completed || t.State != task.Running {
+			continue
+		}
+
+		u, ok := m.taskUsage[t.ID]
+		if !ok {
+			continue
+		}
+		total += a.Utilization(t, u)
+		samples++
+	}
+
+	desired := s.Replicas
+	utilization := s.Status.Utilization
+	if samples > 0 {
+		avg := total / float64(samples)
+		utilization = int(math.Round(avg))
+
+		ratio := avg / float64(a.TargetUtilization)
+		if math.Abs(ratio-1) > autoscaleTolerance {
+			desired = int(math.Ceil(float64(s.Replicas) * ratio))
+		}
+	}
+	desired = min(max(desired, a.MinReplicas), a.MaxReplicas)
+
+	replicas := m.stabilize(s, desired, now)
+	if replicas == s.Replicas && utilization == s.Status.Utilization {
+		return nil
+	}
+
+	latest, err := m.ServiceDb.Get(s.Key())
+	if err != nil {
+		return err
+	}
+
+	latest.Status.Utilization = utilization
+	if replicas != latest.Replicas {
+		log.Printf("[manager] service %s: autoscaling from %d to %d replicas at %d%% %s utilization\n",
+			s.Name, latest.Replicas, replicas, utilization, a.Metric)
+		latest.Replicas = replicas
+		latest.Status.LastScaleTime = now.UTC()
+	}
+	return m.ServiceDb.Put(latest.Key(), latest)
+}
+
+// stabilize records desired as the latest recommendation for s and returns
+// the replica count to apply. Replica counts outside the autoscale bounds
+// are corrected immediately.
+func (m *Manager) stabilize(s *service.Service, desired int, now time.Time) int {
+	a := s.Autoscale
+	upWindow := time.Duration(a.ScaleUpStabilizationSeconds) * time.Second
+	downWindow := time.Duration(*a.ScaleDownStabilizationSeconds) * time.Second
+
+	recs := []recommendation{{replicas: desired, time: now}}
+	for _, r := range m.recommendations[s.Key()] {
+		if now.Sub(r.time) <= max(upWindow, downWindow) {
+			recs = append(recs, r)
+		}
+	}
+	m.recommendations[s.Key()] = recs
+
+	if s.Replicas < a.MinReplicas || s.Replicas > a.MaxReplicas {
+		return desired
+	}
+
+	replicas := desired
+	if desired > s.Replicas {
+		for _, r := range recs {
+			if now.Sub(r.time) <= upWindow {
+				replicas = min(replicas, r.replicas)
+			}
+		}
+		return max(replicas, s.Replicas)
+	}
+
+	if desired < s.Replicas {
+		for _, r := range recs {
+			if now.Sub(r.time) <= downWindow {
+				replicas = max(replicas, r.replicas)
+			}
+		}
+		return min(replicas, s.Replicas)
+	}
+
+	return replicas
+}
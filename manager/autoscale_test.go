@@ -0,0 +1,113 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/task"
+)
+
+// newAutoscaleTest creates a service with the given replicas running and
+// autoscaling by cpu at a target of 50%.
+func newAutoscaleTest(t *testing.T, replicas int, a service.Autoscale) (*Manager, *service.Service) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Metric = service.MetricCpu
+	a.TargetUtilization = 50
+	s := &service.Service{
+		Name:      "web",
+		Namespace: namespace.Default,
+		Replicas:  replicas,
+		Template:  task.Task{Image: "nginx", Cpu: 1},
+		Autoscale: &a,
+	}
+	err = m.CreateService(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < replicas; i++ {
+		tk := s.NewTask()
+		tk.State = task.Running
+		err = m.TaskDb.Put(tk.ID.String(), &tk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m, s
+}
+
+// autoscaleAt runs the autoscaler at now with every replica of s using cpu
+// cores, and returns the replicas it settled on.
+func autoscaleAt(t *testing.T, m *Manager, s *service.Service, cpu float64, now time.Time) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := m.GetTasks()
+	for _, tk := range tasks {
+		m.taskUsage[tk.ID] = task.Usage{TaskID: tk.ID, Cpu: cpu}
+	}
+	latest := getService(t, m, s)
+	err := m.autoscaleService(latest, tasks, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return getService(t, m, s).Replicas
+}
+
+func TestAutoscaleStabilizesScaleDown(t *testing.T) {
+	window := 60
+	m, s := newAutoscaleTest(t, 4, service.Autoscale{
+		MinReplicas:                   1,
+		MaxReplicas:                   10,
+		ScaleDownStabilizationSeconds: &window,
+	})
+
+	start := time.Now()
+	if got := autoscaleAt(t, m, s, 0.5, start); got != 4 {
+		t.Fatalf("got %d replicas on target, want 4", got)
+	}
+	// Load halves, but 4 replicas were recommended within the window.
+	if got := autoscaleAt(t, m, s, 0.25, start.Add(30*time.Second)); got != 4 {
+		t.Errorf("got %d replicas inside the scale down window, want 4", got)
+	}
+	if got := autoscaleAt(t, m, s, 0.25, start.Add(70*time.Second)); got != 2 {
+		t.Errorf("got %d replicas after the scale down window, want 2", got)
+	}
+}
+
+func TestAutoscaleClampsReplicas(t *testing.T) {
+	window := 300
+	m, s := newAutoscaleTest(t, 3, service.Autoscale{
+		MinReplicas:                   2,
+		MaxReplicas:                   5,
+		ScaleDownStabilizationSeconds: &window,
+	})
+
+	now := time.Now()
+	// Twice the target asks for 6 replicas.
+	if got := autoscaleAt(t, m, s, 1, now); got != 5 {
+		t.Errorf("got %d replicas under heavy load, want the maximum of 5", got)
+	}
+
+	// Lowering the maximum takes effect at once.
+	a := *s.Autoscale
+	a.MaxReplicas = 3
+	_, err := m.SetServiceAutoscale(s.Namespace, s.Name, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	if got := autoscaleAt(t, m, s, 0.5, now); got != 3 {
+		t.Errorf("got %d replicas above a lowered maximum, want 3", got)
+	}
+
+	// Idle replicas scale down no further than the minimum.
+	now = now.Add(time.Duration(window+1) * time.Second)
+	if got := autoscaleAt(t, m, s, 0, now); got != 2 {
+		t.Errorf("got %d replicas when idle, want the minimum of 2", got)
+	}
+}
@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/cluster"
+	"github.com/dev6699/cube/queue"
+	"github.com/google/uuid"
+)
+
+// forwardedHeader marks requests a follower proxied to the leader, so that
+// a request is never proxied twice while the members disagree on the
+// leader.
+const forwardedHeader = "X-Cube-Forwarded-By"
+
+// Replicate makes the stores of m part of c, so that followers keep
+// replicas of the leader's stores. It must be called before m is used.
+func (m *Manager) Replicate(c *cluster.Cluster) {
+	m.TaskDb = cluster.Replicate(c, "tasks", m.TaskDb)
+	m.EventDb = cluster.Replicate(c, "events", m.EventDb)
+	m.ServiceDb = cluster.Replicate(c, "services", m.ServiceDb)
+	m.CronJobDb = cluster.Replicate(c, "cronjobs", m.CronJobDb)
+	m.JobDb = cluster.Replicate(c, "jobs", m.JobDb)
+	m.WorkflowDb = cluster.Replicate(c, "workflows", m.WorkflowDb)
+	m.NamespaceDb = cluster.Replicate(c, "namespaces", m.NamespaceDb)
+	m.DeadLetterDb = cluster.Replicate(c, "deadletters", m.DeadLetterDb)
+	m.SubmissionDb = cluster.Replicate(c, "submissions", m.SubmissionDb)
+	m.TokenDb = cluster.Replicate(c, "tokens", m.TokenDb)
+}
+
+// Restore rebuilds the state m keeps in memory from its stores. A manager
+// that takes over as leader calls it before running the control loops, as
+// its stores were last written by the previous leader. Pending events are
+// recreated from the tasks.
+func (m *Manager) Restore() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		_, err := m.Pending.Dequeue()
+		if errors.Is(err, queue.ErrEmpty) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	m.TaskWorkerMap = make(map[uuid.UUID]string)
+	for w := range m.WorkerTaskMap {
+		m.WorkerTaskMap[w] = []uuid.UUID{}
+	}
+	clear(m.fenced)
+	clear(m.drifts)
+	clear(m.dispatching)
+	clear(m.leases)
+
+	err := m.restoreAssignments()
+	if err != nil {
+		return err
+	}
+	return m.recoverPending()
+}
+
+// forwardToLeader proxies requests to the leader while the manager follows
+// in a cluster. Only the leader runs the control loops and polls the
+// nodes, so followers answer nothing but the cluster endpoints themselves.
+func (a *Api) forwardToLeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.Cluster == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		leader, self := a.Cluster.Leader()
+		if self {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if leader == "" || r.Header.Get(forwardedHeader) != "" {
+			respondProxyError(w, r, http.StatusServiceUnavailable, "no leader is elected, try again later")
+			return
+		}
+
+		proxy := &httputil.ReverseProxy{
+			Rewrite: func(pr *httputil.ProxyRequest) {
+				pr.SetURL(&url.URL{Scheme: "http", Host: leader})
+				pr.Out.Header.Set(forwardedHeader, a.Cluster.ID)
+			},
+			// Watch streams are passed on as they are written.
+			FlushInterval: -1,
+			ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+				respondProxyError(w, r, http.StatusBadGateway, fmt.Sprintf("error forwarding to leader %s: %v", leader, err))
+			},
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// respondProxyError responds with an error in the format of the API
+// version r was sent to.
+func respondProxyError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	if strings.HasPrefix(r.URL.Path, "/v1/") {
+		v1.RespondError(w, v1.NewError(status, message))
+		return
+	}
+	respondError(w, status, message)
+}
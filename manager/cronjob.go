@@ -0,0 +1,346 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/cronjob"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCronJobExists = errors.New("cron job already exists")
+)
+
+func (m *Manager) CreateCronJob(c *cronjob.CronJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c.SetDefaults()
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = m.CronJobDb.Get(c.Key())
+	if err == nil {
+		return ErrCronJobExists
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	c.UID = uuid.New()
+	c.CreatedAt = time.Now().UTC()
+	c.Status = cronjob.Status{}
+	next, err := c.Next(c.CreatedAt)
+	if err != nil {
+		return err
+	}
+	c.Status.NextScheduleTime = next.UTC()
+	return m.CronJobDb.Put(c.Key(), c)
+}
+
+// UpdateCronJob replaces the specification of a cron job, keeping its
+// status and run history.
+func (m *Manager) UpdateCronJob(spec *cronjob.CronJob) (*cronjob.CronJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.CronJobDb.Get(spec.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	spec.SetDefaults()
+	err = spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	spec.UID = c.UID
+	spec.CreatedAt = c.CreatedAt
+	spec.Status = c.Status
+	next, err := spec.Next(m.lastCronSchedule(c))
+	if err != nil {
+		return nil, err
+	}
+	spec.Status.NextScheduleTime = next.UTC()
+
+	err = m.CronJobDb.Put(spec.Key(), spec)
+	if err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// GetCronJobs returns the cron jobs of namespace ns.
+func (m *Manager) GetCronJobs(ns string) []*cronjob.CronJob {
+	all, err := m.CronJobDb.List()
+	if err != nil {
+		return []*cronjob.CronJob{}
+	}
+
+	cronJobs := []*cronjob.CronJob{}
+	for _, c := range all {
+		if c.Namespace == ns {
+			cronJobs = append(cronJobs, c)
+		}
+	}
+
+	sort.Slice(cronJobs, func(i, j int) bool {
+		return cronJobs[i].Name < cronJobs[j].Name
+	})
+	return cronJobs
+}
+
+// GetCronJobRuns returns the tasks created by a cron job, newest first.
+func (m *Manager) GetCronJobRuns(ns string, name string) ([]*task.Task, error) {
+	c, err := m.CronJobDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return nil, err
+	}
+
+	runs := []*task.Task{}
+	for _, t := range m.GetTasks() {
+		if c.Owns(t) {
+			runs = append(runs, t)
+		}
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+// DeleteCronJob stops the active runs of a cron job and removes it.
+func (m *Manager) DeleteCronJob(ns string, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, err := m.CronJobDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range m.GetTasks() {
+		if !c.Owns(t) || t.DesiredState == task.Completed || !isActive(t) {
+			continue
+		}
+
+		err := m.requestStop(t.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return m.CronJobDb.Delete(namespace.Key(ns, name))
+}
+
+// RunCronJobs starts the runs of cron jobs as they fall due and prunes
+// their run history.
+func (m *Manager) RunCronJobs(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.runCronJobs()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Manager) runCronJobs() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := m.GetTasks()
+	cronJobs, err := m.CronJobDb.List()
+	if err != nil {
+		log.Printf("[manager] error listing cron jobs: %v\n", err)
+		return
+	}
+	for _, c := range cronJobs {
+		err := m.runCronJob(c, tasks, time.Now())
+		if err != nil {
+			log.Printf("[manager] error running cron job %s: %v\n", c.Name, err)
+		}
+	}
+}
+
+func (m *Manager) runCronJob(c *cronjob.CronJob, tasks []*task.Task, now time.Time) error {
+	var active, succeeded, failed []*task.Task
+	for _, t := range tasks {
+		if !c.Owns(t) {
+			continue
+		}
+
+		switch {
+		case isActive(t) && t.DesiredState != task.Completed:
+			active = append(active, t)
+		case t.State == task.Completed:
+			// Runs that were stopped, by the user or the Replace policy,
+			// did not fail. They are kept with the successful runs but
+			// do not count as a success.
+			succeeded = append(succeeded, t)
+		case !isActive(t):
+			failed = append(failed, t)
+		}
+	}
+
+	status := c.Status
+	next, err := c.Next(m.lastCronSchedule(c))
+	if err != nil {
+		return err
+	}
+
+	if !next.IsZero() && !next.After(now) && !c.Suspend {
+		// Missed runs, e.g. while the manager was down, collapse into one.
+		scheduled := next
+		for {
+			n, err := c.Next(scheduled)
+			if err != nil {
+				return err
+			}
+			if n.IsZero() || n.After(now) {
+				break
+			}
+			scheduled = n
+		}
+
+		status.LastScheduleTime = scheduled.UTC()
+		active, err = m.startCronRun(c, active, scheduled)
+		if err != nil {
+			return err
+		}
+	}
+
+	succeeded, err = m.pruneRuns(succeeded, c.SuccessfulRunsHistoryLimit)
+	if err != nil {
+		return err
+	}
+	_, err = m.pruneRuns(failed, c.FailedRunsHistoryLimit)
+	if err != nil {
+		return err
+	}
+
+	status.Active = []uuid.UUID{}
+	for _, t := range active {
+		status.Active = append(status.Active, t.ID)
+	}
+	for _, t := range succeeded {
+		if t.DesiredState != task.Completed && t.FinishTime.After(status.LastSuccessfulTime) {
+			status.LastSuccessfulTime = t.FinishTime
+		}
+	}
+	next, err = c.Next(maxTime(status.LastScheduleTime, now))
+	if err != nil {
+		return err
+	}
+	status.NextScheduleTime = next.UTC()
+
+	if reflect.DeepEqual(status, c.Status) {
+		return nil
+	}
+
+	latest, err := m.CronJobDb.Get(c.Key())
+	if err != nil {
+		return err
+	}
+	latest.Status = status
+	return m.CronJobDb.Put(latest.Key(), latest)
+}
+
+// startCronRun applies the concurrency policy and creates the run scheduled
+// at the given time. It returns the runs that remain active.
+func (m *Manager) startCronRun(c *cronjob.CronJob, active []*task.Task, scheduled time.Time) ([]*task.Task, error) {
+	if len(active) > 0 {
+		switch c.ConcurrencyPolicy {
+		case cronjob.Forbid:
+			log.Printf("[manager] cron job %s: skipping run at %v, %d runs still active\n", c.Name, scheduled, len(active))
+			return active, nil
+
+		case cronjob.Replace:
+			for _, t := range active {
+				log.Printf("[manager] cron job %s: replacing run %s\n", c.Name, t.ID)
+				err := m.requestStop(t.ID)
+				if err != nil {
+					return active, err
+				}
+			}
+			active = nil
+		}
+	}
+
+	t := c.NewTask(scheduled)
+	err := m.admit(&t)
+	if err != nil {
+		log.Printf("[manager] cron job %s: skipping run at %v: %v\n", c.Name, scheduled, err)
+		return active, nil
+	}
+
+	log.Printf("[manager] cron job %s: starting run %s scheduled at %v\n", c.Name, t.ID, scheduled)
+	err = m.addTask(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      t,
+	})
+	if err != nil {
+		return active, err
+	}
+	return append(active, &t), nil
+}
+
+// pruneRuns deletes all but the newest limit runs and returns the ones kept.
+func (m *Manager) pruneRuns(runs []*task.Task, limit int) ([]*task.Task, error) {
+	sortRuns(runs)
+	if len(runs) <= limit {
+		return runs, nil
+	}
+
+	for _, t := range runs[limit:] {
+		err := m.deleteTask(t)
+		if err != nil {
+			return runs, err
+		}
+	}
+	return runs[:limit], nil
+}
+
+func (m *Manager) lastCronSchedule(c *cronjob.CronJob) time.Time {
+	if c.Status.LastScheduleTime.IsZero() {
+		return c.CreatedAt
+	}
+	return c.Status.LastScheduleTime
+}
+
+// sortRuns orders runs from newest to oldest.
+func sortRuns(runs []*task.Task) {
+	sort.Slice(runs, func(i, j int) bool {
+		return runStarted(runs[i]).After(runStarted(runs[j]))
+	})
+}
+
+func runStarted(t *task.Task) time.Time {
+	if t.StartTime.IsZero() {
+		return t.FinishTime
+	}
+	return t.StartTime
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,114 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dev6699/cube/cronjob"
+	"github.com/dev6699/cube/namespace"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *Api) CreateCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var c cronjob.CronJob
+	err := d.Decode(&c)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	err = setNamespace(r, &c.Namespace)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = a.Manager.CreateCronJob(&c)
+	if errors.Is(err, ErrCronJobExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, c)
+}
+
+func (a *Api) GetCronJobsHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, a.Manager.GetCronJobs(namespaceParam(r)))
+}
+
+func (a *Api) GetCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	c, err := a.Manager.CronJobDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no cron job with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, c)
+}
+
+func (a *Api) UpdateCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var spec cronjob.CronJob
+	err := d.Decode(&spec)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+	if spec.Name == "" {
+		spec.Name = name
+	}
+	if spec.Name != name {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("cron job name %q does not match %q", spec.Name, name))
+		return
+	}
+
+	err = setNamespace(r, &spec.Namespace)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c, err := a.Manager.UpdateCronJob(&spec)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no cron job with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, c)
+}
+
+func (a *Api) GetCronJobRunsHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	runs, err := a.Manager.GetCronJobRuns(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no cron job with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, runs)
+}
+
+func (a *Api) DeleteCronJobHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	err := a.Manager.DeleteCronJob(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no cron job with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
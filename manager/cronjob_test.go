@@ -0,0 +1,149 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/cronjob"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+)
+
+func TestStoppedCronRunIsNotFailed(t *testing.T) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	c := &cronjob.CronJob{
+		Name:                       "nightly",
+		Namespace:                  namespace.Default,
+		Schedule:                   "0 0 1 1 *",
+		TimeZone:                   "UTC",
+		ConcurrencyPolicy:          cronjob.Allow,
+		SuccessfulRunsHistoryLimit: 1,
+		FailedRunsHistoryLimit:     1,
+		Template:                   task.Task{Image: "alpine"},
+		CreatedAt:                  now,
+	}
+	err = m.CronJobDb.Put(c.Key(), c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := c.NewTask(now.Add(-2 * time.Hour))
+	failed.State = task.Failed
+	failed.FinishTime = now.Add(-2 * time.Hour)
+	stopped := c.NewTask(now.Add(-time.Hour))
+	stopped.State = task.Completed
+	stopped.DesiredState = task.Completed
+	stopped.FinishTime = now.Add(-time.Hour)
+	tasks := []*task.Task{&failed, &stopped}
+	for _, tk := range tasks {
+		err := m.TaskDb.Put(tk.ID.String(), tk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.mu.Lock()
+	err = m.runCronJob(c, tasks, now)
+	m.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tk := range tasks {
+		_, err := m.TaskDb.Get(tk.ID.String())
+		if err != nil {
+			t.Errorf("run %s in state %v was pruned: %v", tk.ID, tk.State, err)
+		}
+	}
+	latest, err := m.CronJobDb.Get(c.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !latest.Status.LastSuccessfulTime.IsZero() {
+		t.Errorf("the stopped run was counted as a success at %v", latest.Status.LastSuccessfulTime)
+	}
+}
+
+func TestPrunedCronRunIsUnassigned(t *testing.T) {
+	m, err := New([]string{"worker-0"}, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cronjob.CronJob{Name: "nightly", Namespace: namespace.Default}
+	var runs []*task.Task
+	for i := 0; i < 2; i++ {
+		run := c.NewTask(time.Now().Add(-time.Duration(i) * time.Hour))
+		run.State = task.Failed
+		run.StartTime = time.Now().Add(-time.Duration(i) * time.Hour)
+		m.assignTask(&run, "worker-0")
+		err := m.TaskDb.Put(run.ID.String(), &run)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, &run)
+	}
+	pruned := runs[1]
+
+	m.mu.Lock()
+	_, err = m.pruneRuns(runs, 1)
+	m.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w, ok := m.TaskWorkerMap[pruned.ID]; ok {
+		t.Errorf("pruned run is still mapped to worker %s", w)
+	}
+	if ids := m.WorkerTaskMap["worker-0"]; len(ids) != 1 || ids[0] != runs[0].ID {
+		t.Errorf("got tasks %v on worker-0, want only %s", ids, runs[0].ID)
+	}
+}
+
+func TestRecreatedCronJobHasNoRuns(t *testing.T) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newCronJob := func() *cronjob.CronJob {
+		return &cronjob.CronJob{
+			Name:      "nightly",
+			Namespace: namespace.Default,
+			Schedule:  "0 0 1 1 *",
+			Template:  task.Task{Image: "alpine"},
+		}
+	}
+	old := newCronJob()
+	err = m.CreateCronJob(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := old.NewTask(time.Now())
+	run.State = task.Failed
+	err = m.TaskDb.Put(run.ID.String(), &run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.DeleteCronJob(old.Namespace, old.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.CreateCronJob(newCronJob())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs, err := m.GetCronJobRuns(old.Namespace, old.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("got %d runs of the new cron job, want the old run left out", len(runs))
+	}
+}
@@ -0,0 +1,90 @@
+package manager
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// deadLetter gives up on delivering t, whose last attempt te failed with
+// cause. The task is marked Failed and kept in the dead letters until it is
+// retried or discarded. m.mu must be held.
+func (m *Manager) deadLetter(t *task.Task, te task.TaskEvent, cause error) error {
+	log.Printf("[manager] giving up on task %s after %d failed attempts: %v\n", t.ID, te.Attempts, cause)
+
+	t.State = task.Failed
+	t.FinishTime = time.Now().UTC()
+	err := m.DeadLetterDb.Put(t.ID.String(), &task.DeadLetter{
+		Task:          *t,
+		Attempts:      te.Attempts,
+		FailedWorkers: te.FailedWorkers,
+		Error:         cause.Error(),
+		Timestamp:     time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return m.saveTask(t)
+}
+
+// isDeadLetter reports whether the task with the given ID is in the dead
+// letters.
+func (m *Manager) isDeadLetter(id uuid.UUID) bool {
+	_, err := m.DeadLetterDb.Get(id.String())
+	return err == nil
+}
+
+// GetDeadLetters returns the dead letters of the tasks in namespace ns,
+// oldest first.
+func (m *Manager) GetDeadLetters(ns string) ([]*task.DeadLetter, error) {
+	all, err := m.DeadLetterDb.List()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := []*task.DeadLetter{}
+	for _, dl := range all {
+		if dl.Task.Namespace == ns {
+			letters = append(letters, dl)
+		}
+	}
+	slices.SortFunc(letters, func(a, b *task.DeadLetter) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	return letters, nil
+}
+
+// RetryDeadLetter removes the task with the given ID from the dead letters
+// and hands it to the scheduler again with a fresh set of attempts.
+func (m *Manager) RetryDeadLetter(id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.DeadLetterDb.Delete(id.String())
+	if err != nil {
+		return err
+	}
+
+	t, err := m.TaskDb.Get(id.String())
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[manager] retrying dead letter task %s\n", id)
+	t.DesiredState = task.Running
+	return m.requeueTask(t)
+}
+
+// DeleteDeadLetter discards the dead letter of the task with the given ID.
+// The task itself stays Failed.
+func (m *Manager) DeleteDeadLetter(id uuid.UUID) error {
+	return m.DeadLetterDb.Delete(id.String())
+}
@@ -0,0 +1,73 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (a *Api) GetDeadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	letters, err := a.Manager.GetDeadLetters(namespaceParam(r))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, letters)
+}
+
+func (a *Api) RetryDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	dl, ok := a.deadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.Manager.RetryDeadLetter(dl.Task.ID)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no dead letter for task %v found", dl.Task.ID))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *Api) DeleteDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	dl, ok := a.deadLetter(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.Manager.DeleteDeadLetter(dl.Task.ID)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no dead letter for task %v found", dl.Task.ID))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deadLetter looks up the dead letter of the task named in the request
+// path within the request's namespace. It responds with an error and
+// reports false if there is none.
+func (a *Api) deadLetter(w http.ResponseWriter, r *http.Request) (*task.DeadLetter, bool) {
+	taskID := chi.URLParam(r, "taskID")
+	id, err := uuid.Parse(taskID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing taskID: %v\n", err))
+		return nil, false
+	}
+
+	dl, err := a.Manager.DeadLetterDb.Get(id.String())
+	if err == nil && dl.Task.Namespace != namespaceParam(r) {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no dead letter for task %v found", id))
+		return nil, false
+	}
+	return dl, true
+}
@@ -0,0 +1,518 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/dev6699/cube/queue"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/worker"
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultDispatchConcurrency is how many tasks may be in flight to
+	// workers at the same time.
+	DefaultDispatchConcurrency = 16
+
+	// DefaultDispatchRate and DefaultDispatchBurst limit how many tasks
+	// per second are sent to a single worker.
+	DefaultDispatchRate  = 20
+	DefaultDispatchBurst = 10
+
+	// DefaultMaxDispatchAttempts is how many workers a task is offered to
+	// before it is moved to the dead letters.
+	DefaultMaxDispatchAttempts = 5
+
+	// dispatchRetryInterval is how often events that could not be
+	// dispatched are retried when nothing else wakes the dispatcher.
+	dispatchRetryInterval = 5 * time.Second
+
+	// A request starting a task that times out or fails to connect is
+	// repeated, up to deliveryAttempts times in all, waiting twice as
+	// long before each repetition.
+	deliveryAttempts = 3
+	deliveryBackoff  = 500 * time.Millisecond
+)
+
+var (
+	// errTaskStarting is returned by dispatch for a stop request of a
+	// task the worker is still starting. It is retried once the task
+	// runs.
+	errTaskStarting = errors.New("task is still being started")
+
+	// errTaskRejected is returned by startTask when the worker refused
+	// a task. Such requests are not repeated.
+	errTaskRejected = errors.New("task rejected by worker")
+
+	// errLeaseExpired is the reason a task is placed elsewhere when its
+	// worker did not start it within the lease it accepted it with.
+	errLeaseExpired = errors.New("task was not started within its lease")
+)
+
+// delivery is a task on its way to a worker.
+type delivery struct {
+	worker string
+	event  task.TaskEvent
+}
+
+// lease is a task that worker accepted and promised to start by expires.
+type lease struct {
+	worker  string
+	expires time.Time
+	event   task.TaskEvent
+}
+
+// notify wakes the dispatcher. It never blocks; wakeups that arrive while
+// one is already pending are merged.
+func (m *Manager) notify() {
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// recoverPending makes sure every task waiting to be started or stopped has
+// an event in the pending queue after a restart. Events that were still
+// queued are kept; tasks whose event was taken off the queue but not
+// dispatched before the manager stopped are queued again. m.mu must be
+// held once m is in use.
+func (m *Manager) recoverPending() error {
+	queued, err := m.Pending.List()
+	if err != nil {
+		return err
+	}
+	if len(queued) > 0 {
+		log.Printf("[manager] replaying %d pending events\n", len(queued))
+	}
+
+	ids := make(map[uuid.UUID]bool)
+	for _, te := range queued {
+		ids[te.Task.ID] = true
+	}
+
+	tasks, err := m.TaskDb.List()
+	if err != nil {
+		return err
+	}
+	for _, t := range tasks {
+		if ids[t.ID] {
+			continue
+		}
+
+		te := task.TaskEvent{
+			ID:        uuid.New(),
+			Timestamp: time.Now(),
+			Task:      *t,
+		}
+		switch {
+		case t.State == task.Pending:
+			te.State = task.Scheduled
+		case t.DesiredState == task.Completed && (t.State == task.Scheduled || t.State == task.Running):
+			te.State = task.Completed
+			te.Task.State = task.Completed
+		default:
+			continue
+		}
+
+		log.Printf("[manager] queueing %s event for task %s again\n", te.State, t.ID)
+		err := m.Pending.Enqueue(te)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resync catches up with the nodes and the tasks they run. It runs before
+// the first dispatch, so that the tasks placed before a restart are known
+// to be running, and their nodes Ready, before anything else is placed.
+func (m *Manager) resync() {
+	log.Println("[manager] resyncing with workers")
+	err := m.checkNodeStats()
+	if err != nil {
+		log.Printf("[manager] failed to update node stats: %v\n", err)
+	}
+	err = m.updateTasks()
+	if err != nil {
+		log.Printf("[manager] error update task: %v\n", err)
+	}
+}
+
+// ProcessTasks dispatches pending events as soon as they are queued. Events
+// that cannot be dispatched yet, e.g. because no node has room, are retried
+// when the cluster changes or after dispatchRetryInterval.
+//
+// A task is only placed on a worker once the worker accepted it. The
+// worker promises to start it within a lease; tasks it fails to accept or
+// start in time are offered to another worker, and moved to the dead
+// letters after MaxDispatchAttempts failures.
+func (m *Manager) ProcessTasks(ctx context.Context) error {
+	ticker := time.NewTicker(dispatchRetryInterval)
+	defer ticker.Stop()
+	defer m.sends.Wait()
+
+	m.resync()
+	for {
+		m.expireLeases()
+		m.dispatchPending(ctx)
+
+		select {
+		case <-m.wake:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// dispatchPending drains the pending queue. Placement happens one event at
+// a time so that every decision sees the previous ones; the requests to
+// the workers are sent in the background. It is not safe for concurrent
+// use; ProcessTasks is its only caller.
+func (m *Manager) dispatchPending(ctx context.Context) {
+	if n := m.Pending.Len(); n > 0 {
+		log.Println("[manager] processing tasks:", n)
+	}
+
+	// Events that have to wait are put back once the queue is drained, so
+	// they are not picked up again in this pass.
+	var deferred []task.TaskEvent
+	defer func() {
+		for _, te := range deferred {
+			err := m.Pending.Enqueue(te)
+			if err != nil {
+				log.Printf("[manager] error requeue event %s: %v\n", te.ID, err)
+			}
+		}
+	}()
+
+	for ctx.Err() == nil {
+		te, err := m.Pending.Dequeue()
+		if errors.Is(err, queue.ErrEmpty) {
+			return
+		}
+		if err != nil {
+			log.Printf("[manager] error dequeue pending event: %v\n", err)
+			return
+		}
+
+		err = m.dispatch(te)
+		switch {
+		case errors.Is(err, errTaskStarting), errors.Is(err, errNoCandidate):
+			deferred = append(deferred, te)
+
+		case errors.Is(err, errNoFit):
+			deferred = append(deferred, te)
+			// Make room by evicting lower priority tasks. The pass ends
+			// here so that the evicted tasks, which are queued again, do
+			// not take the room back before te is retried.
+			perr := m.preempt(&te.Task)
+			if perr == nil {
+				m.notify()
+				return
+			}
+			log.Printf("[manager] error send task to worker: %v: %v\n", err, perr)
+
+		case err != nil:
+			log.Printf("[manager] error send task to worker: %v\n", err)
+		}
+	}
+}
+
+// dispatch places te's task on a worker, or finds the worker to stop it
+// on, and sends the request in the background.
+func (m *Manager) dispatch(te task.TaskEvent) error {
+	err := m.EventDb.Put(te.ID.String(), &te)
+	if err != nil {
+		return err
+	}
+
+	t := te.Task
+	m.mu.Lock()
+	taskWorker, ok := m.TaskWorkerMap[t.ID]
+	persistedTask, err := m.TaskDb.Get(t.ID.String())
+	if ok {
+		m.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if te.State == task.Completed &&
+			task.ValidStateTransiton(persistedTask.State, te.State) {
+			m.send(taskWorker, func() {
+				err := m.stopTask(taskWorker, t.ID.String())
+				if err != nil {
+					log.Printf("[manager] error stop task %s: %v\n", t.ID, err)
+				}
+			})
+			return nil
+		}
+		if te.State == task.Completed && persistedTask.State == task.Scheduled {
+			return fmt.Errorf("task %s: %w", t.ID, errTaskStarting)
+		}
+
+		log.Printf("[manager] invalid request: existing task is %s is in state %d and cannot transition to the completed state\n",
+			persistedTask.ID.String(), persistedTask.State)
+		return nil
+	}
+
+	if err == nil && persistedTask.DesiredState == task.Completed {
+		defer m.mu.Unlock()
+		if persistedTask.State == task.Pending {
+			log.Printf("[manager] task %s stopped before it was scheduled\n", t.ID)
+			persistedTask.State = task.Completed
+			persistedTask.FinishTime = time.Now().UTC()
+			return m.saveTask(persistedTask)
+		}
+		return nil
+	}
+	_, inFlight := m.dispatching[t.ID]
+	m.mu.Unlock()
+
+	if te.State == task.Completed {
+		log.Printf("[manager] invalid request: task %s is not known to any worker\n", t.ID)
+		return nil
+	}
+	if inFlight {
+		log.Printf("[manager] task %s is already on its way to a worker\n", t.ID)
+		return nil
+	}
+
+	w, err := m.SelectWorker(t, te.FailedWorkers)
+	if err != nil {
+		return err
+	}
+
+	// The task stays Pending until the worker accepts it; meanwhile the
+	// delivery reserves its room on the worker.
+	t.State = task.Scheduled
+	t.DesiredState = task.Running
+	te.Task = t
+	m.mu.Lock()
+	m.dispatching[t.ID] = delivery{worker: w.Name, event: te}
+	m.mu.Unlock()
+
+	m.send(w.Name, func() {
+		m.deliver(w.Name, w.Api, te)
+	})
+	return nil
+}
+
+// send runs f in the background once the rate limit of worker allows it.
+// At most DispatchConcurrency sends run at the same time.
+func (m *Manager) send(worker string, f func()) {
+	m.sendOnce.Do(func() {
+		m.sendSlots = make(chan struct{}, max(m.DispatchConcurrency, 1))
+	})
+
+	m.mu.Lock()
+	b, ok := m.limiters[worker]
+	if !ok {
+		b = newTokenBucket(m.DispatchRate, m.DispatchBurst)
+		m.limiters[worker] = b
+	}
+	delay := b.reserve(time.Now())
+	m.mu.Unlock()
+
+	m.sends.Add(1)
+	go func() {
+		defer m.sends.Done()
+		time.Sleep(delay)
+
+		m.sendSlots <- struct{}{}
+		defer func() { <-m.sendSlots }()
+		f()
+	}()
+}
+
+// deliver sends te to worker and records whether the worker accepted it.
+// A task that was stopped while it was on its way is stopped on the
+// worker again.
+func (m *Manager) deliver(worker string, api string, te task.TaskEvent) {
+	ack, err := m.startTask(api, te)
+
+	m.mu.Lock()
+	delete(m.dispatching, te.Task.ID)
+	unwanted := false
+	if err == nil {
+		unwanted, err = m.accepted(worker, te, ack)
+	} else {
+		log.Printf("[manager] error send task %s to worker %s: %v\n", te.Task.ID, worker, err)
+		err = m.dispatchFailed(te, worker, err)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		log.Printf("[manager] error dispatch task %s: %v\n", te.Task.ID, err)
+	}
+
+	if unwanted {
+		log.Printf("[manager] task %s was stopped while it was sent to worker %s\n", te.Task.ID, worker)
+		err := m.stopTask(worker, te.Task.ID.String())
+		if err != nil {
+			log.Printf("[manager] error stop task %s: %v\n", te.Task.ID, err)
+		}
+	}
+}
+
+// accepted places te's task on the worker w, which accepted it with ack. It
+// reports whether the task is no longer wanted, because it was stopped or
+// removed while it was on its way. m.mu must be held.
+func (m *Manager) accepted(w string, te task.TaskEvent, ack worker.Ack) (bool, error) {
+	t, err := m.TaskDb.Get(te.Task.ID.String())
+	if errors.Is(err, store.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if t.State != task.Pending || t.DesiredState != task.Running {
+		return true, nil
+	}
+
+	if ack.Lease <= 0 {
+		ack.Lease = worker.DefaultStartLease
+	}
+	log.Printf("[manager] task %s accepted by worker %s\n", t.ID, w)
+	m.assignTask(t, w)
+	t.State = task.Scheduled
+	m.leases[t.ID] = lease{
+		worker:  w,
+		expires: time.Now().Add(ack.Lease),
+		event:   te,
+	}
+	return false, m.saveTask(t)
+}
+
+// dispatchFailed hands te's task back to the scheduler after worker failed
+// to accept or start it. The next attempt avoids the workers that failed
+// before; once MaxDispatchAttempts have failed the task is moved to the
+// dead letters. m.mu must be held.
+func (m *Manager) dispatchFailed(te task.TaskEvent, worker string, cause error) error {
+	t, err := m.TaskDb.Get(te.Task.ID.String())
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if t.State != task.Pending || t.DesiredState != task.Running {
+		return nil
+	}
+
+	// Every attempt is a new event, so that a worker that accepted the
+	// task before takes it again.
+	te.ID = uuid.New()
+	te.Task = *t
+	te.Attempts++
+	te.FailedWorkers = append(slices.Clone(te.FailedWorkers), worker)
+	te.Timestamp = time.Now()
+	if te.Attempts >= max(m.MaxDispatchAttempts, 1) {
+		return m.deadLetter(t, te, cause)
+	}
+
+	log.Printf("[manager] placing task %s again after %d failed attempts\n", t.ID, te.Attempts)
+	err = m.Pending.Enqueue(te)
+	if err != nil {
+		return err
+	}
+	m.notify()
+	return nil
+}
+
+// expireLeases hands the tasks that their worker accepted but did not
+// start within the lease back to the scheduler. They are stopped on that
+// worker in case they start after all.
+func (m *Manager) expireLeases() {
+	now := time.Now()
+	var expired []lease
+
+	m.mu.Lock()
+	for id, l := range m.leases {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil || t.State != task.Scheduled || m.TaskWorkerMap[id] != l.worker {
+			// Started, moved or removed.
+			delete(m.leases, id)
+			continue
+		}
+		if now.Before(l.expires) {
+			continue
+		}
+
+		delete(m.leases, id)
+		expired = append(expired, l)
+		log.Printf("[manager] worker %s did not start task %s within its lease\n", l.worker, id)
+		err = m.unplaceTask(t)
+		if err == nil {
+			err = m.dispatchFailed(l.event, l.worker, errLeaseExpired)
+		}
+		if err != nil {
+			log.Printf("[manager] error dispatch task %s: %v\n", id, err)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, l := range expired {
+		m.send(l.worker, func() {
+			err := m.preemptTask(l.worker, l.event.Task.ID.String())
+			if err != nil && !errors.Is(err, errTaskNotOnWorker) {
+				log.Printf("[manager] error stop task %s: %v\n", l.event.Task.ID, err)
+			}
+		})
+	}
+}
+
+// startTask asks the worker serving api to start te's task and returns
+// its acknowledgement. Requests that time out, cannot connect or fail on
+// the worker are repeated; the worker recognizes te if it accepted it
+// before, so a repeated request never starts the task twice.
+func (m *Manager) startTask(api string, te task.TaskEvent) (worker.Ack, error) {
+	data, err := json.Marshal(te)
+	if err != nil {
+		return worker.Ack{}, err
+	}
+
+	backoff := deliveryBackoff
+	for attempt := 1; ; attempt++ {
+		ack, err := m.postTask(api, data)
+		if err == nil || errors.Is(err, errTaskRejected) || attempt == deliveryAttempts {
+			return ack, err
+		}
+
+		log.Printf("[manager] error send task %s, retrying: %v\n", te.Task.ID, err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// postTask sends a request to start a task to the worker serving api once.
+func (m *Manager) postTask(api string, data []byte) (worker.Ack, error) {
+	var ack worker.Ack
+	url := fmt.Sprintf("%s/tasks", api)
+	resp, err := m.Client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return ack, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusCreated:
+		err = json.NewDecoder(resp.Body).Decode(&ack)
+		return ack, err
+
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return ack, fmt.Errorf("invalid status code: %d", resp.StatusCode)
+
+	default:
+		var e worker.ErrResponse
+		json.NewDecoder(resp.Body).Decode(&e)
+		return ack, fmt.Errorf("%w: status %d: %s", errTaskRejected, resp.StatusCode, e.Message)
+	}
+}
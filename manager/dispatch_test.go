@@ -0,0 +1,174 @@
+package manager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// newDispatchTest starts a manager with n fake workers that can be told
+// to misbehave. The round robin scheduler offers the first task to the
+// last worker.
+func newDispatchTest(t *testing.T, n int) (*Manager, string, []*fakeWorker) {
+	m, api := newTestManager(t, n)
+	m.DispatchRate = 0
+
+	var fws []*fakeWorker
+	for _, wn := range m.WorkerNodes {
+		fw := newFakeWorker(t)
+		wn.Api = fw.srv.URL
+		fws = append(fws, fw)
+	}
+	return m, api.URL, fws
+}
+
+// dispatchAll runs dispatch passes until the pending queue is empty.
+func dispatchAll(t *testing.T, m *Manager) {
+	for i := 0; i < 10; i++ {
+		m.dispatchPending(context.Background())
+		m.sends.Wait()
+		if m.Pending.Len() == 0 {
+			return
+		}
+	}
+	t.Fatalf("%d events still pending", m.Pending.Len())
+}
+
+func submit(t *testing.T, m *Manager) uuid.UUID {
+	te := newTaskEvent(namespace.Default)
+	if _, _, err := m.Submit(te, ""); err != nil {
+		t.Fatal(err)
+	}
+	return te.Task.ID
+}
+
+func getTask(t *testing.T, m *Manager, id uuid.UUID) *task.Task {
+	tk, err := m.TaskDb.Get(id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tk
+}
+
+func TestRejectedTaskIsPlacedElsewhere(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 2)
+	fws[1].failures = []int{http.StatusBadRequest, http.StatusBadRequest}
+
+	id := submit(t, m)
+	dispatchAll(t, m)
+
+	tk := getTask(t, m, id)
+	if tk.State != task.Scheduled || tk.Worker != "worker-0" {
+		t.Errorf("task is %s on %q, want it Scheduled on worker-0", tk.State, tk.Worker)
+	}
+	if fws[1].requests != 1 {
+		t.Errorf("rejecting worker got %d requests, want 1", fws[1].requests)
+	}
+}
+
+func TestUnansweredRequestIsRepeated(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 1)
+	fws[0].failures = []int{http.StatusServiceUnavailable}
+
+	id := submit(t, m)
+	dispatchAll(t, m)
+
+	tk := getTask(t, m, id)
+	if tk.State != task.Scheduled || tk.Worker != "worker-0" {
+		t.Errorf("task is %s on %q, want it Scheduled on worker-0", tk.State, tk.Worker)
+	}
+	if fws[0].requests != 2 {
+		t.Errorf("worker got %d requests, want 2", fws[0].requests)
+	}
+}
+
+func TestExpiredLeaseIsPlacedElsewhere(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 2)
+	fws[1].stalled = true
+	fws[1].lease = time.Millisecond
+
+	id := submit(t, m)
+	dispatchAll(t, m)
+	if tk := getTask(t, m, id); tk.Worker != "worker-1" {
+		t.Fatalf("task is on %q, want it accepted by worker-1", tk.Worker)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	m.expireLeases()
+	m.sends.Wait()
+	dispatchAll(t, m)
+
+	tk := getTask(t, m, id)
+	if tk.State != task.Scheduled || tk.Worker != "worker-0" {
+		t.Errorf("task is %s on %q, want it Scheduled on worker-0", tk.State, tk.Worker)
+	}
+}
+
+func TestExpiredLeaseIsOfferedToTheSameWorker(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 1)
+	fws[0].stalled = true
+	fws[0].lease = time.Millisecond
+
+	id := submit(t, m)
+	dispatchAll(t, m)
+
+	time.Sleep(10 * time.Millisecond)
+	fws[0].mu.Lock()
+	fws[0].stalled = false
+	fws[0].mu.Unlock()
+	m.expireLeases()
+	m.sends.Wait()
+	dispatchAll(t, m)
+	m.updateTasks()
+
+	if tk := getTask(t, m, id); tk.State != task.Running || tk.Worker != "worker-0" {
+		t.Errorf("task is %s on %q, want it Running on worker-0", tk.State, tk.Worker)
+	}
+	if fws[0].requests != 2 {
+		t.Errorf("worker got %d requests, want 2", fws[0].requests)
+	}
+}
+
+func TestUndeliverableTaskIsDeadLettered(t *testing.T) {
+	m, api, fws := newDispatchTest(t, 1)
+	m.MaxDispatchAttempts = 3
+	fws[0].failures = []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest}
+
+	id := submit(t, m)
+	dispatchAll(t, m)
+
+	if tk := getTask(t, m, id); tk.State != task.Failed {
+		t.Errorf("task is %s, want it Failed", tk.State)
+	}
+	resp, err := http.Get(api + "/deadletters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var letters []task.DeadLetter
+	err = json.NewDecoder(resp.Body).Decode(&letters)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Task.ID != id || letters[0].Attempts != 3 {
+		t.Fatalf("got dead letters %+v, want task %s after 3 attempts", letters, id)
+	}
+
+	if status := doRequest(t, http.MethodPost, api+"/deadletters/"+id.String()+"/retry", nil); status != http.StatusNoContent {
+		t.Fatalf("retry dead letter: got status %d", status)
+	}
+	dispatchAll(t, m)
+
+	if tk := getTask(t, m, id); tk.State != task.Scheduled {
+		t.Errorf("retried task is %s, want it Scheduled", tk.State)
+	}
+	if m.isDeadLetter(id) {
+		t.Error("retried task is still a dead letter")
+	}
+}
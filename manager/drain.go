@@ -0,0 +1,133 @@
+package manager
+
+import (
+	"log"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+)
+
+// CordonNode marks the named node unschedulable, or schedulable again when
+// cordon is false. Uncordoning a node also stops draining it.
+func (m *Manager) CordonNode(name string, cordon bool) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.workerNode(name)
+	if n == nil {
+		return nil, store.ErrNotFound
+	}
+
+	n.Unschedulable = cordon
+	if !cordon {
+		n.Draining = false
+		m.notify()
+	}
+	log.Printf("[manager] node %s unschedulable: %t\n", n.Name, cordon)
+	m.publishNode(n)
+	return n.Clone(), nil
+}
+
+// DrainNode cordons the named node and starts moving its tasks to other
+// nodes. Tasks of a service are only moved while the service's disruption
+// budget allows it, so draining may take several rounds.
+func (m *Manager) DrainNode(name string) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.workerNode(name)
+	if n == nil {
+		return nil, store.ErrNotFound
+	}
+
+	n.Unschedulable = true
+	n.Draining = true
+	log.Printf("[manager] draining node %s\n", n.Name)
+	m.publishNode(n)
+	return n.Clone(), nil
+}
+
+// drainNodes evicts the tasks of draining nodes and finishes the drain of
+// nodes that have no tasks left.
+func (m *Manager) drainNodes() {
+	for _, n := range m.GetNodes(labels.Selector{}) {
+		if !n.Draining {
+			continue
+		}
+
+		m.mu.Lock()
+		victims, remaining := m.drainVictims(n.Name)
+		m.mu.Unlock()
+
+		for _, t := range victims {
+			log.Printf("[manager] draining node %s: moving task %s\n", n.Name, t.ID)
+			err := m.evictTask(t)
+			if err != nil {
+				log.Printf("[manager] error evict task %s: %v\n", t.ID, err)
+				remaining++
+			}
+		}
+		if remaining > 0 {
+			continue
+		}
+
+		m.mu.Lock()
+		if wn := m.workerNode(n.Name); wn != nil && wn.Draining {
+			log.Printf("[manager] node %s drained\n", n.Name)
+			wn.Draining = false
+			m.publishNode(wn)
+		}
+		m.mu.Unlock()
+	}
+}
+
+// drainVictims returns the tasks on the named node that the disruption
+// budgets allow to be moved now, and the number of tasks that have to stay
+// for the time being. m.mu must be held.
+func (m *Manager) drainVictims(name string) ([]*task.Task, int) {
+	running := make(map[string]int)
+	for _, t := range m.GetTasks() {
+		if t.Owner != nil && t.Owner.Kind == service.Kind &&
+			t.State == task.Running && t.DesiredState != task.Completed {
+			running[namespace.Key(t.Namespace, t.Owner.Name)]++
+		}
+	}
+
+	var victims []*task.Task
+	remaining := 0
+	for _, id := range m.WorkerTaskMap[name] {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil {
+			log.Printf("[manager] error get task %s: %v\n", id, err)
+			continue
+		}
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+		if t.DesiredState == task.Completed {
+			// Already being stopped.
+			remaining++
+			continue
+		}
+
+		if t.Owner != nil && t.Owner.Kind == service.Kind {
+			key := namespace.Key(t.Namespace, t.Owner.Name)
+			s, err := m.ServiceDb.Get(key)
+			if err == nil && s.Owns(t) {
+				if s.AllowedDisruptions(running[key]) == 0 {
+					remaining++
+					continue
+				}
+				if t.State == task.Running {
+					running[key]--
+				}
+			}
+		}
+		victims = append(victims, t)
+	}
+	return victims, remaining
+}
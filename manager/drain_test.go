@@ -0,0 +1,69 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/task"
+)
+
+func TestDrainRespectsDisruptionBudget(t *testing.T) {
+	m, _, _ := newDispatchTest(t, 3)
+	s := &service.Service{
+		Name:             "web",
+		Namespace:        namespace.Default,
+		Replicas:         4,
+		Template:         task.Task{Image: "nginx"},
+		DisruptionBudget: &service.DisruptionBudget{MaxUnavailable: 1},
+	}
+	err := m.CreateService(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		stepServices(t, m)
+	}
+	if _, running := serviceReplicas(m, s); running["nginx"] != 4 {
+		t.Fatalf("got running replicas %v, want 4", running)
+	}
+
+	// Drain the node with the most replicas, so the budget has to hold
+	// some of them back.
+	busiest := ""
+	for name, ids := range m.WorkerTaskMap {
+		if busiest == "" || len(ids) > len(m.WorkerTaskMap[busiest]) {
+			busiest = name
+		}
+	}
+	if len(m.WorkerTaskMap[busiest]) < 2 {
+		t.Fatalf("node %s runs %d replicas, want at least 2", busiest, len(m.WorkerTaskMap[busiest]))
+	}
+	_, err = m.DrainNode(busiest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10 && m.workerNode(busiest).Draining; i++ {
+		m.drainNodes()
+		if _, running := serviceReplicas(m, s); running["nginx"] < 3 {
+			t.Fatalf("round %d: %d replicas running during the drain, want at least 3", i, running["nginx"])
+		}
+		dispatchAll(t, m)
+		m.updateTasks()
+	}
+
+	n := m.workerNode(busiest)
+	if n.Draining || !n.Unschedulable {
+		t.Errorf("node is draining %t, unschedulable %t, want a finished drain that leaves it cordoned", n.Draining, n.Unschedulable)
+	}
+	active, running := serviceReplicas(m, s)
+	if running["nginx"] != 4 {
+		t.Errorf("got running replicas %v after the drain, want 4", running)
+	}
+	for _, tk := range active {
+		if tk.Worker == busiest {
+			t.Errorf("task %s is still on the drained node", tk.ID)
+		}
+	}
+}
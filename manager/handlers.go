@@ -2,24 +2,81 @@ package manager
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/store"
 	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/watch"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
+const (
+	// idempotencyKeyHeader carries a key chosen by the client that makes
+	// a task submission safe to repeat.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks the response to a repeated
+	// submission, which returns the task created the first time.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotencyKeyLen bounds the length of idempotency keys.
+	maxIdempotencyKeyLen = 255
+)
+
+// NextCursorHeader carries the cursor of the next page of a task listing
+// that was cut off at its limit.
+const NextCursorHeader = "X-Next-Cursor"
+
 type ErrResponse struct {
 	HTTPStatusCode int
 	Message        string
 }
 
 func (a *Api) GetTasksHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(a.Manager.GetTasks())
+	q, err := v1.ParseTaskQuery(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := a.Manager.QueryTasks(namespaceParam(r), q)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if page.Next != "" {
+		w.Header().Set(NextCursorHeader, page.Next)
+	}
+	respondJSON(w, http.StatusOK, page.Tasks)
+}
+
+func (a *Api) GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	taskID := chi.URLParam(r, "taskID")
+	tID, err := uuid.Parse(taskID)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing taskID: %v\n", err))
+		return
+	}
+
+	t, err := a.Manager.TaskDb.Get(tID.String())
+	if err == nil && t.Namespace != namespaceParam(r) {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no task with ID %v found", tID))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, t)
 }
 
 func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
@@ -38,9 +95,51 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.Manager.AddTask(te)
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(te.Task)
+	err = setNamespace(r, &te.Task.Namespace)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	err = labels.Validate(te.Task.Labels)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if te.State == task.Completed && te.Task.ID == uuid.Nil {
+		respondError(w, http.StatusBadRequest, "a task to stop needs an ID")
+		return
+	}
+	key := r.Header.Get(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLen {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("%s is longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLen))
+		return
+	}
+
+	t, created, err := a.Manager.Submit(te, key)
+	if errors.Is(err, namespace.ErrQuotaExceeded) {
+		respondError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if errors.Is(err, ErrTaskExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if errors.Is(err, ErrIdempotencyKeyReused) {
+		respondError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, fmt.Sprintf("Error admitting task: %v\n", err))
+		return
+	}
+
+	if !created {
+		w.Header().Set(idempotentReplayedHeader, "true")
+		respondJSON(w, http.StatusOK, t)
+		return
+	}
+	respondJSON(w, http.StatusCreated, t)
 }
 
 func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
@@ -66,8 +165,14 @@ func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	taskToStop, err := a.Manager.TaskDb.Get(tID.String())
-	if err != nil {
+	t, err := a.Manager.TaskDb.Get(tID.String())
+	if err == nil && t.Namespace != namespaceParam(r) {
+		err = store.ErrNotFound
+	}
+	if err == nil {
+		err = a.Manager.StopTask(tID)
+	}
+	if errors.Is(err, store.ErrNotFound) {
 		w.WriteHeader(http.StatusNotFound)
 		e := ErrResponse{
 			HTTPStatusCode: http.StatusNotFound,
@@ -76,22 +181,245 @@ func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(e)
 		return
 	}
-
-	te := task.TaskEvent{
-		ID:        uuid.New(),
-		State:     task.Completed,
-		Timestamp: time.Now(),
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusInternalServerError,
+			Message:        fmt.Sprintf("Error stopping task: %v\n", err),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
 	}
 
-	taskCopy := *taskToStop
-	taskCopy.State = task.Completed
-	te.Task = taskCopy
-	a.Manager.AddTask(te)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func (a *Api) GetNodesHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
+	sel, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("invalid label selector: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, a.Manager.GetNodes(sel))
+}
+
+// RegisterNodeHandler adds a worker to the cluster or updates a worker
+// that registers again.
+func (a *Api) RegisterNodeHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var reg node.Registration
+	err := d.Decode(&reg)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	n, err := a.Manager.RegisterNode(reg)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, n)
+}
+
+// HeartbeatHandler records that a registered worker is alive. Workers
+// that get a 404 register again.
+func (a *Api) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	err := a.Manager.Heartbeat(name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no node with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PatchNodeHandler sets or removes labels and annotations of a node.
+func (a *Api) PatchNodeHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var p node.Patch
+	err := d.Decode(&p)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	n, err := a.Manager.PatchNode(name, p)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no node with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, n)
+}
+
+// CordonNodeHandler stops new tasks from being placed on a node.
+func (a *Api) CordonNodeHandler(w http.ResponseWriter, r *http.Request) {
+	a.cordonNode(w, r, true)
+}
+
+// UncordonNodeHandler allows new tasks on a node again and cancels a drain
+// in progress.
+func (a *Api) UncordonNodeHandler(w http.ResponseWriter, r *http.Request) {
+	a.cordonNode(w, r, false)
+}
+
+func (a *Api) cordonNode(w http.ResponseWriter, r *http.Request, cordon bool) {
+	name := chi.URLParam(r, "name")
+	n, err := a.Manager.CordonNode(name, cordon)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no node with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, n)
+}
+
+// DrainNodeHandler cordons a node and moves its tasks elsewhere in the
+// background. The node's Draining field is cleared once it is empty.
+func (a *Api) DrainNodeHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	n, err := a.Manager.DrainNode(name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no node with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusAccepted, n)
+}
+
+// WatchHandler streams task and node changes as Server-Sent Events. Only
+// the tasks of the namespace given with the namespace query parameter, by
+// default the default namespace, are included. Clients resume with the
+// resourceVersion query parameter or the Last-Event-ID header; without
+// either, the current tasks and nodes are sent first.
+func (a *Api) WatchHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusInternalServerError,
+			Message:        "streaming unsupported",
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	rv := r.URL.Query().Get("resourceVersion")
+	if rv == "" {
+		rv = r.Header.Get("Last-Event-ID")
+	}
+
+	var since uint64
+	if rv != "" {
+		var err error
+		since, err = strconv.ParseUint(rv, 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			e := ErrResponse{
+				HTTPStatusCode: http.StatusBadRequest,
+				Message:        fmt.Sprintf("Error parsing resourceVersion: %v\n", err),
+			}
+			json.NewEncoder(w).Encode(e)
+			return
+		}
+	} else {
+		since = a.Manager.Hub.Version()
+	}
+
+	sub, err := a.Manager.Hub.Subscribe(since)
+	if err != nil {
+		w.WriteHeader(http.StatusGone)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusGone,
+			Message:        err.Error(),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+	defer sub.Close()
+
+	kind := watch.Kind(r.URL.Query().Get("kind"))
+	ns := r.URL.Query().Get("namespace")
+	if ns == "" {
+		ns = namespace.Default
+	}
+	matches := func(e watch.Event) bool {
+		if e.Task != nil && e.Task.Namespace != ns {
+			return false
+		}
+		return kind == "" || strings.EqualFold(string(e.Kind), string(kind))
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(a.Manager.WorkerNodes)
+
+	if rv == "" {
+		for _, t := range a.Manager.GetTasks() {
+			e := watch.Event{ResourceVersion: since, Type: watch.Added, Kind: watch.KindTask, Task: t}
+			if matches(e) {
+				watch.WriteEvent(w, e)
+			}
+		}
+		for _, n := range a.Manager.GetNodes(labels.Selector{}) {
+			e := watch.Event{ResourceVersion: since, Type: watch.Added, Kind: watch.KindNode, Node: n}
+			if matches(e) {
+				watch.WriteEvent(w, e)
+			}
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case e, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if !matches(e) {
+				continue
+			}
+			err := watch.WriteEvent(w, e)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-keepalive.C:
+			_, err := fmt.Fprint(w, ": keepalive\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func respondJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func respondError(w http.ResponseWriter, status int, message string) {
+	respondJSON(w, status, ErrResponse{
+		HTTPStatusCode: status,
+		Message:        message,
+	})
 }
@@ -0,0 +1,208 @@
+package manager
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/stats"
+	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/watch"
+	"github.com/google/uuid"
+)
+
+// DefaultNodeGracePeriod is how long a node may go without answering the
+// manager before it is declared dead and its tasks are rescheduled.
+const DefaultNodeGracePeriod = 60 * time.Second
+
+// errTaskNotOnWorker is returned by stopTask when the worker does not know
+// the task, e.g. because it was restarted.
+var errTaskNotOnWorker = errors.New("task not found on worker")
+
+// errNoCandidate is returned by SelectWorker when no schedulable node can
+// run a task.
+var errNoCandidate = errors.New("no available candidate")
+
+// schedulableNodes returns the nodes new tasks may be placed on: those
+// that are Ready and not cordoned. m.mu must be held.
+func (m *Manager) schedulableNodes() []*node.Node {
+	var nodes []*node.Node
+	for _, n := range m.WorkerNodes {
+		if n.Status == node.Ready && !n.Unschedulable {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// nodeReachable records a successful poll of the named node. A node that
+// comes back after its tasks were rescheduled only becomes Ready again once
+// the old copies it may still be running have been stopped. Polls that only
+// update the stats are not published to watchers.
+func (m *Manager) nodeReachable(name string, s stats.Stats) {
+	m.mu.Lock()
+	n := m.workerNode(name)
+	if n == nil {
+		m.mu.Unlock()
+		return
+	}
+	n.Stats = s
+	n.LastSeen = time.Now().UTC()
+	if n.Status == node.Ready {
+		m.mu.Unlock()
+		return
+	}
+	fenced := slices.Clone(m.fenced[name])
+	m.mu.Unlock()
+
+	stopped, err := m.fenceNode(name, fenced)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fenced[name] = slices.DeleteFunc(m.fenced[name], func(id uuid.UUID) bool {
+		return slices.Contains(stopped, id)
+	})
+	if len(m.fenced[name]) > 0 {
+		log.Printf("[manager] node %s is reachable but still runs rescheduled tasks: %v\n", name, err)
+		m.setNodeStatus(n, node.NotReady)
+		return
+	}
+
+	delete(m.fenced, name)
+	log.Printf("[manager] node %s is Ready\n", name)
+	m.setNodeStatus(n, node.Ready)
+}
+
+// nodeUnreachable records a failed poll of the named node.
+func (m *Manager) nodeUnreachable(name string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.workerNode(name)
+	if n == nil || n.Status != node.Ready {
+		return
+	}
+
+	log.Printf("[manager] node %s is NotReady: %v\n", name, err)
+	m.setNodeStatus(n, node.NotReady)
+}
+
+// checkNodeHealth declares nodes that have not been heard from within the
+// grace period dead and reschedules their tasks onto the remaining nodes.
+// Heartbeats from a worker keep its node from being declared dead even
+// when the manager cannot poll it.
+func (m *Manager) checkNodeHealth() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	for _, n := range m.WorkerNodes {
+		if n.Status != node.NotReady {
+			continue
+		}
+		lastContact := n.LastSeen
+		if n.LastHeartbeat.After(lastContact) {
+			lastContact = n.LastHeartbeat
+		}
+		if now.Sub(lastContact) < m.NodeGracePeriod {
+			continue
+		}
+
+		log.Printf("[manager] node %s has not been reachable for %v, rescheduling its tasks\n", n.Name, m.NodeGracePeriod)
+		m.setNodeStatus(n, node.Unknown)
+		m.rescheduleNodeTasks(n)
+	}
+}
+
+// rescheduleNodeTasks moves the active tasks of a dead node back to the
+// pending queue, remembering them so that they can be stopped on the node
+// if it comes back. Tasks that were being stopped are marked Completed.
+// m.mu must be held.
+func (m *Manager) rescheduleNodeTasks(n *node.Node) {
+	for _, id := range slices.Clone(m.WorkerTaskMap[n.Name]) {
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil {
+			log.Printf("[manager] error get task %s: %v\n", id, err)
+			continue
+		}
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+
+		m.fenced[n.Name] = append(m.fenced[n.Name], t.ID)
+		if t.DesiredState == task.Completed {
+			m.unassignTask(t)
+			t.State = task.Completed
+			t.FinishTime = time.Now().UTC()
+			err = m.saveTask(t)
+		} else {
+			log.Printf("[manager] rescheduling task %s from node %s\n", t.ID, n.Name)
+			err = m.requeueTask(t)
+		}
+		if err != nil {
+			log.Printf("[manager] error reschedule task %s: %v\n", t.ID, err)
+		}
+	}
+}
+
+// fenceNode stops the copies of rescheduled tasks on the named node and
+// returns the IDs of those that are no longer running there.
+func (m *Manager) fenceNode(name string, ids []uuid.UUID) ([]uuid.UUID, error) {
+	var stopped []uuid.UUID
+	for _, id := range ids {
+		err := m.preemptTask(name, id.String())
+		if err != nil && !errors.Is(err, errTaskNotOnWorker) {
+			return stopped, err
+		}
+		log.Printf("[manager] stopped rescheduled task %s on node %s\n", id, name)
+		stopped = append(stopped, id)
+	}
+	return stopped, nil
+}
+
+// assignTask records that t is placed on worker. The caller saves t. m.mu
+// must be held.
+func (m *Manager) assignTask(t *task.Task, worker string) {
+	m.unassignTask(t)
+	m.WorkerTaskMap[worker] = append(m.WorkerTaskMap[worker], t.ID)
+	m.TaskWorkerMap[t.ID] = worker
+	t.Worker = worker
+}
+
+// unassignTask forgets the worker t was placed on. The caller saves t.
+// m.mu must be held.
+func (m *Manager) unassignTask(t *task.Task) {
+	t.Worker = ""
+	w, ok := m.TaskWorkerMap[t.ID]
+	if !ok {
+		return
+	}
+
+	delete(m.TaskWorkerMap, t.ID)
+	m.WorkerTaskMap[w] = slices.DeleteFunc(m.WorkerTaskMap[w], func(id uuid.UUID) bool {
+		return id == t.ID
+	})
+}
+
+// setNodeStatus changes the status of n and publishes the change. m.mu
+// must be held.
+func (m *Manager) setNodeStatus(n *node.Node, s node.Status) {
+	if n.Status == s {
+		return
+	}
+	n.Status = s
+	m.publishNode(n)
+	if s == node.Ready {
+		m.notify()
+	}
+}
+
+func (m *Manager) publishNode(n *node.Node) {
+	m.Hub.Publish(watch.Event{
+		Type: watch.Modified,
+		Kind: watch.KindNode,
+		Node: n.Clone(),
+	})
+}
@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/task"
+)
+
+func TestNodePollsOnlyPublishChanges(t *testing.T) {
+	m, _ := newTestManager(t, 2)
+
+	before := m.Hub.Version()
+	for i := 0; i < 3; i++ {
+		err := m.checkNodeStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := m.Hub.Version(); got != before {
+		t.Errorf("polling Ready nodes published %d events, want none", got-before)
+	}
+
+	m.nodeUnreachable("worker-0", nil)
+	m.nodeUnreachable("worker-0", nil)
+	if got := m.Hub.Version(); got != before+1 {
+		t.Errorf("a node becoming NotReady published %d events, want 1", got-before)
+	}
+}
+
+func TestDeadNodeTasksAreMovedAndFenced(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 2)
+	m.NodeGracePeriod = 0
+
+	id := submit(t, m)
+	dispatchAll(t, m)
+	m.updateTasks()
+	tk := getTask(t, m, id)
+	if tk.State != task.Running {
+		t.Fatalf("task is %s, want it Running", tk.State)
+	}
+	dead := m.workerNode(tk.Worker)
+	api := dead.Api
+	var deadWorker *fakeWorker
+	for _, fw := range fws {
+		if fw.srv.URL == api {
+			deadWorker = fw
+		}
+	}
+
+	// The node stops answering and is declared dead.
+	dead.Api = "http://127.0.0.1:1"
+	m.checkNodeStats()
+	m.checkNodeHealth()
+	if dead.Status != node.Unknown {
+		t.Fatalf("dead node is %s, want it Unknown", dead.Status)
+	}
+	dispatchAll(t, m)
+	m.updateTasks()
+	tk = getTask(t, m, id)
+	if tk.State != task.Running || tk.Worker == dead.Name {
+		t.Fatalf("task is %s on %s, want it Running on the other node", tk.State, tk.Worker)
+	}
+	moved := tk.Worker
+
+	// The node comes back still running the old copy, which is stopped
+	// before the node is used again.
+	dead.Api = api
+	m.checkNodeStats()
+	if dead.Status != node.Ready {
+		t.Errorf("returned node is %s, want it Ready", dead.Status)
+	}
+	deadWorker.mu.Lock()
+	state := deadWorker.tasks[id].State
+	deadWorker.mu.Unlock()
+	if state != task.Preempted {
+		t.Errorf("old copy is %s on the returned node, want it Preempted", state)
+	}
+	m.updateTasks()
+	if tk := getTask(t, m, id); tk.State != task.Running || tk.Worker != moved {
+		t.Errorf("task is %s on %s after the node returned, want it Running on %s", tk.State, tk.Worker, moved)
+	}
+}
@@ -0,0 +1,270 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/job"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrJobExists = errors.New("job already exists")
+)
+
+func (m *Manager) CreateJob(j *job.Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j.SetDefaults()
+	err := j.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = m.JobDb.Get(j.Key())
+	if err == nil {
+		return ErrJobExists
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	j.UID = uuid.New()
+	j.CreatedAt = time.Now().UTC()
+	j.Status = job.Status{
+		State:     job.Running,
+		StartTime: j.CreatedAt,
+	}
+	for i := 0; i < j.Completions; i++ {
+		j.Status.Indexes = append(j.Status.Indexes, job.IndexStatus{Index: i})
+	}
+	return m.JobDb.Put(j.Key(), j)
+}
+
+// GetJobs returns the jobs of namespace ns.
+func (m *Manager) GetJobs(ns string) []*job.Job {
+	all, err := m.JobDb.List()
+	if err != nil {
+		return []*job.Job{}
+	}
+
+	jobs := []*job.Job{}
+	for _, j := range all {
+		if j.Namespace == ns {
+			jobs = append(jobs, j)
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Name < jobs[j].Name
+	})
+	return jobs
+}
+
+// GetJobTasks returns the tasks created by a job ordered by index.
+func (m *Manager) GetJobTasks(ns string, name string) ([]*task.Task, error) {
+	j, err := m.JobDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []*task.Task{}
+	for _, t := range m.GetTasks() {
+		if j.Owns(t) {
+			tasks = append(tasks, t)
+		}
+	}
+	sort.SliceStable(tasks, func(a, b int) bool {
+		return tasks[a].Owner.Index < tasks[b].Owner.Index
+	})
+	return tasks, nil
+}
+
+// DeleteJob stops the active tasks of a job and removes it.
+func (m *Manager) DeleteJob(ns string, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, err := m.JobDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return err
+	}
+
+	err = m.stopJobTasks(j, m.GetTasks())
+	if err != nil {
+		return err
+	}
+	return m.JobDb.Delete(namespace.Key(ns, name))
+}
+
+// RunJobs fans job indexes out as tasks and tracks their completion.
+func (m *Manager) RunJobs(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.runJobs()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Manager) runJobs() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := m.GetTasks()
+	jobs, err := m.JobDb.List()
+	if err != nil {
+		log.Printf("[manager] error listing jobs: %v\n", err)
+		return
+	}
+	for _, j := range jobs {
+		if j.Finished() {
+			continue
+		}
+
+		err := m.runJob(j, tasks, time.Now())
+		if err != nil {
+			log.Printf("[manager] error running job %s: %v\n", j.Name, err)
+		}
+	}
+}
+
+func (m *Manager) runJob(j *job.Job, tasks []*task.Task, now time.Time) error {
+	status := j.Status
+	status.Indexes = make([]job.IndexStatus, j.Completions)
+	status.Active, status.Succeeded, status.Failed = 0, 0, 0
+
+	active := make([]bool, j.Completions)
+	lastFailure := make([]time.Time, j.Completions)
+	for i := range status.Indexes {
+		status.Indexes[i].Index = i
+	}
+
+	for _, t := range tasks {
+		if !j.Owns(t) || t.Owner.Index < 0 || t.Owner.Index >= j.Completions {
+			continue
+		}
+
+		idx := &status.Indexes[t.Owner.Index]
+		switch {
+		case isActive(t):
+			if t.DesiredState != task.Completed {
+				active[idx.Index] = true
+				idx.TaskID = t.ID
+				status.Active++
+			}
+		case t.State == task.Completed && t.DesiredState != task.Completed:
+			if !idx.Succeeded {
+				idx.Succeeded = true
+				idx.TaskID = t.ID
+				status.Succeeded++
+			}
+		case t.State == task.Failed:
+			idx.Failures++
+			status.Failed++
+			if t.FinishTime.After(lastFailure[idx.Index]) {
+				lastFailure[idx.Index] = t.FinishTime
+			}
+		}
+	}
+
+	switch {
+	case status.Succeeded == j.Completions:
+		status.State = job.Complete
+		status.CompletionTime = now.UTC()
+		status.Message = fmt.Sprintf("%d of %d completions succeeded", status.Succeeded, j.Completions)
+
+	case status.Failed > *j.BackoffLimit:
+		status.State = job.Failed
+		status.CompletionTime = now.UTC()
+		status.Message = fmt.Sprintf("backoff limit of %d exceeded with %d failed tasks", *j.BackoffLimit, status.Failed)
+		err := m.stopJobTasks(j, tasks)
+		if err != nil {
+			return err
+		}
+		status.Active = 0
+
+	case j.ActiveDeadlineSeconds > 0 && now.Sub(status.StartTime) >= time.Duration(j.ActiveDeadlineSeconds)*time.Second:
+		status.State = job.Failed
+		status.CompletionTime = now.UTC()
+		status.Message = fmt.Sprintf("active deadline of %ds exceeded", j.ActiveDeadlineSeconds)
+		err := m.stopJobTasks(j, tasks)
+		if err != nil {
+			return err
+		}
+		status.Active = 0
+
+	default:
+		for _, idx := range status.Indexes {
+			if status.Active >= j.Parallelism {
+				break
+			}
+			if idx.Succeeded || active[idx.Index] {
+				continue
+			}
+			if now.Sub(lastFailure[idx.Index]) < job.Backoff(idx.Failures) {
+				continue
+			}
+
+			t := j.NewTask(idx.Index)
+			err := m.admit(&t)
+			if err != nil {
+				log.Printf("[manager] job %s: not starting index %d: %v\n", j.Name, idx.Index, err)
+				break
+			}
+
+			log.Printf("[manager] job %s: starting task %s for index %d\n", j.Name, t.ID, idx.Index)
+			err = m.addTask(task.TaskEvent{
+				ID:        uuid.New(),
+				State:     task.Scheduled,
+				Timestamp: time.Now(),
+				Task:      t,
+			})
+			if err != nil {
+				log.Printf("[manager] job %s: error queueing task %s: %v\n", j.Name, t.ID, err)
+				break
+			}
+			status.Indexes[idx.Index].TaskID = t.ID
+			status.Active++
+		}
+	}
+
+	if reflect.DeepEqual(status, j.Status) {
+		return nil
+	}
+
+	if status.State != j.Status.State {
+		log.Printf("[manager] job %s: %s\n", j.Name, status.Message)
+	}
+	j.Status = status
+	return m.JobDb.Put(j.Key(), j)
+}
+
+func (m *Manager) stopJobTasks(j *job.Job, tasks []*task.Task) error {
+	for _, t := range tasks {
+		if !j.Owns(t) || t.DesiredState == task.Completed || !isActive(t) {
+			continue
+		}
+
+		err := m.requestStop(t.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
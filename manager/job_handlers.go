@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dev6699/cube/job"
+	"github.com/dev6699/cube/namespace"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *Api) CreateJobHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var j job.Job
+	err := d.Decode(&j)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	err = setNamespace(r, &j.Namespace)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = a.Manager.CreateJob(&j)
+	if errors.Is(err, ErrJobExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, j)
+}
+
+func (a *Api) GetJobsHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, a.Manager.GetJobs(namespaceParam(r)))
+}
+
+func (a *Api) GetJobHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	j, err := a.Manager.JobDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no job with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, j)
+}
+
+func (a *Api) GetJobTasksHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	tasks, err := a.Manager.GetJobTasks(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no job with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tasks)
+}
+
+func (a *Api) DeleteJobHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	err := a.Manager.DeleteJob(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no job with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,170 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/job"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+)
+
+func TestRecreatedJobStartsOver(t *testing.T) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zero := 0
+	newJob := func() *job.Job {
+		return &job.Job{
+			Name:         "shards",
+			Namespace:    namespace.Default,
+			BackoffLimit: &zero,
+			Template:     task.Task{Image: "alpine"},
+		}
+	}
+	old := newJob()
+	err = m.CreateJob(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := old.NewTask(0)
+	failed.State = task.Failed
+	failed.FinishTime = time.Now().UTC()
+	err = m.TaskDb.Put(failed.ID.String(), &failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.DeleteJob(old.Namespace, old.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	j := newJob()
+	err = m.CreateJob(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.runJobs()
+
+	j, err = m.JobDb.Get(j.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Status.State != job.Running || j.Status.Failed != 0 || j.Status.Active != 1 {
+		t.Errorf("got status %+v, want the new job running its first task", j.Status)
+	}
+}
+
+// stepJob runs the job controller for j once at now and returns the job's
+// latest state.
+func stepJob(t *testing.T, m *Manager, j *job.Job, now time.Time) *job.Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	latest, err := m.JobDb.Get(j.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.runJob(latest, m.GetTasks(), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest, err = m.JobDb.Get(j.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return latest
+}
+
+// setJobTasks moves the tasks of j that are meant to run to state at now.
+func setJobTasks(t *testing.T, m *Manager, j *job.Job, state task.State, now time.Time) {
+	for _, tk := range m.GetTasks() {
+		if !j.Owns(tk) || tk.DesiredState == task.Completed || !isActive(tk) {
+			continue
+		}
+		tk.State = state
+		if state == task.Failed {
+			tk.FinishTime = now.UTC()
+		}
+		err := m.TaskDb.Put(tk.ID.String(), tk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestJobFailsPastBackoffLimit(t *testing.T) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := 1
+	j := &job.Job{
+		Name:         "shards",
+		Namespace:    namespace.Default,
+		BackoffLimit: &limit,
+		Template:     task.Task{Image: "alpine"},
+	}
+	err = m.CreateJob(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	stepJob(t, m, j, now)
+	setJobTasks(t, m, j, task.Failed, now)
+
+	// The failed index waits out its backoff before it is retried.
+	got := stepJob(t, m, j, now.Add(time.Second))
+	if got.Status.State != job.Running || got.Status.Active != 0 || got.Status.Failed != 1 {
+		t.Errorf("got status %+v during the backoff, want the job waiting to retry", got.Status)
+	}
+	now = now.Add(job.Backoff(1))
+	got = stepJob(t, m, j, now)
+	if got.Status.State != job.Running || got.Status.Active != 1 {
+		t.Errorf("got status %+v after the backoff, want the index retried", got.Status)
+	}
+
+	setJobTasks(t, m, j, task.Failed, now)
+	got = stepJob(t, m, j, now.Add(time.Second))
+	if got.Status.State != job.Failed || got.Status.Failed != 2 {
+		t.Errorf("got status %+v, want the job failed after 2 failures", got.Status)
+	}
+}
+
+func TestJobFailsPastActiveDeadline(t *testing.T) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := &job.Job{
+		Name:                  "shards",
+		Namespace:             namespace.Default,
+		ActiveDeadlineSeconds: 60,
+		Template:              task.Task{Image: "alpine"},
+	}
+	err = m.CreateJob(j)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := j.Status.StartTime
+	stepJob(t, m, j, start)
+	setJobTasks(t, m, j, task.Running, start)
+	got := stepJob(t, m, j, start.Add(59*time.Second))
+	if got.Status.State != job.Running || got.Status.Active != 1 {
+		t.Fatalf("got status %+v before the deadline, want the job running", got.Status)
+	}
+
+	got = stepJob(t, m, j, start.Add(60*time.Second))
+	if got.Status.State != job.Failed || got.Status.Active != 0 {
+		t.Errorf("got status %+v at the deadline, want the job failed", got.Status)
+	}
+	for _, tk := range m.GetTasks() {
+		if j.Owns(tk) && tk.DesiredState != task.Completed {
+			t.Errorf("task %s of the failed job was not stopped", tk.ID)
+		}
+	}
+}
@@ -1,28 +1,45 @@
 package manager
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"net/url"
+	"reflect"
+	"slices"
+	"sync"
 	"time"
 
+	"github.com/dev6699/cube/auth"
+	"github.com/dev6699/cube/cronjob"
+	"github.com/dev6699/cube/job"
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
 	"github.com/dev6699/cube/node"
 	"github.com/dev6699/cube/queue"
 	"github.com/dev6699/cube/scheduler"
+	"github.com/dev6699/cube/service"
 	"github.com/dev6699/cube/stats"
 	"github.com/dev6699/cube/store"
 	"github.com/dev6699/cube/task"
-	"github.com/dev6699/cube/worker"
+	"github.com/dev6699/cube/watch"
+	"github.com/dev6699/cube/workflow"
 	"github.com/docker/go-connections/nat"
 	"github.com/google/uuid"
 )
 
+// Manager state is shared by the API handlers and the control loops. mu
+// guards the worker maps, WorkerNodes and the nodes in it, the autoscaler's
+// samples, and makes read-modify-write cycles on the stores atomic. It is
+// never held while talking to workers, so a slow worker cannot stall the
+// API. Pending and the stores are safe for concurrent use on their own.
 type Manager struct {
-	Pending       queue.Queue[task.TaskEvent]
+	mu sync.Mutex
+
+	Pending       queue.PriorityQueue[task.TaskEvent]
 	TaskDb        store.Store[*task.Task]
 	EventDb       store.Store[*task.TaskEvent]
 	Workers       []string
@@ -31,8 +48,70 @@ type Manager struct {
 	LastWorker    int
 	WorkerNodes   []*node.Node
 	Scheduler     scheduler.Scheduler
+	Hub           *watch.Hub
+	ServiceDb     store.Store[*service.Service]
+	CronJobDb     store.Store[*cronjob.CronJob]
+	JobDb         store.Store[*job.Job]
+	WorkflowDb    store.Store[*workflow.Workflow]
+	NamespaceDb   store.Store[*namespace.Namespace]
+	DeadLetterDb  store.Store[*task.DeadLetter]
+	SubmissionDb  store.Store[*task.Submission]
+	TokenDb       store.Store[*auth.Token]
+
+	// NodeGracePeriod is how long a node may be unreachable before its
+	// tasks are rescheduled onto other nodes.
+	NodeGracePeriod time.Duration
+
+	// DispatchConcurrency bounds the number of requests sent to workers
+	// at the same time. DispatchRate is the number of tasks per second
+	// sent to a single worker, with bursts of up to DispatchBurst.
+	DispatchConcurrency int
+	DispatchRate        float64
+	DispatchBurst       int
+
+	// MaxDispatchAttempts is how often delivering a task to a worker may
+	// fail before the task is moved to the dead letters.
+	MaxDispatchAttempts int
+
+	// ReconcileInterval is how often the tasks the workers run are
+	// compared with the desired state of the tasks.
+	ReconcileInterval time.Duration
+
+	// Client sends the requests to workers, and its transport carries the
+	// task logs the API proxies from them. UseClusterToken makes them
+	// carry the cluster token.
+	Client *http.Client
+
+	// StopUnknownTasks makes reconciliation stop the tasks workers run
+	// that the manager does not know. Otherwise they are only reported:
+	// a manager with memory stores that restarted, or a replica that took
+	// over before every task reached it, does not know all its tasks.
+	StopUnknownTasks bool
+
+	// IdempotencyKeyTTL is how long the task submitted with an
+	// idempotency key is returned for repeated submissions.
+	IdempotencyKeyTTL time.Duration
+
+	wake      chan struct{}
+	sendOnce  sync.Once
+	sendSlots chan struct{}
+	sends     sync.WaitGroup
+	limiters  map[string]*tokenBucket
+
+	// dispatching holds the tasks on their way to a worker, and leases
+	// those a worker accepted but has not been seen running yet.
+	dispatching map[uuid.UUID]delivery
+	leases      map[uuid.UUID]lease
+
+	fenced          map[string][]uuid.UUID
+	drifts          map[drift]time.Time
+	taskUsage       map[uuid.UUID]task.Usage
+	recommendations map[string][]recommendation
 }
 
+// workerTimeout is how long a worker may take to answer a request.
+const workerTimeout = 10 * time.Second
+
 func New(workers []string, schedulerType string, dbType string) (*Manager, error) {
 	var nodes []*node.Node
 	workerTaskMap := make(map[string][]uuid.UUID)
@@ -44,13 +123,14 @@ func New(workers []string, schedulerType string, dbType string) (*Manager, error
 		nodes = append(nodes, n)
 	}
 
+	client := &http.Client{Timeout: workerTimeout}
 	var s scheduler.Scheduler
 	switch schedulerType {
 	case "roundrobin":
 		s = &scheduler.RoundRobin{Name: "roundrobin"}
 
 	case "epvm":
-		s = &scheduler.Epvm{Name: "epvm"}
+		s = &scheduler.Epvm{Name: "epvm", Client: client}
 
 	default:
 		s = &scheduler.RoundRobin{Name: "roundrobin"}
@@ -58,10 +138,28 @@ func New(workers []string, schedulerType string, dbType string) (*Manager, error
 
 	var ts store.Store[*task.Task]
 	var es store.Store[*task.TaskEvent]
+	var ss store.Store[*service.Service]
+	var cs store.Store[*cronjob.CronJob]
+	var js store.Store[*job.Job]
+	var ws store.Store[*workflow.Workflow]
+	var ns store.Store[*namespace.Namespace]
+	var ds store.Store[*task.DeadLetter]
+	var sbs store.Store[*task.Submission]
+	var tks store.Store[*auth.Token]
+	var pq queue.PriorityQueue[task.TaskEvent]
 	switch dbType {
 	case "memory":
+		pq = queue.NewInMemoryPriorityQueue(eventPriority)
 		ts = store.NewInMemoryStore[*task.Task]()
 		es = store.NewInMemoryStore[*task.TaskEvent]()
+		ss = store.NewInMemoryStore[*service.Service]()
+		cs = store.NewInMemoryStore[*cronjob.CronJob]()
+		js = store.NewInMemoryStore[*job.Job]()
+		ws = store.NewInMemoryStore[*workflow.Workflow]()
+		ns = store.NewInMemoryStore[*namespace.Namespace]()
+		ds = store.NewInMemoryStore[*task.DeadLetter]()
+		sbs = store.NewInMemoryStore[*task.Submission]()
+		tks = store.NewInMemoryStore[*auth.Token]()
 
 	case "bolt":
 		var err error
@@ -73,10 +171,46 @@ func New(workers []string, schedulerType string, dbType string) (*Manager, error
 		if err != nil {
 			return nil, err
 		}
+		ss, err = store.NewBoltStore[*service.Service]("services.db", 0600, "services")
+		if err != nil {
+			return nil, err
+		}
+		cs, err = store.NewBoltStore[*cronjob.CronJob]("cronjobs.db", 0600, "cronjobs")
+		if err != nil {
+			return nil, err
+		}
+		js, err = store.NewBoltStore[*job.Job]("jobs.db", 0600, "jobs")
+		if err != nil {
+			return nil, err
+		}
+		ws, err = store.NewBoltStore[*workflow.Workflow]("workflows.db", 0600, "workflows")
+		if err != nil {
+			return nil, err
+		}
+		ns, err = store.NewBoltStore[*namespace.Namespace]("namespaces.db", 0600, "namespaces")
+		if err != nil {
+			return nil, err
+		}
+		ds, err = store.NewBoltStore[*task.DeadLetter]("deadletters.db", 0600, "deadletters")
+		if err != nil {
+			return nil, err
+		}
+		sbs, err = store.NewBoltStore[*task.Submission]("submissions.db", 0600, "submissions")
+		if err != nil {
+			return nil, err
+		}
+		tks, err = store.NewBoltStore[*auth.Token]("tokens.db", 0600, "tokens")
+		if err != nil {
+			return nil, err
+		}
+		pq, err = queue.NewBoltPriorityQueue("pending.db", 0600, "pending", eventPriority)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &Manager{
-		Pending:       queue.Queue[task.TaskEvent]{},
+	m := &Manager{
+		Pending:       pq,
 		Workers:       workers,
 		TaskDb:        ts,
 		EventDb:       es,
@@ -85,119 +219,301 @@ func New(workers []string, schedulerType string, dbType string) (*Manager, error
 		LastWorker:    0,
 		WorkerNodes:   nodes,
 		Scheduler:     s,
-	}, nil
+		Hub:           watch.NewHub(1000),
+		ServiceDb:     ss,
+		CronJobDb:     cs,
+		JobDb:         js,
+		WorkflowDb:    ws,
+		NamespaceDb:   ns,
+		DeadLetterDb:  ds,
+		SubmissionDb:  sbs,
+		TokenDb:       tks,
+
+		NodeGracePeriod: DefaultNodeGracePeriod,
+
+		DispatchConcurrency: DefaultDispatchConcurrency,
+		DispatchRate:        DefaultDispatchRate,
+		DispatchBurst:       DefaultDispatchBurst,
+		MaxDispatchAttempts: DefaultMaxDispatchAttempts,
+
+		ReconcileInterval: DefaultReconcileInterval,
+		IdempotencyKeyTTL: DefaultIdempotencyKeyTTL,
+
+		Client: client,
+
+		wake:     make(chan struct{}, 1),
+		limiters: make(map[string]*tokenBucket),
+
+		dispatching: make(map[uuid.UUID]delivery),
+		leases:      make(map[uuid.UUID]lease),
+
+		fenced:          make(map[string][]uuid.UUID),
+		drifts:          make(map[drift]time.Time),
+		taskUsage:       make(map[uuid.UUID]task.Usage),
+		recommendations: make(map[string][]recommendation),
+	}
+
+	err := m.ensureDefaultNamespace()
+	if err != nil {
+		return nil, err
+	}
+	err = m.restoreAssignments()
+	if err != nil {
+		return nil, err
+	}
+	err = m.recoverPending()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func (m *Manager) AddTask(te task.TaskEvent) {
-	m.Pending.Enqueue(te)
+// Run runs the manager's control loops until ctx is done and they have
+// returned.
+func (m *Manager) Run(ctx context.Context) {
+	loops := []func(context.Context) error{
+		m.ProcessTasks,
+		m.UpdateTasks,
+		m.DoHealthChecks,
+		m.UpdateNodeStats,
+		m.Reconcile,
+		m.ReconcileServices,
+		m.Autoscale,
+		m.RunCronJobs,
+		m.RunJobs,
+		m.RunWorkflows,
+		m.ExpireIdempotencyKeys,
+	}
+
+	var wg sync.WaitGroup
+	for _, loop := range loops {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loop(ctx)
+		}()
+	}
+	wg.Wait()
 }
 
-func (m *Manager) GetTasks() []*task.Task {
+// restoreAssignments rebuilds the worker maps from the workers recorded on
+// the tasks, e.g. after a restart with the bolt store. Nodes with active
+// tasks start out NotReady, so that their tasks are rescheduled if they do
+// not answer within the grace period. Workers that had registered
+// themselves get a node without an address until they register again.
+func (m *Manager) restoreAssignments() error {
 	tasks, err := m.TaskDb.List()
 	if err != nil {
-		return []*task.Task{}
+		return err
 	}
 
-	return tasks
-}
+	now := time.Now().UTC()
+	for _, t := range tasks {
+		if t.Worker == "" {
+			continue
+		}
+		m.WorkerTaskMap[t.Worker] = append(m.WorkerTaskMap[t.Worker], t.ID)
+		m.TaskWorkerMap[t.ID] = t.Worker
 
-func (m *Manager) SelectWorker(t task.Task) (*node.Node, error) {
-	candidates := m.Scheduler.SelectCandidateNodes(t, m.WorkerNodes)
-	if candidates == nil {
-		return nil, fmt.Errorf("[manager] no available candidate for task: %s", t.ID)
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+		n := m.workerNode(t.Worker)
+		if n == nil {
+			log.Printf("[manager] waiting for worker %s to register again\n", t.Worker)
+			n = node.New(t.Worker, "", "worker")
+			m.WorkerNodes = append(m.WorkerNodes, n)
+			m.Workers = append(m.Workers, n.Name)
+		}
+		if n.Status != node.NotReady {
+			n.Status = node.NotReady
+			n.LastSeen = now
+		}
 	}
+	return nil
+}
 
-	scores := m.Scheduler.Score(t, candidates)
-	selectedNode := m.Scheduler.Pick(scores, candidates)
-	return selectedNode, nil
+// AddTask records te's task as Pending and queues te for dispatch.
+func (m *Manager) AddTask(te task.TaskEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.addTask(te)
 }
 
-func (m *Manager) SendWork() error {
-	if m.Pending.Len() == 0 {
-		return nil
+// addTask is AddTask with m.mu held.
+func (m *Manager) addTask(te task.TaskEvent) error {
+	if te.State != task.Completed {
+		_, err := m.TaskDb.Get(te.Task.ID.String())
+		if errors.Is(err, store.ErrNotFound) {
+			if te.Task.Namespace == "" {
+				te.Task.Namespace = namespace.Default
+			}
+			t := te.Task
+			t.State = task.Pending
+			t.DesiredState = task.Running
+			err = m.saveTask(&t)
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			log.Printf("[manager] error saving pending task %s: %v\n", te.Task.ID, err)
+		}
 	}
 
-	te, ok := m.Pending.Dequeue()
-	if !ok {
-		return nil
+	err := m.Pending.Enqueue(te)
+	if err != nil {
+		return fmt.Errorf("error queueing task %s: %w", te.Task.ID, err)
 	}
+	m.notify()
+	return nil
+}
+
+// StopTask requests that the task with the given ID is stopped. Tasks that
+// have not been sent to a worker yet are cancelled when dequeued.
+func (m *Manager) StopTask(taskID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.requestStop(taskID)
+}
 
-	err := m.EventDb.Put(te.ID.String(), &te)
+// requestStop is StopTask with m.mu held.
+func (m *Manager) requestStop(taskID uuid.UUID) error {
+	t, err := m.TaskDb.Get(taskID.String())
 	if err != nil {
 		return err
 	}
 
-	t := te.Task
-	taskWorker, ok := m.TaskWorkerMap[t.ID]
-	if ok {
-		persistedTask, err := m.TaskDb.Get(t.ID.String())
-		if err != nil {
-			return err
-		}
-		if te.State == task.Completed &&
-			task.ValidStateTransiton(persistedTask.State, te.State) {
-			return m.stopTask(taskWorker, t.ID.String())
-		}
-
-		log.Printf("[manager] invalid request: existing task is %s is in state %d and cannot transition to the completed state\n",
-			persistedTask.ID.String(), persistedTask.State)
-		return nil
+	t.DesiredState = task.Completed
+	err = m.saveTask(t)
+	if err != nil {
+		return err
 	}
 
-	w, err := m.SelectWorker(t)
+	taskCopy := *t
+	taskCopy.State = task.Completed
+	return m.addTask(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Completed,
+		Timestamp: time.Now(),
+		Task:      taskCopy,
+	})
+}
+
+func (m *Manager) GetTasks() []*task.Task {
+	tasks, err := m.TaskDb.List()
 	if err != nil {
-		return err
+		return []*task.Task{}
 	}
 
-	m.WorkerTaskMap[w.Name] = append(m.WorkerTaskMap[w.Name], t.ID)
-	m.TaskWorkerMap[t.ID] = w.Name
+	return tasks
+}
 
-	t.State = task.Scheduled
-	m.TaskDb.Put(t.ID.String(), &t)
+// saveTask persists t and notifies watchers of the change. m.mu must be
+// held.
+func (m *Manager) saveTask(t *task.Task) error {
+	eventType := watch.Modified
+	_, err := m.TaskDb.Get(t.ID.String())
+	if errors.Is(err, store.ErrNotFound) {
+		eventType = watch.Added
+	}
 
-	data, err := json.Marshal(te)
+	err = m.TaskDb.Put(t.ID.String(), t)
 	if err != nil {
 		return err
 	}
 
-	url := fmt.Sprintf("http://%s/tasks", w.Name)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	taskCopy := *t
+	m.Hub.Publish(watch.Event{
+		Type: eventType,
+		Kind: watch.KindTask,
+		Task: &taskCopy,
+	})
+	return nil
+}
+
+// deleteTask removes t from the task store and the worker maps and
+// notifies watchers. m.mu must be held.
+func (m *Manager) deleteTask(t *task.Task) error {
+	err := m.TaskDb.Delete(t.ID.String())
 	if err != nil {
-		m.Pending.Enqueue(te)
 		return err
 	}
 
-	d := json.NewDecoder(resp.Body)
-	if resp.StatusCode != http.StatusCreated {
-		var e worker.ErrResponse
-		err := d.Decode(&e)
-		if err != nil {
-			return err
-		}
+	taskCopy := *t
+	m.unassignTask(t)
+	m.Hub.Publish(watch.Event{
+		Type: watch.Deleted,
+		Kind: watch.KindTask,
+		Task: &taskCopy,
+	})
+	return nil
+}
+
+// SelectWorker picks a schedulable node with room for t, avoiding the
+// nodes in exclude unless no other node can run t. The returned node is a
+// copy.
+func (m *Manager) SelectWorker(t task.Task, exclude []string) (*node.Node, error) {
+	m.mu.Lock()
+	candidates := m.Scheduler.SelectCandidateNodes(t, m.schedulableNodes())
+	preferred := slices.DeleteFunc(slices.Clone(candidates), func(n *node.Node) bool {
+		return slices.Contains(exclude, n.Name)
+	})
+	if len(preferred) > 0 {
+		candidates = preferred
+	}
+	if candidates == nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("[manager] task %s: %w", t.ID, errNoCandidate)
 	}
 
-	var createdTask task.Task
-	err = d.Decode(&createdTask)
-	if err != nil {
-		return err
+	placed := m.nodeTasks()
+	var fitting []*node.Node
+	for _, n := range candidates {
+		if fits(&t, n, allocated(placed[n.Name])) {
+			fitting = append(fitting, n.Clone())
+		}
 	}
-	log.Printf("[manager] task sent %#v\n", t)
-	return nil
+	m.mu.Unlock()
+	if len(fitting) == 0 {
+		return nil, fmt.Errorf("[manager] task %s: %w", t.ID, errNoFit)
+	}
+
+	// Scoring may query the nodes, so it happens without holding m.mu.
+	scores := m.Scheduler.Score(t, fitting)
+	selectedNode := m.Scheduler.Pick(scores, fitting)
+	return selectedNode, nil
 }
 
+// stopTask asks worker to stop the task for good. m.mu must not be held.
 func (m *Manager) stopTask(worker string, taskID string) error {
-	client := &http.Client{}
-	url := fmt.Sprintf("http://%s/tasks/%s", worker, taskID)
+	return m.sendStop(worker, taskID, false)
+}
+
+// preemptTask asks worker to stop the task so that it can be placed again,
+// possibly on the same worker. m.mu must not be held.
+func (m *Manager) preemptTask(worker string, taskID string) error {
+	return m.sendStop(worker, taskID, true)
+}
+
+func (m *Manager) sendStop(worker string, taskID string, preempt bool) error {
+	url := fmt.Sprintf("%s/tasks/%s", m.workerApi(worker), taskID)
+	if preempt {
+		url += "?preempt=true"
+	}
 	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		return err
 	}
 
-	resp, err := client.Do(req)
+	resp, err := m.Client.Do(req)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return errTaskNotOnWorker
+	}
 	if resp.StatusCode != http.StatusNoContent {
 		return fmt.Errorf("invalid status code: %d", resp.StatusCode)
 	}
@@ -205,26 +521,13 @@ func (m *Manager) stopTask(worker string, taskID string) error {
 	return nil
 }
 
-func (m *Manager) ProcessTasks(ctx context.Context) error {
-	for {
-		select {
-		case <-time.NewTicker(10 * time.Second).C:
-			log.Println("[manager] processing tasks:", m.Pending.Len())
-			err := m.SendWork()
-			if err != nil {
-				log.Printf("[manager] error send task to worker: %v\n", err)
-			}
-
-		case <-ctx.Done():
-			return nil
-		}
-	}
-}
-
 func (m *Manager) UpdateTasks(ctx context.Context) error {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-time.NewTicker(15 * time.Second).C:
+		case <-ticker.C:
 			log.Println("[manager] updating tasks")
 			err := m.updateTasks()
 			if err != nil {
@@ -237,54 +540,109 @@ func (m *Manager) UpdateTasks(ctx context.Context) error {
 	}
 }
 
+// updateTasks polls every worker for the state of its tasks. An
+// unreachable worker does not keep the others from being polled.
 func (m *Manager) updateTasks() error {
-	for _, worker := range m.Workers {
-		url := fmt.Sprintf("http://%s/tasks", worker)
-		resp, err := http.Get(url)
+	var errs []error
+	for _, n := range m.GetNodes(labels.Selector{}) {
+		err := m.updateNodeTasks(n)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("node %s: %w", n.Name, err))
 		}
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("invalid status %v", resp.StatusCode)
-		}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) updateNodeTasks(n *node.Node) error {
+	worker := n.Name
+	tasks, err := m.getNodeTasks(n)
+	if err != nil {
+		return err
+	}
 
-		d := json.NewDecoder(resp.Body)
-		var tasks []*task.Task
-		err = d.Decode(&tasks)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range tasks {
+		key := t.ID.String()
+		taskPersisted, err := m.TaskDb.Get(key)
 		if err != nil {
-			return err
+			continue
+		}
+		// Reports from a worker the task has been moved away from, or
+		// for a preempted task waiting to be scheduled again, are stale.
+		w, ok := m.TaskWorkerMap[t.ID]
+		if (ok && w != worker) || taskPersisted.State == task.Pending || t.State == task.Preempted {
+			continue
 		}
 
-		for _, t := range tasks {
-			key := t.ID.String()
-			taskPersisted, err := m.TaskDb.Get(key)
-			if err != nil {
-				continue
-			}
+		// Tasks placed before their worker was recorded on them are
+		// adopted by the worker that runs them.
+		adopted := false
+		if !ok && (taskPersisted.State == task.Scheduled || taskPersisted.State == task.Running) {
+			log.Printf("[manager] task %s is placed on worker %s\n", t.ID, worker)
+			m.assignTask(taskPersisted, worker)
+			adopted = true
+		}
 
-			taskPersisted.State = t.State
-			taskPersisted.StartTime = t.StartTime
-			taskPersisted.FinishTime = t.FinishTime
-			taskPersisted.ContainerID = t.ContainerID
-			taskPersisted.HostPorts = t.HostPorts
+		if !adopted &&
+			taskPersisted.State == t.State &&
+			taskPersisted.StartTime.Equal(t.StartTime) &&
+			taskPersisted.FinishTime.Equal(t.FinishTime) &&
+			taskPersisted.ContainerID == t.ContainerID &&
+			reflect.DeepEqual(taskPersisted.HostPorts, t.HostPorts) {
+			continue
+		}
 
-			m.TaskDb.Put(key, taskPersisted)
+		// A task that started or finished may unblock pending events:
+		// a stop waiting for it to run, or tasks waiting for its room.
+		if taskPersisted.State != t.State {
+			m.notify()
 		}
+		taskPersisted.State = t.State
+		taskPersisted.StartTime = t.StartTime
+		taskPersisted.FinishTime = t.FinishTime
+		taskPersisted.ContainerID = t.ContainerID
+		taskPersisted.HostPorts = t.HostPorts
+
+		m.saveTask(taskPersisted)
 	}
 
 	return nil
 }
 
+// getNodeTasks returns the tasks n reports.
+func (m *Manager) getNodeTasks(n *node.Node) ([]*task.Task, error) {
+	url := fmt.Sprintf("%s/tasks", n.Api)
+	resp, err := m.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("invalid status %v", resp.StatusCode)
+	}
+
+	var tasks []*task.Task
+	err = json.NewDecoder(resp.Body).Decode(&tasks)
+	return tasks, err
+}
+
 func (m *Manager) UpdateNodeStats(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-time.NewTicker(5 * time.Second).C:
+		case <-ticker.C:
 			log.Println("[manager] updating node stats")
 			err := m.checkNodeStats()
 			if err != nil {
 				log.Printf("[manager] failed to update node stats: %v\n", err)
 			}
+			m.checkNodeHealth()
+			m.drainNodes()
 
 		case <-ctx.Done():
 			return nil
@@ -292,35 +650,47 @@ func (m *Manager) UpdateNodeStats(ctx context.Context) error {
 	}
 }
 
+// checkNodeStats polls every worker for its stats and updates the health
+// of its node.
 func (m *Manager) checkNodeStats() error {
-	for i, n := range m.WorkerNodes {
-		url := fmt.Sprintf("%s/stats", n.Api)
-		resp, err := http.Get(url)
+	var errs []error
+	for _, n := range m.GetNodes(labels.Selector{}) {
+		s, err := m.getNodeStats(n)
 		if err != nil {
-			return err
+			m.nodeUnreachable(n.Name, err)
+			errs = append(errs, fmt.Errorf("node %s: %w", n.Name, err))
+			continue
 		}
+		m.nodeReachable(n.Name, s)
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return fmt.Errorf("invalid status code: %v", resp.StatusCode)
-		}
+	return errors.Join(errs...)
+}
 
-		d := json.NewDecoder(resp.Body)
-		var newStat stats.Stats
-		err = d.Decode(&newStat)
-		if err != nil {
-			return err
-		}
+func (m *Manager) getNodeStats(n *node.Node) (stats.Stats, error) {
+	var s stats.Stats
+	url := fmt.Sprintf("%s/stats", n.Api)
+	resp, err := m.Client.Get(url)
+	if err != nil {
+		return s, err
+	}
+	defer resp.Body.Close()
 
-		m.WorkerNodes[i].Stats = newStat
+	if resp.StatusCode != http.StatusOK {
+		return s, fmt.Errorf("invalid status code: %v", resp.StatusCode)
 	}
 
-	return nil
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	return s, err
 }
 
 func (m *Manager) DoHealthChecks(ctx context.Context) error {
+	ticker := time.NewTicker(60 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-time.NewTicker(60 * time.Second).C:
+		case <-ticker.C:
 			log.Println("[manager] checking health")
 			m.doHealthChecks()
 
@@ -345,7 +715,7 @@ func (m *Manager) doHealthChecks() {
 					}
 				}
 			}
-		} else if t.State == task.Failed && t.RestartCount < maxRestart {
+		} else if t.State == task.Failed && t.RestartCount < maxRestart && t.Owner == nil && !m.isDeadLetter(t.ID) {
 			log.Println("[manager] restarting failed task:", t.ID)
 			err := m.restartTask(t)
 			if err != nil {
@@ -355,67 +725,65 @@ func (m *Manager) doHealthChecks() {
 	}
 }
 
+// restartTask hands t back to the scheduler. A copy that still runs on its
+// worker, e.g. because it failed its health check, is stopped first.
 func (m *Manager) restartTask(t *task.Task) error {
-	w := m.TaskWorkerMap[t.ID]
-	t.State = task.Scheduled
-	t.RestartCount++
-	m.TaskDb.Put(t.ID.String(), t)
-
-	te := task.TaskEvent{
-		ID:        uuid.New(),
-		State:     task.Running,
-		Timestamp: time.Now(),
-		Task:      *t,
-	}
-	data, err := json.Marshal(&te)
-	if err != nil {
-		return err
-	}
-
-	url := fmt.Sprintf("http://%s/tasks", w)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(data))
+	m.mu.Lock()
+	t, err := m.TaskDb.Get(t.ID.String())
 	if err != nil {
-		m.Pending.Enqueue(te)
+		m.mu.Unlock()
 		return err
 	}
+	w, ok := m.TaskWorkerMap[t.ID]
+	running := t.State == task.Running
+	m.mu.Unlock()
 
-	d := json.NewDecoder(resp.Body)
-	if resp.StatusCode != http.StatusCreated {
-		var e worker.ErrResponse
-		err := d.Decode(&e)
-		if err != nil {
+	if ok && running {
+		err := m.preemptTask(w, t.ID.String())
+		if err != nil && !errors.Is(err, errTaskNotOnWorker) {
 			return err
 		}
-
-		return fmt.Errorf("failed to restart task; err = %v", e)
 	}
 
-	var newTask task.Task
-	err = d.Decode(&newTask)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, err = m.TaskDb.Get(t.ID.String())
 	if err != nil {
 		return err
 	}
-
-	return nil
+	t.RestartCount++
+	return m.requeueTask(t)
 }
 
+// healthCheckTimeout is how long a task may take to answer its health
+// check.
+const healthCheckTimeout = 5 * time.Second
+
+// healthCheckClient sends the health checks of tasks. Unlike Client it
+// never carries the cluster token, which the tasks must not get hold of.
+var healthCheckClient = &http.Client{Timeout: healthCheckTimeout}
+
 func (m *Manager) checkTaskHealth(t task.Task) error {
+	m.mu.Lock()
 	w := m.TaskWorkerMap[t.ID]
+	m.mu.Unlock()
 	hostPort := getHostPort(t.HostPorts)
 	if hostPort == nil {
 		return fmt.Errorf("invalid hostPort")
 	}
 
-	workerHost := strings.Split(w, ":")
-	if len(workerHost) < 1 {
+	api, err := url.Parse(m.workerApi(w))
+	if err != nil || api.Hostname() == "" {
 		return fmt.Errorf("invalid worker host")
 	}
 
-	url := fmt.Sprintf("http://%s:%s%s", workerHost[0], *hostPort, t.HealthCheck)
-	resp, err := http.Get(url)
+	url := fmt.Sprintf("http://%s:%s%s", api.Hostname(), *hostPort, t.HealthCheck)
+	resp, err := healthCheckClient.Get(url)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("invalid status code: %v", resp.StatusCode)
@@ -0,0 +1,521 @@
+package manager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/worker"
+	"github.com/docker/go-connections/nat"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// fakeWorker serves the worker API and runs every task it is sent. Like a
+// worker, it accepts each event only once. Its health check endpoint always
+// succeeds.
+type fakeWorker struct {
+	mu       sync.Mutex
+	tasks    map[uuid.UUID]*task.Task
+	accepted map[uuid.UUID]uuid.UUID
+	srv      *httptest.Server
+
+	// failures are the status codes the next requests to start a task
+	// are answered with. Once they are used up, tasks are accepted with
+	// lease, and run unless stalled is set.
+	failures []int
+	lease    time.Duration
+	stalled  bool
+	requests int
+}
+
+func newFakeWorker(t *testing.T) *fakeWorker {
+	fw := &fakeWorker{
+		tasks:    make(map[uuid.UUID]*task.Task),
+		accepted: make(map[uuid.UUID]uuid.UUID),
+	}
+
+	r := chi.NewRouter()
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	r.Get("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	})
+	r.Route("/tasks", func(r chi.Router) {
+		r.Post("/", fw.startTask)
+		r.Get("/", fw.getTasks)
+		r.Get("/stats", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+		})
+		r.Delete("/{taskID}", fw.stopTask)
+	})
+	r.Get("/v1/tasks/{taskID}/logs", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintf(w, "logs of %s, tail %s\n", chi.URLParam(r, "taskID"), r.URL.Query().Get("tail"))
+	})
+	fw.srv = httptest.NewServer(r)
+	t.Cleanup(fw.srv.Close)
+	return fw
+}
+
+func (fw *fakeWorker) startTask(w http.ResponseWriter, r *http.Request) {
+	var te task.TaskEvent
+	err := json.NewDecoder(r.Body).Decode(&te)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	u, _ := url.Parse(fw.srv.URL)
+	t := te.Task
+	t.State = task.Running
+	t.StartTime = time.Now().UTC()
+	t.HostPorts = nat.PortMap{"80/tcp": {{HostPort: u.Port()}}}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	fw.requests++
+	if len(fw.failures) > 0 {
+		status := fw.failures[0]
+		fw.failures = fw.failures[1:]
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(worker.ErrResponse{HTTPStatusCode: status, Message: "failed"})
+		return
+	}
+	ack := worker.Ack{TaskID: t.ID, Lease: fw.lease}
+	if fw.accepted[t.ID] == te.ID {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(ack)
+		return
+	}
+	fw.accepted[t.ID] = te.ID
+	if !fw.stalled {
+		fw.tasks[t.ID] = &t
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ack)
+}
+
+func (fw *fakeWorker) getTasks(w http.ResponseWriter, r *http.Request) {
+	fw.mu.Lock()
+	tasks := []task.Task{}
+	for _, t := range fw.tasks {
+		tasks = append(tasks, *t)
+	}
+	fw.mu.Unlock()
+
+	json.NewEncoder(w).Encode(tasks)
+}
+
+func (fw *fakeWorker) stopTask(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	t, ok := fw.tasks[id]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	t.State = task.Completed
+	if r.URL.Query().Get("preempt") == "true" {
+		t.State = task.Preempted
+	}
+	t.FinishTime = time.Now().UTC()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// newTestManager starts a manager with n fake workers that are all Ready,
+// and an API server for it.
+func newTestManager(t *testing.T, n int) (*Manager, *httptest.Server) {
+	var names []string
+	for i := 0; i < n; i++ {
+		names = append(names, fmt.Sprintf("worker-%d", i))
+	}
+
+	m, err := New(names, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, wn := range m.WorkerNodes {
+		wn.Api = newFakeWorker(t).srv.URL
+	}
+	err = m.checkNodeStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := NewApi("", 0, m)
+	api.initRouter()
+	srv := httptest.NewServer(api.Router)
+	t.Cleanup(srv.Close)
+	return m, srv
+}
+
+// requestOption adds headers to a request sent by doRequest or reads its
+// response.
+type requestOption func(*testRequest)
+
+type testRequest struct {
+	header     http.Header
+	out        any
+	respHeader *http.Header
+}
+
+// withHeader sets a request header, unless value is empty.
+func withHeader(key string, value string) requestOption {
+	return func(r *testRequest) {
+		if value != "" {
+			r.header.Set(key, value)
+		}
+	}
+}
+
+// withToken sends token as bearer token, unless it is empty.
+func withToken(token string) requestOption {
+	if token == "" {
+		return func(r *testRequest) {}
+	}
+	return withHeader("Authorization", "Bearer "+token)
+}
+
+// decodeInto decodes the response body, if there is one, into out.
+func decodeInto(out any) requestOption {
+	return func(r *testRequest) {
+		r.out = out
+	}
+}
+
+// responseHeader stores the response headers in h.
+func responseHeader(h *http.Header) requestOption {
+	return func(r *testRequest) {
+		r.respHeader = h
+	}
+}
+
+// doRequest sends body as JSON to url and returns the response status. It
+// reports errors with t.Error, so that it can be used from any goroutine,
+// and returns 0 then.
+func doRequest(t *testing.T, method string, url string, body any, opts ...requestOption) int {
+	r := testRequest{header: http.Header{}}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	var buf bytes.Buffer
+	if body != nil {
+		err := json.NewEncoder(&buf).Encode(body)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+	}
+
+	req, err := http.NewRequest(method, url, &buf)
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Error(err)
+		return 0
+	}
+	defer resp.Body.Close()
+
+	if r.respHeader != nil {
+		*r.respHeader = resp.Header
+	}
+	if r.out != nil {
+		err = json.NewDecoder(resp.Body).Decode(r.out)
+		if err != nil && !errors.Is(err, io.EOF) {
+			t.Errorf("%s %s: %v", method, url, err)
+		}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode
+}
+
+func newTaskEvent(ns string) task.TaskEvent {
+	id := uuid.New()
+	return task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task: task.Task{
+			ID:        id,
+			Name:      "task-" + id.String(),
+			Image:     "alpine",
+			Namespace: ns,
+		},
+	}
+}
+
+// runLoops calls every control loop body repeatedly until ctx is done.
+func runLoops(ctx context.Context, m *Manager) *sync.WaitGroup {
+	var wg sync.WaitGroup
+	loop := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				f()
+				time.Sleep(10 * time.Millisecond)
+			}
+		}()
+	}
+
+	loop(func() { m.dispatchPending(ctx) })
+	loop(func() { m.updateTasks() })
+	loop(func() { m.checkNodeStats() })
+	loop(func() { m.checkNodeHealth() })
+	loop(func() { m.drainNodes() })
+	loop(func() { m.reconcile() })
+	loop(m.doHealthChecks)
+	loop(m.reconcileServices)
+	loop(func() {
+		m.collectTaskUsage()
+		m.autoscaleServices()
+	})
+	loop(m.runCronJobs)
+	loop(m.runJobs)
+	loop(m.runWorkflows)
+	return &wg
+}
+
+func TestConcurrentApiAndLoops(t *testing.T) {
+	m, api := newTestManager(t, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	loops := runLoops(ctx, m)
+	defer func() {
+		cancel()
+		loops.Wait()
+		m.sends.Wait()
+	}()
+
+	status := doRequest(t, http.MethodPost, api.URL+"/services", service.Service{
+		Name:     "web",
+		Replicas: 2,
+		Template: task.Task{Image: "nginx"},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create service: got status %d", status)
+	}
+
+	var mu sync.Mutex
+	var running, stopped []uuid.UUID
+	var clients sync.WaitGroup
+	for c := 0; c < 8; c++ {
+		clients.Add(1)
+		go func(c int) {
+			defer clients.Done()
+			node := fmt.Sprintf("worker-%d", c%3)
+			for i := 0; i < 10; i++ {
+				te := newTaskEvent(namespace.Default)
+				if status := doRequest(t, http.MethodPost, api.URL+"/tasks", te); status != http.StatusCreated {
+					t.Errorf("submit task: got status %d", status)
+					return
+				}
+
+				doRequest(t, http.MethodGet, api.URL+"/tasks", nil)
+				doRequest(t, http.MethodGet, api.URL+"/nodes", nil)
+				doRequest(t, http.MethodPatch, api.URL+"/nodes/"+node, map[string]any{
+					"Labels": map[string]string{"client": fmt.Sprint(c)},
+				})
+				doRequest(t, http.MethodPost, api.URL+"/nodes/"+node+"/cordon", nil)
+				doRequest(t, http.MethodPost, api.URL+"/nodes/"+node+"/uncordon", nil)
+
+				if i%2 == 0 {
+					if status := doRequest(t, http.MethodDelete, api.URL+"/tasks/"+te.Task.ID.String(), nil); status != http.StatusNoContent {
+						t.Errorf("stop task: got status %d", status)
+					}
+					mu.Lock()
+					stopped = append(stopped, te.Task.ID)
+					mu.Unlock()
+					continue
+				}
+				mu.Lock()
+				running = append(running, te.Task.ID)
+				mu.Unlock()
+			}
+		}(c)
+	}
+	clients.Wait()
+	if t.Failed() {
+		return
+	}
+
+	deadline := time.Now().Add(60 * time.Second)
+	for {
+		settled := true
+		for _, id := range running {
+			tk, err := m.TaskDb.Get(id.String())
+			if err != nil || tk.State != task.Running {
+				settled = false
+			}
+		}
+		for _, id := range stopped {
+			tk, err := m.TaskDb.Get(id.String())
+			if err != nil || tk.State != task.Completed {
+				settled = false
+			}
+		}
+		if settled {
+			break
+		}
+		if time.Now().After(deadline) {
+			for _, id := range running {
+				tk, _ := m.TaskDb.Get(id.String())
+				t.Logf("running %s %v %v", id, tk.State, tk.DesiredState)
+			}
+			for _, id := range stopped {
+				tk, _ := m.TaskDb.Get(id.String())
+				t.Logf("stopped %s %v %v", id, tk.State, tk.DesiredState)
+			}
+			t.Fatal("tasks did not reach their desired state")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, w := range m.TaskWorkerMap {
+		if !slices.Contains(m.WorkerTaskMap[w], id) {
+			t.Errorf("task %s is mapped to %s, which does not list it", id, w)
+		}
+	}
+	for w, ids := range m.WorkerTaskMap {
+		for _, id := range ids {
+			if m.TaskWorkerMap[id] != w {
+				t.Errorf("worker %s lists task %s, which is mapped to %q", w, id, m.TaskWorkerMap[id])
+			}
+		}
+	}
+}
+
+func TestConcurrentSubmissionsRespectQuota(t *testing.T) {
+	m, api := newTestManager(t, 1)
+
+	status := doRequest(t, http.MethodPost, api.URL+"/namespaces", namespace.Namespace{
+		Name:  "team",
+		Quota: namespace.Resources{Tasks: 5},
+	})
+	if status != http.StatusCreated {
+		t.Fatalf("create namespace: got status %d", status)
+	}
+
+	var mu sync.Mutex
+	counts := make(map[int]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := doRequest(t, http.MethodPost, api.URL+"/namespaces/team/tasks", newTaskEvent("team"))
+			mu.Lock()
+			counts[status]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if counts[http.StatusCreated] != 5 || counts[http.StatusForbidden] != 15 {
+		t.Errorf("got status counts %v, want 5 created and 15 forbidden", counts)
+	}
+	if n := m.Pending.Len(); n != 5 {
+		t.Errorf("got %d pending tasks, want 5", n)
+	}
+}
+
+func TestDispatchDrainsQueue(t *testing.T) {
+	m, api := newTestManager(t, 3)
+	m.DispatchRate = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.ProcessTasks(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	var ids []uuid.UUID
+	for i := 0; i < 100; i++ {
+		te := newTaskEvent(namespace.Default)
+		if status := doRequest(t, http.MethodPost, api.URL+"/tasks", te); status != http.StatusCreated {
+			t.Fatalf("submit task: got status %d", status)
+		}
+		ids = append(ids, te.Task.ID)
+	}
+
+	// Well below the retry interval, so only wakeups can get the tasks
+	// out in time.
+	deadline := time.Now().Add(dispatchRetryInterval / 2)
+	for _, id := range ids {
+		for {
+			tk, err := m.TaskDb.Get(id.String())
+			if err == nil && tk.State == task.Scheduled {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("task %s was not dispatched, %d events pending", id, m.Pending.Len())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	now := time.Now()
+	b := newTokenBucket(10, 2)
+	b.last = now
+
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		if got := b.reserve(now); got != want {
+			t.Errorf("reservation %d: got delay %v, want %v", i, got, want)
+		}
+	}
+
+	// After a second the bucket has paid back the reservations and
+	// refilled, but never beyond its burst.
+	now = now.Add(time.Second)
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond} {
+		if got := b.reserve(now); got != want {
+			t.Errorf("reservation %d after refill: got delay %v, want %v", i, got, want)
+		}
+	}
+}
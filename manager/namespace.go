@@ -0,0 +1,159 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+)
+
+var (
+	ErrNamespaceExists   = errors.New("namespace already exists")
+	ErrNamespaceNotEmpty = errors.New("namespace is not empty")
+)
+
+func (m *Manager) CreateNamespace(n *namespace.Namespace) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := n.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = m.NamespaceDb.Get(n.Name)
+	if err == nil {
+		return ErrNamespaceExists
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	n.CreatedAt = time.Now().UTC()
+	n.Status = namespace.Status{}
+	return m.NamespaceDb.Put(n.Name, n)
+}
+
+// UpdateNamespace replaces the quota of a namespace. Tasks admitted under
+// the old quota keep running.
+func (m *Manager) UpdateNamespace(spec *namespace.Namespace) (*namespace.Namespace, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, err := m.NamespaceDb.Get(spec.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	err = spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	n.Quota = spec.Quota
+	err = m.NamespaceDb.Put(n.Name, n)
+	if err != nil {
+		return nil, err
+	}
+	return m.GetNamespace(n.Name)
+}
+
+// GetNamespace returns a namespace with the resources currently used by
+// its tasks.
+func (m *Manager) GetNamespace(name string) (*namespace.Namespace, error) {
+	n, err := m.NamespaceDb.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	n.Status.Used = m.namespaceUsage(name)
+	return n, nil
+}
+
+func (m *Manager) GetNamespaces() []*namespace.Namespace {
+	namespaces, err := m.NamespaceDb.List()
+	if err != nil {
+		return []*namespace.Namespace{}
+	}
+
+	for _, n := range namespaces {
+		n.Status.Used = m.namespaceUsage(n.Name)
+	}
+	sort.Slice(namespaces, func(i, j int) bool {
+		return namespaces[i].Name < namespaces[j].Name
+	})
+	return namespaces
+}
+
+// DeleteNamespace removes a namespace that has no active tasks and no
+// services, cron jobs, jobs or workflows left. The default namespace cannot
+// be deleted.
+func (m *Manager) DeleteNamespace(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if name == namespace.Default {
+		return fmt.Errorf("namespace %s cannot be deleted", name)
+	}
+
+	_, err := m.NamespaceDb.Get(name)
+	if err != nil {
+		return err
+	}
+
+	used := m.namespaceUsage(name)
+	if used.Tasks > 0 {
+		return fmt.Errorf("%w: %d active tasks", ErrNamespaceNotEmpty, used.Tasks)
+	}
+	if len(m.GetServices(name)) > 0 || len(m.GetCronJobs(name)) > 0 || len(m.GetJobs(name)) > 0 || len(m.GetWorkflows(name)) > 0 {
+		return fmt.Errorf("%w: remove its services, cron jobs, jobs and workflows first", ErrNamespaceNotEmpty)
+	}
+
+	return m.NamespaceDb.Delete(name)
+}
+
+// admit checks that the namespace of t exists and has room in its quota for
+// t. It is called before a new task is accepted, whether it was submitted
+// directly or created by a controller. m.mu must be held.
+func (m *Manager) admit(t *task.Task) error {
+	n, err := m.NamespaceDb.Get(t.Namespace)
+	if err != nil {
+		return fmt.Errorf("namespace %s: %w", t.Namespace, err)
+	}
+
+	return n.Admit(m.namespaceUsage(n.Name), namespace.Resources{
+		Tasks:  1,
+		Cpu:    t.Cpu,
+		Memory: t.Memory,
+	})
+}
+
+// namespaceUsage sums the resources of the tasks in the namespace that are
+// waiting to run or running and have not been asked to stop.
+func (m *Manager) namespaceUsage(name string) namespace.Resources {
+	var used namespace.Resources
+	for _, t := range m.GetTasks() {
+		if t.Namespace != name || !isActive(t) || t.DesiredState == task.Completed {
+			continue
+		}
+		used.Tasks++
+		used.Cpu += t.Cpu
+		used.Memory += t.Memory
+	}
+	return used
+}
+
+// ensureDefaultNamespace creates the default namespace without a quota if
+// it does not exist yet.
+func (m *Manager) ensureDefaultNamespace() error {
+	_, err := m.NamespaceDb.Get(namespace.Default)
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	return m.CreateNamespace(&namespace.Namespace{Name: namespace.Default})
+}
@@ -0,0 +1,131 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *Api) CreateNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var n namespace.Namespace
+	err := d.Decode(&n)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	err = a.Manager.CreateNamespace(&n)
+	if errors.Is(err, ErrNamespaceExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, n)
+}
+
+func (a *Api) GetNamespacesHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, a.Manager.GetNamespaces())
+}
+
+func (a *Api) GetNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	name := namespaceParam(r)
+	n, err := a.Manager.GetNamespace(name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no namespace with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, n)
+}
+
+func (a *Api) UpdateNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	name := namespaceParam(r)
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var spec namespace.Namespace
+	err := d.Decode(&spec)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+	if spec.Name == "" {
+		spec.Name = name
+	}
+	if spec.Name != name {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("namespace name %q does not match %q", spec.Name, name))
+		return
+	}
+
+	n, err := a.Manager.UpdateNamespace(&spec)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no namespace with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, n)
+}
+
+func (a *Api) DeleteNamespaceHandler(w http.ResponseWriter, r *http.Request) {
+	name := namespaceParam(r)
+	err := a.Manager.DeleteNamespace(name)
+	if errors.Is(err, ErrNamespaceNotEmpty) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no namespace with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// namespaceCtx rejects requests for namespaces that do not exist.
+func (a *Api) namespaceCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := namespaceParam(r)
+		_, err := a.Manager.NamespaceDb.Get(name)
+		if err != nil {
+			respondStoreError(w, err, fmt.Sprintf("no namespace with name %v found", name))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// namespaceParam returns the namespace of the request path, or the default
+// namespace for the routes outside /namespaces.
+func namespaceParam(r *http.Request) string {
+	ns := chi.URLParam(r, "namespace")
+	if ns == "" {
+		return namespace.Default
+	}
+	return ns
+}
+
+// setNamespace fills in the namespace of a submitted resource from the
+// request path and rejects resources that name a different one.
+func setNamespace(r *http.Request, ns *string) error {
+	want := namespaceParam(r)
+	if *ns == "" {
+		*ns = want
+	}
+	if *ns != want {
+		return fmt.Errorf("namespace %q does not match %q", *ns, want)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"time"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/watch"
+	"github.com/google/uuid"
+)
+
+// GetNodes returns copies of the worker nodes whose labels match sel.
+func (m *Manager) GetNodes(sel labels.Selector) []*node.Node {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	nodes := []*node.Node{}
+	for _, n := range m.WorkerNodes {
+		if sel.Matches(n.Labels) {
+			nodes = append(nodes, n.Clone())
+		}
+	}
+	return nodes
+}
+
+// PatchNode applies p to the labels and annotations of the named node.
+func (m *Manager) PatchNode(name string, p node.Patch) (*node.Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, n := range m.WorkerNodes {
+		if n.Name != name {
+			continue
+		}
+
+		newLabels := make(map[string]string)
+		for k, v := range p.Labels {
+			if v != nil {
+				newLabels[k] = *v
+			}
+		}
+		err := labels.Validate(newLabels)
+		if err != nil {
+			return nil, err
+		}
+
+		n.Apply(p)
+		m.publishNode(n)
+		return n.Clone(), nil
+	}
+
+	return nil, store.ErrNotFound
+}
+
+// RegisterNode adds a worker announced through the API, or updates it when
+// it registers again after a restart. A worker matching a node given with
+// --workers by address keeps that node's name.
+func (m *Manager) RegisterNode(reg node.Registration) (*node.Node, error) {
+	if reg.Name == "" || reg.Address == "" {
+		return nil, fmt.Errorf("name and address are required")
+	}
+	err := labels.Validate(reg.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	api := fmt.Sprintf("http://%s", reg.Address)
+	eventType := watch.Modified
+	n := m.workerNode(reg.Name)
+	if n == nil {
+		for _, wn := range m.WorkerNodes {
+			if wn.Api == api {
+				n = wn
+				break
+			}
+		}
+	}
+	if n == nil {
+		n = node.New(reg.Name, api, "worker")
+		m.WorkerNodes = append(m.WorkerNodes, n)
+		m.Workers = append(m.Workers, n.Name)
+		if _, ok := m.WorkerTaskMap[n.Name]; !ok {
+			m.WorkerTaskMap[n.Name] = []uuid.UUID{}
+		}
+		eventType = watch.Added
+		log.Printf("[manager] registered worker %s at %s\n", n.Name, reg.Address)
+	}
+
+	n.Api = api
+	n.Cores = reg.Cores
+	n.Memory = reg.Memory
+	n.Disk = reg.Disk
+	n.Labels = maps.Clone(reg.Labels)
+	n.LastHeartbeat = time.Now().UTC()
+
+	m.Hub.Publish(watch.Event{
+		Type: eventType,
+		Kind: watch.KindNode,
+		Node: n.Clone(),
+	})
+	return n.Clone(), nil
+}
+
+// Heartbeat records that the named worker is alive.
+func (m *Manager) Heartbeat(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.workerNode(name)
+	if n == nil {
+		return store.ErrNotFound
+	}
+
+	n.LastHeartbeat = time.Now().UTC()
+	return nil
+}
+
+// workerNode returns the node of the named worker, or nil. m.mu must be
+// held.
+func (m *Manager) workerNode(name string) *node.Node {
+	for _, n := range m.WorkerNodes {
+		if n.Name == name {
+			return n
+		}
+	}
+	return nil
+}
+
+// workerApi returns the base URL of the named worker's API. m.mu must not
+// be held.
+func (m *Manager) workerApi(name string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := m.workerNode(name)
+	if n == nil {
+		return fmt.Sprintf("http://%s", name)
+	}
+	return n.Api
+}
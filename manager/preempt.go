@@ -0,0 +1,226 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// errNoFit is returned by SelectWorker when no node has room for a task.
+var errNoFit = errors.New("no node has enough free resources")
+
+// resources is the amount of CPU, memory (bytes) and disk (bytes) tasks
+// request on a node.
+type resources struct {
+	cpu    float64
+	memory int64
+	disk   int64
+}
+
+func (r *resources) add(t *task.Task) {
+	r.cpu += t.Cpu
+	r.memory += t.Memory
+	r.disk += t.Disk
+}
+
+func (r *resources) sub(t *task.Task) {
+	r.cpu -= t.Cpu
+	r.memory -= t.Memory
+	r.disk -= t.Disk
+}
+
+// eventPriority orders the pending queue. Stop requests go first since
+// they free capacity for everything behind them.
+func eventPriority(te task.TaskEvent) int {
+	if te.State == task.Completed {
+		return math.MaxInt
+	}
+	return te.Task.Priority
+}
+
+// fits reports whether t fits on n next to the allocated resources.
+// Capacities the node has not reported yet are not checked.
+func fits(t *task.Task, n *node.Node, allocated resources) bool {
+	if n.Cores > 0 && allocated.cpu+t.Cpu > float64(n.Cores) {
+		return false
+	}
+	if n.Stats.MemStats != nil && n.Stats.MemTotalKb() > 0 &&
+		allocated.memory+t.Memory > int64(n.Stats.MemTotalKb())*1024 {
+		return false
+	}
+	if n.Stats.DiskStats != nil && n.Stats.DiskTotal() > 0 &&
+		allocated.disk+t.Disk > int64(n.Stats.DiskTotal()) {
+		return false
+	}
+	return true
+}
+
+// nodeTasks returns the tasks placed on each worker that still hold
+// resources there, including those on their way to it. m.mu must be held.
+func (m *Manager) nodeTasks() map[string][]*task.Task {
+	placed := make(map[string][]*task.Task)
+	for _, d := range m.dispatching {
+		placed[d.worker] = append(placed[d.worker], &d.event.Task)
+	}
+	for _, t := range m.GetTasks() {
+		w, ok := m.TaskWorkerMap[t.ID]
+		if !ok || (t.State != task.Scheduled && t.State != task.Running) {
+			continue
+		}
+		placed[w] = append(placed[w], t)
+	}
+	return placed
+}
+
+// allocated sums the resources requested by tasks.
+func allocated(tasks []*task.Task) resources {
+	var r resources
+	for _, t := range tasks {
+		r.add(t)
+	}
+	return r
+}
+
+// preempt looks for the node where stopping the fewest, lowest priority
+// running tasks makes room for t and evicts them. Only tasks with a lower
+// priority than t that are not marked NonPreemptible are considered.
+func (m *Manager) preempt(t *task.Task) error {
+	m.mu.Lock()
+	candidates := m.Scheduler.SelectCandidateNodes(*t, m.schedulableNodes())
+	placed := m.nodeTasks()
+
+	var best []*task.Task
+	var bestNode *node.Node
+	for _, n := range candidates {
+		var victims []*task.Task
+		for _, v := range placed[n.Name] {
+			if v.State == task.Running && v.Priority < t.Priority && !v.NonPreemptible {
+				victims = append(victims, v)
+			}
+		}
+		// Evict the lowest priority tasks first and, among those, the
+		// ones that started most recently and so lose the least work.
+		sort.SliceStable(victims, func(i, j int) bool {
+			if victims[i].Priority != victims[j].Priority {
+				return victims[i].Priority < victims[j].Priority
+			}
+			return victims[i].StartTime.After(victims[j].StartTime)
+		})
+
+		alloc := allocated(placed[n.Name])
+		var chosen []*task.Task
+		for _, v := range victims {
+			if fits(t, n, alloc) {
+				break
+			}
+			alloc.sub(v)
+			chosen = append(chosen, v)
+		}
+		if !fits(t, n, alloc) {
+			continue
+		}
+
+		if bestNode == nil || betterVictims(chosen, best) {
+			best, bestNode = chosen, n
+		}
+	}
+
+	m.mu.Unlock()
+	if bestNode == nil {
+		return fmt.Errorf("no lower priority tasks can be preempted for task %s", t.ID)
+	}
+
+	for _, v := range best {
+		log.Printf("[manager] preempting task %s (priority %d) on %s for task %s (priority %d)\n",
+			v.ID, v.Priority, bestNode.Name, t.ID, t.Priority)
+		err := m.evictTask(v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// betterVictims prefers evicting tasks of lower priority, then fewer tasks.
+func betterVictims(a, b []*task.Task) bool {
+	maxPriority := func(tasks []*task.Task) int {
+		p := math.MinInt
+		for _, t := range tasks {
+			p = max(p, t.Priority)
+		}
+		return p
+	}
+	if pa, pb := maxPriority(a), maxPriority(b); pa != pb {
+		return pa < pb
+	}
+	return len(a) < len(b)
+}
+
+// evictTask stops t on its worker and puts it back in the pending queue
+// so that it is scheduled again, possibly on another node.
+func (m *Manager) evictTask(t *task.Task) error {
+	m.mu.Lock()
+	w, ok := m.TaskWorkerMap[t.ID]
+	m.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("task %s is not placed on any worker", t.ID)
+	}
+
+	err := m.preemptTask(w, t.ID.String())
+	if err != nil && !errors.Is(err, errTaskNotOnWorker) {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if cur, ok := m.TaskWorkerMap[t.ID]; !ok || cur != w {
+		// Moved by someone else while it was being stopped.
+		return nil
+	}
+	latest, err := m.TaskDb.Get(t.ID.String())
+	if err != nil {
+		return err
+	}
+	return m.requeueTask(latest)
+}
+
+// requeueTask removes t from its worker and puts it back in the pending
+// queue without contacting the worker. m.mu must be held.
+func (m *Manager) requeueTask(t *task.Task) error {
+	err := m.unplaceTask(t)
+	if err != nil {
+		return err
+	}
+
+	err = m.Pending.Enqueue(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      *t,
+	})
+	if err != nil {
+		return err
+	}
+	m.notify()
+	return nil
+}
+
+// unplaceTask removes t from its worker and makes it Pending again, without
+// queueing it or contacting the worker. m.mu must be held.
+func (m *Manager) unplaceTask(t *task.Task) error {
+	m.unassignTask(t)
+
+	t.State = task.Pending
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.StartTime = time.Time{}
+	t.FinishTime = time.Time{}
+	return m.saveTask(t)
+}
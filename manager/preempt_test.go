@@ -0,0 +1,38 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/dev6699/cube/task"
+)
+
+func TestEvictedTaskIsPlacedAgain(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 1)
+
+	id := submit(t, m)
+	dispatchAll(t, m)
+	m.updateTasks()
+	if tk := getTask(t, m, id); tk.State != task.Running {
+		t.Fatalf("task is %s, want it Running", tk.State)
+	}
+
+	err := m.evictTask(getTask(t, m, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fws[0].mu.Lock()
+	state := fws[0].tasks[id].State
+	fws[0].mu.Unlock()
+	if state != task.Preempted {
+		t.Errorf("task is %s on the worker, want it Preempted", state)
+	}
+
+	m.updateTasks()
+	if tk := getTask(t, m, id); tk.State != task.Pending {
+		t.Errorf("task is %s after the worker reported it, want it Pending", tk.State)
+	}
+	dispatchAll(t, m)
+	if tk := getTask(t, m, id); tk.State != task.Scheduled || tk.Worker != "worker-0" {
+		t.Errorf("task is %s on %q, want it Scheduled on worker-0", tk.State, tk.Worker)
+	}
+}
@@ -0,0 +1,150 @@
+package manager
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// taskSorts are the orders tasks can be listed in, by the name of the
+// field they are sorted by, one for each of v1.TaskSortFields. Ties are
+// broken by ID, so that every order is total and pages never overlap.
+var taskSorts = map[string]func(a, b *task.Task) int{
+	"id": func(a, b *task.Task) int { return 0 },
+	"name": func(a, b *task.Task) int {
+		return strings.Compare(a.Name, b.Name)
+	},
+	"image": func(a, b *task.Task) int {
+		return strings.Compare(a.Image, b.Image)
+	},
+	"node": func(a, b *task.Task) int {
+		return strings.Compare(a.Worker, b.Worker)
+	},
+	"state": func(a, b *task.Task) int {
+		return cmp.Compare(a.State, b.State)
+	},
+	"started": func(a, b *task.Task) int {
+		return a.StartTime.Compare(b.StartTime)
+	},
+	"finished": func(a, b *task.Task) int {
+		return a.FinishTime.Compare(b.FinishTime)
+	},
+}
+
+// TaskPage is a page of tasks. Next is the cursor of the following page;
+// it is empty on the last page.
+type TaskPage struct {
+	Tasks []*task.Task
+	Next  string
+}
+
+// taskCursor is the decoded form of a cursor. It holds the fields the last
+// task of the page is sorted by.
+type taskCursor struct {
+	Sort       string `json:",omitempty"`
+	ID         uuid.UUID
+	Name       string     `json:",omitempty"`
+	Image      string     `json:",omitempty"`
+	Worker     string     `json:",omitempty"`
+	State      task.State `json:",omitempty"`
+	StartTime  time.Time
+	FinishTime time.Time
+}
+
+// compareTasks orders tasks as requested by q.
+func compareTasks(q v1.TaskQuery, a, b *task.Task) int {
+	field, desc := strings.CutPrefix(q.Sort, "-")
+	c := taskSorts[cmp.Or(field, "id")](a, b)
+	if c == 0 {
+		c = strings.Compare(a.ID.String(), b.ID.String())
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+// QueryTasks returns the page of the tasks in namespace ns that q selects.
+func (m *Manager) QueryTasks(ns string, q v1.TaskQuery) (TaskPage, error) {
+	var after *task.Task
+	if q.Cursor != "" {
+		c, err := decodeCursor(q.Cursor)
+		if err != nil || c.Sort != q.Sort {
+			return TaskPage{}, fmt.Errorf("invalid cursor for this query")
+		}
+		after = c.last()
+	}
+
+	tasks := []*task.Task{}
+	for _, t := range m.GetTasks() {
+		if t.Namespace != ns || !q.Matches(t) {
+			continue
+		}
+		if after != nil && compareTasks(q, t, after) <= 0 {
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	slices.SortFunc(tasks, func(a, b *task.Task) int {
+		return compareTasks(q, a, b)
+	})
+
+	if q.Limit == 0 || len(tasks) <= q.Limit {
+		return TaskPage{Tasks: tasks}, nil
+	}
+	tasks = tasks[:q.Limit]
+	next, err := encodeCursor(q.Sort, tasks[len(tasks)-1])
+	if err != nil {
+		return TaskPage{}, err
+	}
+	return TaskPage{Tasks: tasks, Next: next}, nil
+}
+
+func encodeCursor(sort string, last *task.Task) (string, error) {
+	data, err := json.Marshal(taskCursor{
+		Sort:       sort,
+		ID:         last.ID,
+		Name:       last.Name,
+		Image:      last.Image,
+		Worker:     last.Worker,
+		State:      last.State,
+		StartTime:  last.StartTime,
+		FinishTime: last.FinishTime,
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(s string) (taskCursor, error) {
+	var c taskCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	err = json.Unmarshal(data, &c)
+	return c, err
+}
+
+// last is the task the page the cursor continues ended with, as far as
+// it is known.
+func (c taskCursor) last() *task.Task {
+	return &task.Task{
+		ID:         c.ID,
+		Name:       c.Name,
+		Image:      c.Image,
+		Worker:     c.Worker,
+		State:      c.State,
+		StartTime:  c.StartTime,
+		FinishTime: c.FinishTime,
+	}
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+func TestQueryTasks(t *testing.T) {
+	m, api := newTestManager(t, 1)
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m.mu.Lock()
+	for i := 0; i < 10; i++ {
+		tk := &task.Task{
+			ID:        uuid.New(),
+			Name:      fmt.Sprintf("task-%d", i),
+			Namespace: namespace.Default,
+			Image:     "alpine",
+			State:     task.Completed,
+			StartTime: start.Add(time.Duration(i) * time.Hour),
+		}
+		if i%2 == 0 {
+			tk.Image = "nginx"
+			tk.State = task.Running
+			tk.Worker = "worker-0"
+		}
+		if err := m.saveTask(tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.mu.Unlock()
+
+	filter := url.Values{
+		"state":        {"running"},
+		"image":        {"nginx"},
+		"node":         {"worker-0"},
+		"startedAfter": {start.Add(3 * time.Hour).Format(time.RFC3339)},
+		"sort":         {"-started"},
+	}
+	var tasks []task.Task
+	if status := doRequest(t, http.MethodGet, api.URL+"/tasks?"+filter.Encode(), nil, decodeInto(&tasks)); status != http.StatusOK {
+		t.Fatalf("list tasks: got status %d", status)
+	}
+	var names []string
+	for _, tk := range tasks {
+		names = append(names, tk.Name)
+	}
+	if fmt.Sprint(names) != "[task-8 task-6 task-4]" {
+		t.Errorf("got tasks %v, want [task-8 task-6 task-4]", names)
+	}
+
+	// Paging through all tasks yields each of them once, in order.
+	query := url.Values{"sort": {"name"}, "limit": {"3"}}
+	var paged []string
+	for pages := 0; ; pages++ {
+		if pages > 4 {
+			t.Fatal("paging does not end")
+		}
+		var tasks []task.Task
+		var header http.Header
+		status := doRequest(t, http.MethodGet, api.URL+"/tasks?"+query.Encode(), nil, decodeInto(&tasks), responseHeader(&header))
+		if status != http.StatusOK {
+			t.Fatalf("list tasks with %v: got status %d", query, status)
+		}
+		for _, tk := range tasks {
+			paged = append(paged, tk.Name)
+		}
+		next := header.Get(NextCursorHeader)
+		if next == "" {
+			break
+		}
+		query.Set("cursor", next)
+	}
+	if fmt.Sprint(paged) != "[task-0 task-1 task-2 task-3 task-4 task-5 task-6 task-7 task-8 task-9]" {
+		t.Errorf("got pages of %v, want every task once in order", paged)
+	}
+
+	query.Set("sort", "image")
+	if status := doRequest(t, http.MethodGet, api.URL+"/tasks?"+query.Encode(), nil); status != http.StatusBadRequest {
+		t.Errorf("cursor of another sort order: got status %d, want %d", status, http.StatusBadRequest)
+	}
+
+	tk := m.GetTasks()[0]
+	if status := doRequest(t, http.MethodGet, api.URL+"/tasks/"+tk.ID.String(), nil); status != http.StatusOK {
+		t.Errorf("get task: got status %d, want %d", status, http.StatusOK)
+	}
+	if status := doRequest(t, http.MethodGet, api.URL+"/tasks/"+uuid.NewString(), nil); status != http.StatusNotFound {
+		t.Errorf("get unknown task: got status %d, want %d", status, http.StatusNotFound)
+	}
+}
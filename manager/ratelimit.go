@@ -0,0 +1,41 @@
+package manager
+
+import "time"
+
+// tokenBucket limits how fast tasks are sent to a worker. It holds up to
+// burst tokens and refills at rate tokens per second. Tokens may be taken
+// ahead of time, in which case the bucket goes negative and later callers
+// wait correspondingly longer.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(max(burst, 1)),
+		tokens: float64(max(burst, 1)),
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token and returns how long the caller has to wait
+// before using it. A bucket with a non-positive rate never waits.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b.rate <= 0 {
+		return 0
+	}
+
+	if now.After(b.last) {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+		b.last = now
+	}
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,215 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// DefaultReconcileInterval is how often the manager compares the desired
+// state of the tasks with the tasks the workers report.
+const DefaultReconcileInterval = 30 * time.Second
+
+// driftKind is a way in which a worker differs from what the manager
+// wants it to run.
+type driftKind int
+
+const (
+	// driftMissing is a task placed on a worker to run that the worker
+	// does not know, e.g. because the request starting it was lost.
+	driftMissing driftKind = iota
+	// driftNotStopped is a task that should be stopped but still runs.
+	driftNotStopped
+	// driftMoved is a task a worker runs that the manager placed
+	// elsewhere.
+	driftMoved
+	// driftUnwanted is a task a worker runs that the manager does not
+	// know at all.
+	driftUnwanted
+)
+
+// drift is a difference between a worker and the desired state of one of
+// the tasks it runs or should run.
+type drift struct {
+	kind   driftKind
+	task   uuid.UUID
+	worker string
+}
+
+// Reconcile periodically drives the workers towards the desired state of
+// the tasks. Tasks their worker does not know are scheduled again, and
+// tasks that run but should not are stopped. Tasks the manager does not
+// know are only stopped with StopUnknownTasks.
+func (m *Manager) Reconcile(ctx context.Context) error {
+	ticker := time.NewTicker(m.ReconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			log.Println("[manager] reconciling tasks")
+			err := m.reconcile()
+			if err != nil {
+				log.Printf("[manager] error reconcile tasks: %v\n", err)
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// reconcile compares the tasks every Ready node reports with the tasks the
+// manager placed on it and corrects the differences that have been seen
+// in every pass for at least ReconcileInterval. The wait leaves time for
+// requests that are still on their way, e.g. held back by the dispatch
+// rate limit. Nodes that are not Ready are left to the node health checks.
+func (m *Manager) reconcile() error {
+	queued, err := m.Pending.List()
+	if err != nil {
+		return err
+	}
+	waiting := make(map[uuid.UUID]bool)
+	for _, te := range queued {
+		waiting[te.Task.ID] = true
+	}
+
+	now := time.Now()
+	var errs []error
+	found := make(map[drift]time.Time)
+	for _, n := range m.GetNodes(labels.Selector{}) {
+		if n.Status != node.Ready {
+			continue
+		}
+		reported, err := m.getNodeTasks(n)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", n.Name, err))
+			continue
+		}
+		for _, d := range m.nodeDrift(n.Name, reported, waiting) {
+			found[d] = now
+		}
+	}
+
+	m.mu.Lock()
+	var stops []drift
+	for d := range found {
+		since, ok := m.drifts[d]
+		if !ok {
+			continue
+		}
+		found[d] = since
+		if now.Sub(since) < m.ReconcileInterval {
+			continue
+		}
+		if d.kind == driftUnwanted && !m.StopUnknownTasks {
+			log.Printf("[manager] worker %s runs task %s, which the manager does not know\n", d.worker, d.task)
+			continue
+		}
+		if d.kind != driftMissing {
+			stops = append(stops, d)
+			continue
+		}
+		err := m.replaceMissing(d)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("task %s: %w", d.task, err))
+		}
+	}
+	m.drifts = found
+	m.mu.Unlock()
+
+	for _, d := range stops {
+		log.Printf("[manager] stopping task %s on worker %s, which should not run it\n", d.task, d.worker)
+		stop := m.stopTask
+		if d.kind == driftMoved {
+			// The task may be placed on this worker again.
+			stop = m.preemptTask
+		}
+		m.send(d.worker, func() {
+			err := stop(d.worker, d.task.String())
+			if err != nil && !errors.Is(err, errTaskNotOnWorker) {
+				log.Printf("[manager] error stop task %s: %v\n", d.task, err)
+			}
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// nodeDrift compares the tasks worker reported with those placed on it.
+// Tasks with an event in the pending queue are about to be started or
+// stopped and are left alone, as are tasks on their way to a worker and
+// tasks whose worker still has time to start them.
+func (m *Manager) nodeDrift(worker string, reported []*task.Task, waiting map[uuid.UUID]bool) []drift {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var drifts []drift
+	onWorker := make(map[uuid.UUID]bool)
+	for _, t := range reported {
+		onWorker[t.ID] = true
+		if _, ok := m.dispatching[t.ID]; ok {
+			continue
+		}
+		if t.State != task.Scheduled && t.State != task.Running {
+			continue
+		}
+
+		placed, err := m.TaskDb.Get(t.ID.String())
+		if errors.Is(err, store.ErrNotFound) {
+			drifts = append(drifts, drift{kind: driftUnwanted, task: t.ID, worker: worker})
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		w, ok := m.TaskWorkerMap[t.ID]
+		switch {
+		case ok && w == worker:
+			if placed.DesiredState == task.Completed && !waiting[t.ID] {
+				drifts = append(drifts, drift{kind: driftNotStopped, task: t.ID, worker: worker})
+			}
+		case !ok && (placed.State == task.Scheduled || placed.State == task.Running):
+			// Adopted by the worker when its tasks are next updated.
+		default:
+			drifts = append(drifts, drift{kind: driftMoved, task: t.ID, worker: worker})
+		}
+	}
+
+	for _, id := range m.WorkerTaskMap[worker] {
+		if _, ok := m.leases[id]; ok || onWorker[id] || waiting[id] {
+			continue
+		}
+		t, err := m.TaskDb.Get(id.String())
+		if err != nil {
+			continue
+		}
+		if t.DesiredState == task.Running && (t.State == task.Scheduled || t.State == task.Running) {
+			drifts = append(drifts, drift{kind: driftMissing, task: id, worker: worker})
+		}
+	}
+	return drifts
+}
+
+// replaceMissing schedules a task its worker does not know again, unless
+// it was moved or stopped since the drift was found. m.mu must be held.
+func (m *Manager) replaceMissing(d drift) error {
+	t, err := m.TaskDb.Get(d.task.String())
+	if err != nil {
+		return err
+	}
+	if m.TaskWorkerMap[t.ID] != d.worker || t.DesiredState != task.Running {
+		return nil
+	}
+
+	log.Printf("[manager] task %s is not known to worker %s, scheduling it again\n", t.ID, d.worker)
+	return m.requeueTask(t)
+}
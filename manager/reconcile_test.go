@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+func TestReconcileCorrectsDrift(t *testing.T) {
+	for _, stopUnknown := range []bool{false, true} {
+		t.Run(fmt.Sprintf("StopUnknownTasks=%v", stopUnknown), func(t *testing.T) {
+			testReconcileCorrectsDrift(t, stopUnknown)
+		})
+	}
+}
+
+func testReconcileCorrectsDrift(t *testing.T, stopUnknown bool) {
+	m, _ := newTestManager(t, 1)
+	m.ReconcileInterval = time.Millisecond
+	m.StopUnknownTasks = stopUnknown
+	n := m.WorkerNodes[0]
+
+	// lost was placed on the worker, but the request starting it never
+	// arrived. stopped was asked to stop, but the request stopping it never
+	// arrived. unknown runs on the worker without the manager knowing it.
+	lost := task.Task{ID: uuid.New(), State: task.Scheduled, DesiredState: task.Running}
+	stopped := task.Task{ID: uuid.New(), State: task.Running, DesiredState: task.Completed}
+	unknown := task.Task{ID: uuid.New()}
+	for _, tk := range []task.Task{stopped, unknown} {
+		status := doRequest(t, http.MethodPost, n.Api+"/tasks", task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: tk})
+		if status != http.StatusCreated {
+			t.Fatalf("start task on worker: got status %d", status)
+		}
+	}
+	m.mu.Lock()
+	for _, tk := range []task.Task{lost, stopped} {
+		m.assignTask(&tk, n.Name)
+		if err := m.saveTask(&tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.mu.Unlock()
+
+	// The first pass only notices the drift.
+	if err := m.reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	m.sends.Wait()
+	if tk, _ := m.TaskDb.Get(lost.ID.String()); tk.State != task.Scheduled {
+		t.Errorf("lost task is %s after the first pass, want it left Scheduled", tk.State)
+	}
+
+	time.Sleep(2 * m.ReconcileInterval)
+	if err := m.reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	m.sends.Wait()
+
+	tk, err := m.TaskDb.Get(lost.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.State != task.Pending || tk.Worker != "" {
+		t.Errorf("lost task is %s on %q, want it Pending to be scheduled again", tk.State, tk.Worker)
+	}
+	if m.Pending.Len() != 1 {
+		t.Errorf("got %d pending events, want 1", m.Pending.Len())
+	}
+
+	reported, err := m.getNodeTasks(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tk := range reported {
+		want := task.Completed
+		if tk.ID == unknown.ID && !stopUnknown {
+			want = task.Running
+		}
+		if tk.State != want {
+			t.Errorf("task %s is %s on the worker, want it %s", tk.ID, tk.State, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+//go:build !race
+
+// boltdb/bolt trips the pointer checks enabled by the race detector.
+
+package manager
+
+import (
+	"context"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// closeStores closes the Bolt databases of m so that they can be opened
+// again by another manager.
+func closeStores(t *testing.T, m *Manager) {
+	for _, s := range []any{m.Pending, m.TaskDb, m.EventDb, m.ServiceDb, m.CronJobDb, m.JobDb, m.WorkflowDb, m.NamespaceDb, m.DeadLetterDb, m.SubmissionDb, m.TokenDb} {
+		if c, ok := s.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				t.Error(err)
+			}
+		}
+	}
+}
+
+func TestPendingTasksSurviveRestart(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	m, err := New(nil, "roundrobin", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []uuid.UUID
+	for i := 0; i < 3; i++ {
+		te := newTaskEvent(namespace.Default)
+		if _, _, err := m.Submit(te, ""); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, te.Task.ID)
+	}
+	// The manager stops after taking an event off the queue but before
+	// dispatching it.
+	if _, err := m.Pending.Dequeue(); err != nil {
+		t.Fatal(err)
+	}
+	closeStores(t, m)
+
+	m, err = New(nil, "roundrobin", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStores(t, m)
+
+	queued, err := m.Pending.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []uuid.UUID
+	for _, te := range queued {
+		got = append(got, te.Task.ID)
+	}
+	slices.SortFunc(got, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	if !slices.Equal(got, ids) {
+		t.Errorf("got queued tasks %v after restart, want %v", got, ids)
+	}
+}
+
+func TestAssignmentsSurviveRestart(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	fw := newFakeWorker(t)
+	addr := strings.TrimPrefix(fw.srv.URL, "http://")
+
+	m, err := New([]string{addr}, "roundrobin", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.resync()
+	te := newTaskEvent(namespace.Default)
+	if _, _, err := m.Submit(te, ""); err != nil {
+		t.Fatal(err)
+	}
+	m.dispatchPending(context.Background())
+	m.sends.Wait()
+	closeStores(t, m)
+
+	m, err = New([]string{addr}, "roundrobin", "bolt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeStores(t, m)
+
+	if w := m.TaskWorkerMap[te.Task.ID]; w != addr {
+		t.Fatalf("got task placed on %q after restart, want %q", w, addr)
+	}
+	if !slices.Contains(m.WorkerTaskMap[addr], te.Task.ID) {
+		t.Fatalf("worker %s does not list task %s after restart", addr, te.Task.ID)
+	}
+
+	m.resync()
+	tk, err := m.TaskDb.Get(te.Task.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.State != task.Running {
+		t.Errorf("got task state %v after resync, want %v", tk.State, task.Running)
+	}
+	if n := m.workerNode(addr); n.Status != node.Ready {
+		t.Errorf("got node status %v after resync, want %v", n.Status, node.Ready)
+	}
+
+	if err := m.StopTask(te.Task.ID); err != nil {
+		t.Fatal(err)
+	}
+	m.dispatchPending(context.Background())
+	m.sends.Wait()
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	if s := fw.tasks[te.Task.ID].State; s != task.Completed {
+		t.Errorf("got task state %v on the worker after stopping it, want %v", s, task.Completed)
+	}
+}
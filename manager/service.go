@@ -0,0 +1,543 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrServiceExists = errors.New("service already exists")
+)
+
+func (m *Manager) CreateService(s *service.Service) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s.SetDefaults()
+	err := s.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = m.ServiceDb.Get(s.Key())
+	if err == nil {
+		return ErrServiceExists
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	template := s.Template
+	s.Revision = 0
+	s.History = nil
+	s.SetTemplate(template)
+	s.UID = uuid.New()
+	s.CreatedAt = time.Now().UTC()
+	s.Status = service.Status{}
+	return m.ServiceDb.Put(s.Key(), s)
+}
+
+// UpdateService applies a new specification to an existing service. A
+// changed template starts a rolling update to a new revision.
+func (m *Manager) UpdateService(spec *service.Service) (*service.Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.ServiceDb.Get(spec.Key())
+	if err != nil {
+		return nil, err
+	}
+
+	spec.SetDefaults()
+	err = spec.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	s.Replicas = spec.Replicas
+	s.UpdateConfig = spec.UpdateConfig
+	s.Autoscale = spec.Autoscale
+	s.DisruptionBudget = spec.DisruptionBudget
+	s.RevisionHistoryLimit = spec.RevisionHistoryLimit
+	if s.SetTemplate(spec.Template) {
+		s.Status.UpdateState = service.UpdateUpdating
+		s.Status.Message = fmt.Sprintf("updating to revision %d", s.Revision)
+	}
+
+	err = m.ServiceDb.Put(s.Key(), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// RollbackService starts a rolling update back to the template of the
+// given revision, or of the previous one when revision is zero.
+func (m *Manager) RollbackService(ns string, name string, revision int) (*service.Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return nil, err
+	}
+
+	from := s.Revision
+	err = s.Rollback(revision)
+	if err != nil {
+		return nil, err
+	}
+	s.Status.UpdateState = service.UpdateUpdating
+	s.Status.Message = fmt.Sprintf("rolling back from revision %d as revision %d", from, s.Revision)
+
+	err = m.ServiceDb.Put(s.Key(), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// PauseService stops the controller from creating or removing tasks of the
+// service until it is resumed.
+func (m *Manager) PauseService(ns string, name string) (*service.Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status.UpdateState = service.UpdatePaused
+	s.Status.Message = "paused by user"
+	err = m.ServiceDb.Put(s.Key(), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (m *Manager) ResumeService(ns string, name string) (*service.Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return nil, err
+	}
+	if s.Status.UpdateState != service.UpdatePaused {
+		return nil, fmt.Errorf("service %s is not paused", name)
+	}
+
+	s.Status.UpdateState = service.UpdateUpdating
+	s.Status.Message = fmt.Sprintf("resumed update to revision %d", s.Revision)
+	err = m.ServiceDb.Put(s.Key(), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetServices returns the services of namespace ns.
+func (m *Manager) GetServices(ns string) []*service.Service {
+	all, err := m.ServiceDb.List()
+	if err != nil {
+		return []*service.Service{}
+	}
+
+	services := []*service.Service{}
+	for _, s := range all {
+		if s.Namespace == ns {
+			services = append(services, s)
+		}
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+	return services
+}
+
+func (m *Manager) ScaleService(ns string, name string, replicas int) (*service.Service, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if replicas < 0 {
+		return nil, fmt.Errorf("replicas must not be negative")
+	}
+
+	s, err := m.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return nil, err
+	}
+
+	s.Replicas = replicas
+	err = m.ServiceDb.Put(s.Key(), s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// DeleteService stops every task of the service and removes it.
+func (m *Manager) DeleteService(ns string, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range m.GetTasks() {
+		if !s.Owns(t) || t.DesiredState == task.Completed || !isActive(t) {
+			continue
+		}
+
+		err := m.requestStop(t.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	delete(m.recommendations, s.Key())
+	return m.ServiceDb.Delete(namespace.Key(ns, name))
+}
+
+// ReconcileServices periodically creates and stops tasks so that every
+// service runs its desired number of replicas of its current revision.
+func (m *Manager) ReconcileServices(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			log.Println("[manager] reconciling services")
+			m.reconcileServices()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Manager) reconcileServices() {
+	// Health checks reach out to the tasks, so they run before m.mu is
+	// taken.
+	health := m.serviceTaskHealth()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tasks := m.GetTasks()
+	services, err := m.ServiceDb.List()
+	if err != nil {
+		log.Printf("[manager] error listing services: %v\n", err)
+		return
+	}
+	for _, s := range services {
+		err := m.reconcileService(s, tasks, health)
+		if err != nil {
+			log.Printf("[manager] error reconciling service %s: %v\n", s.Name, err)
+		}
+	}
+}
+
+// serviceTaskHealth checks the health of the running tasks of the current
+// revision of services that are being updated.
+func (m *Manager) serviceTaskHealth() map[uuid.UUID]error {
+	health := make(map[uuid.UUID]error)
+	services, err := m.ServiceDb.List()
+	if err != nil {
+		return health
+	}
+
+	tasks := m.GetTasks()
+	for _, s := range services {
+		if s.Status.UpdateState != service.UpdateUpdating && s.Status.UpdateState != service.UpdateRollingBack {
+			continue
+		}
+		for _, t := range tasks {
+			if s.Owns(t) && t.Owner.Revision == s.Revision && t.State == task.Running {
+				health[t.ID] = m.checkServiceTaskHealth(t)
+			}
+		}
+	}
+	return health
+}
+
+// reconcileService drives s towards its desired state. health holds the
+// results of serviceTaskHealth. m.mu must be held.
+func (m *Manager) reconcileService(s *service.Service, tasks []*task.Task, health map[uuid.UUID]error) error {
+	status := s.Status
+	updating := status.UpdateState == service.UpdateUpdating || status.UpdateState == service.UpdateRollingBack
+
+	var current, old []*task.Task
+	var failure error
+	for _, t := range tasks {
+		if !s.Owns(t) || t.DesiredState == task.Completed {
+			continue
+		}
+
+		if !isActive(t) {
+			// Failed tasks are replaced; retire them so they are not
+			// restarted or counted again.
+			if updating && t.Owner.Revision == s.Revision && failure == nil {
+				failure = fmt.Errorf("task %s failed", t.ID)
+			}
+			err := m.retireTask(t)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if t.Owner.Revision == s.Revision {
+			current = append(current, t)
+		} else {
+			old = append(old, t)
+		}
+	}
+
+	available := 0
+	for _, t := range current {
+		if t.State != task.Running {
+			continue
+		}
+		if !updating {
+			available++
+			continue
+		}
+
+		err, checked := health[t.ID]
+		if !checked {
+			// Started running after the health checks.
+			continue
+		}
+		if err == nil {
+			available++
+			continue
+		}
+
+		timeout := time.Duration(s.UpdateConfig.HealthTimeoutSeconds) * time.Second
+		if time.Since(t.StartTime) > timeout {
+			if failure == nil {
+				failure = fmt.Errorf("task %s not healthy after %v: %v", t.ID, timeout, err)
+			}
+			err := m.requestStop(t.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if failure != nil && updating {
+		m.handleUpdateFailure(s, &status, failure)
+	} else if status.UpdateState == service.UpdatePaused {
+		// Leave the tasks alone until the update is resumed or undone.
+	} else if len(old) > 0 {
+		if !updating {
+			status.UpdateState = service.UpdateUpdating
+			status.Message = fmt.Sprintf("updating to revision %d", s.Revision)
+		}
+		err := m.rollService(s, current, old, available)
+		if err != nil {
+			return err
+		}
+	} else {
+		err := m.scaleService(s, current)
+		if err != nil {
+			return err
+		}
+		if updating && available >= s.Replicas {
+			status.UpdateState = service.UpdateCompleted
+			status.Message = fmt.Sprintf("revision %d is available", s.Revision)
+		}
+	}
+
+	status.RunningReplicas, status.PendingReplicas, status.UpdatedReplicas = 0, 0, 0
+	for _, t := range m.GetTasks() {
+		if !s.Owns(t) || t.DesiredState == task.Completed || !isActive(t) {
+			continue
+		}
+		if t.State == task.Running {
+			status.RunningReplicas++
+		} else {
+			status.PendingReplicas++
+		}
+		if t.Owner.Revision == s.Revision {
+			status.UpdatedReplicas++
+		}
+	}
+
+	if status == s.Status && failure == nil {
+		return nil
+	}
+
+	latest, err := m.ServiceDb.Get(s.Key())
+	if err != nil {
+		return err
+	}
+	if latest.Revision != s.Revision && status.UpdateState != service.UpdateRollingBack {
+		// The service was updated while reconciling; pick it up next time.
+		return nil
+	}
+	latest.Template = s.Template
+	latest.Revision = s.Revision
+	latest.History = s.History
+	latest.Status = status
+	return m.ServiceDb.Put(s.Key(), latest)
+}
+
+// handleUpdateFailure pauses the update or starts rolling back to the
+// previous revision, depending on the service's failure action.
+func (m *Manager) handleUpdateFailure(s *service.Service, status *service.Status, failure error) {
+	log.Printf("[manager] service %s: update to revision %d failed: %v\n", s.Name, s.Revision, failure)
+
+	if s.UpdateConfig.FailureAction != service.FailureActionRollback || status.UpdateState == service.UpdateRollingBack {
+		status.UpdateState = service.UpdatePaused
+		status.Message = fmt.Sprintf("update to revision %d paused: %v", s.Revision, failure)
+		return
+	}
+
+	from := s.Revision
+	err := s.Rollback(0)
+	if err != nil {
+		status.UpdateState = service.UpdatePaused
+		status.Message = fmt.Sprintf("update to revision %d paused: %v; rollback failed: %v", from, failure, err)
+		return
+	}
+
+	status.UpdateState = service.UpdateRollingBack
+	status.Message = fmt.Sprintf("revision %d failed (%v); rolling back as revision %d", from, failure, s.Revision)
+}
+
+// scaleService creates or stops tasks of the current revision until there
+// are exactly Replicas of them.
+func (m *Manager) scaleService(s *service.Service, current []*task.Task) error {
+	for i := len(current); i < s.Replicas; i++ {
+		err := m.createServiceTask(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	excess := len(current) - s.Replicas
+	if excess <= 0 {
+		return nil
+	}
+
+	// Remove tasks that are not running yet first, then the newest ones.
+	sort.SliceStable(current, func(i, j int) bool {
+		if current[i].State != current[j].State {
+			return current[i].State < current[j].State
+		}
+		return current[i].StartTime.After(current[j].StartTime)
+	})
+
+	for _, t := range current[:excess] {
+		log.Printf("[manager] service %s: stopping task %s\n", s.Name, t.ID)
+		err := m.requestStop(t.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollService takes one step of a rolling update: it surges new tasks up
+// to Replicas+MaxSurge and removes old ones while at least
+// Replicas-MaxUnavailable tasks stay available.
+func (m *Manager) rollService(s *service.Service, current, old []*task.Task, availableCurrent int) error {
+	maxTotal := s.Replicas + s.UpdateConfig.MaxSurge
+	minAvailable := max(s.Replicas-s.UpdateConfig.MaxUnavailable, 0)
+
+	toCreate := min(maxTotal-len(current)-len(old), s.Replicas-len(current))
+	for i := 0; i < toCreate; i++ {
+		err := m.createServiceTask(s)
+		if err != nil {
+			return err
+		}
+	}
+
+	available := availableCurrent
+	for _, t := range old {
+		if t.State == task.Running {
+			available++
+		}
+	}
+
+	// Old tasks that are not running cost no availability; remove them first.
+	sort.SliceStable(old, func(i, j int) bool {
+		return old[i].State < old[j].State
+	})
+
+	for _, t := range old {
+		if t.State == task.Running {
+			if available <= minAvailable {
+				break
+			}
+			available--
+		}
+
+		log.Printf("[manager] service %s: replacing task %s of revision %d\n", s.Name, t.ID, t.Owner.Revision)
+		err := m.requestStop(t.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) createServiceTask(s *service.Service) error {
+	t := s.NewTask()
+	err := m.admit(&t)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[manager] service %s: creating task %s of revision %d\n", s.Name, t.ID, s.Revision)
+	return m.addTask(task.TaskEvent{
+		ID:        uuid.New(),
+		State:     task.Scheduled,
+		Timestamp: time.Now(),
+		Task:      t,
+	})
+}
+
+// checkServiceTaskHealth treats tasks without a health check as healthy
+// once they are running.
+func (m *Manager) checkServiceTaskHealth(t *task.Task) error {
+	if t.HealthCheck == "" {
+		return nil
+	}
+	return m.checkTaskHealth(*t)
+}
+
+// retireTask marks a task that is no longer running as replaced, so that it
+// is neither restarted nor counted by its owner again.
+func (m *Manager) retireTask(t *task.Task) error {
+	t.DesiredState = task.Completed
+	return m.saveTask(t)
+}
+
+// isActive reports whether t is waiting to run or running.
+func isActive(t *task.Task) bool {
+	switch t.State {
+	case task.Pending, task.Scheduled, task.Running:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,224 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/store"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *Api) CreateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var s service.Service
+	err := d.Decode(&s)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	err = setNamespace(r, &s.Namespace)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = a.Manager.CreateService(&s)
+	if errors.Is(err, ErrServiceExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, s)
+}
+
+func (a *Api) GetServicesHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, a.Manager.GetServices(namespaceParam(r)))
+}
+
+func (a *Api) GetServiceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	s, err := a.Manager.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (a *Api) ScaleServiceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var req service.ScaleRequest
+	err := d.Decode(&req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	s, err := a.Manager.ScaleService(ns, name, req.Replicas)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (a *Api) DeleteServiceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	err := a.Manager.DeleteService(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondStoreError maps store.ErrNotFound to 404 and everything else to 400.
+func respondStoreError(w http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, store.ErrNotFound) {
+		respondError(w, http.StatusNotFound, notFound)
+		return
+	}
+	respondError(w, http.StatusBadRequest, err.Error())
+}
+
+func (a *Api) UpdateServiceHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var spec service.Service
+	err := d.Decode(&spec)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+	if spec.Name == "" {
+		spec.Name = name
+	}
+	if spec.Name != name {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("service name %q does not match %q", spec.Name, name))
+		return
+	}
+
+	err = setNamespace(r, &spec.Namespace)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s, err := a.Manager.UpdateService(&spec)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (a *Api) GetServiceRevisionsHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	s, err := a.Manager.ServiceDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s.Revisions())
+}
+
+func (a *Api) RollbackServiceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+
+	var req service.RollbackRequest
+	if r.ContentLength != 0 {
+		d := json.NewDecoder(r.Body)
+		d.DisallowUnknownFields()
+		err := d.Decode(&req)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+			return
+		}
+	}
+
+	s, err := a.Manager.RollbackService(ns, name, req.Revision)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (a *Api) PauseServiceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	s, err := a.Manager.PauseService(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (a *Api) ResumeServiceHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	s, err := a.Manager.ResumeService(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (a *Api) SetServiceAutoscaleHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var as service.Autoscale
+	err := d.Decode(&as)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	s, err := a.Manager.SetServiceAutoscale(ns, name, &as)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s)
+}
+
+func (a *Api) DeleteServiceAutoscaleHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	_, err := a.Manager.SetServiceAutoscale(ns, name, nil)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no service with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
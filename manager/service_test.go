@@ -0,0 +1,214 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/service"
+	"github.com/dev6699/cube/task"
+)
+
+func TestRecreatedServiceStartsOver(t *testing.T) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newService := func() *service.Service {
+		return &service.Service{
+			Name:      "web",
+			Namespace: namespace.Default,
+			Replicas:  1,
+			Template:  task.Task{Image: "nginx"},
+		}
+	}
+	old := newService()
+	err = m.CreateService(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := old.NewTask()
+	failed.State = task.Failed
+	err = m.TaskDb.Put(failed.ID.String(), &failed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = m.DeleteService(old.Namespace, old.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newService()
+	err = m.CreateService(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.reconcileServices()
+
+	owned := 0
+	for _, tk := range m.GetTasks() {
+		if s.Owns(tk) {
+			owned++
+		}
+	}
+	if owned != 1 {
+		t.Errorf("the new service owns %d tasks, want only its own replica", owned)
+	}
+	tk, err := m.TaskDb.Get(failed.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tk.DesiredState != task.Running {
+		t.Errorf("the new service retired task %s of the old one", tk.ID)
+	}
+}
+
+// stepServices runs the service controller once and lets the workers start
+// and stop the tasks it asked for.
+func stepServices(t *testing.T, m *Manager) {
+	m.reconcileServices()
+	dispatchAll(t, m)
+	m.updateTasks()
+}
+
+// serviceReplicas returns the tasks of s that are meant to run, and how
+// many of those are running, by image.
+func serviceReplicas(m *Manager, s *service.Service) ([]*task.Task, map[string]int) {
+	var active []*task.Task
+	running := make(map[string]int)
+	for _, tk := range m.GetTasks() {
+		if !s.Owns(tk) || tk.DesiredState == task.Completed || !isActive(tk) {
+			continue
+		}
+		active = append(active, tk)
+		if tk.State == task.Running {
+			running[tk.Image]++
+		}
+	}
+	return active, running
+}
+
+func getService(t *testing.T, m *Manager, s *service.Service) *service.Service {
+	latest, err := m.ServiceDb.Get(s.Key())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return latest
+}
+
+func TestRollingUpdate(t *testing.T) {
+	m, _, _ := newDispatchTest(t, 3)
+	s := &service.Service{
+		Name:         "web",
+		Namespace:    namespace.Default,
+		Replicas:     3,
+		Template:     task.Task{Image: "nginx:1"},
+		UpdateConfig: service.UpdateConfig{MaxSurge: 1, MaxUnavailable: 0},
+	}
+	err := m.CreateService(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		stepServices(t, m)
+	}
+	if _, running := serviceReplicas(m, s); running["nginx:1"] != 3 {
+		t.Fatalf("got running replicas %v, want 3 of nginx:1", running)
+	}
+
+	spec := *s
+	spec.Template = task.Task{Image: "nginx:2"}
+	_, err = m.UpdateService(&spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 30 && getService(t, m, s).Status.UpdateState != service.UpdateCompleted; i++ {
+		// Check what the controller asked for before the workers act
+		// on it: new replicas only count once they have been running
+		// for a round.
+		m.reconcileServices()
+		active, running := serviceReplicas(m, s)
+		if len(active) > s.Replicas+1 {
+			t.Fatalf("step %d: %d replicas, more than the surge of 1 allows", i, len(active))
+		}
+		if n := running["nginx:1"] + running["nginx:2"]; n < s.Replicas {
+			t.Fatalf("step %d: %d replicas running, but none may be unavailable", i, n)
+		}
+		dispatchAll(t, m)
+		m.updateTasks()
+	}
+
+	latest := getService(t, m, s)
+	if latest.Status.UpdateState != service.UpdateCompleted {
+		t.Fatalf("update is %s: %s", latest.Status.UpdateState, latest.Status.Message)
+	}
+	active, running := serviceReplicas(m, s)
+	if len(active) != 3 || running["nginx:2"] != 3 {
+		t.Errorf("got %d replicas with %v running, want 3 of nginx:2", len(active), running)
+	}
+}
+
+func TestFailedUpdateRollsBack(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 2)
+	s := &service.Service{
+		Name:      "web",
+		Namespace: namespace.Default,
+		Replicas:  2,
+		Template:  task.Task{Image: "nginx:1"},
+		UpdateConfig: service.UpdateConfig{
+			MaxSurge:      1,
+			FailureAction: service.FailureActionRollback,
+		},
+	}
+	err := m.CreateService(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		stepServices(t, m)
+	}
+
+	spec := *s
+	spec.Template = task.Task{Image: "nginx:broken"}
+	_, err = m.UpdateService(&spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepServices(t, m)
+
+	// The new revision crashes on the workers.
+	crashed := 0
+	for _, fw := range fws {
+		fw.mu.Lock()
+		for _, tk := range fw.tasks {
+			if tk.Image == "nginx:broken" {
+				tk.State = task.Failed
+				crashed++
+			}
+		}
+		fw.mu.Unlock()
+	}
+	if crashed == 0 {
+		t.Fatal("no task of the new revision was started")
+	}
+	m.updateTasks()
+
+	stepServices(t, m)
+	if latest := getService(t, m, s); latest.Status.UpdateState != service.UpdateRollingBack {
+		t.Fatalf("update is %s after the new revision failed, want it rolling back", latest.Status.UpdateState)
+	}
+	for i := 0; i < 30 && getService(t, m, s).Status.UpdateState != service.UpdateCompleted; i++ {
+		stepServices(t, m)
+	}
+
+	latest := getService(t, m, s)
+	if latest.Status.UpdateState != service.UpdateCompleted || latest.Template.Image != "nginx:1" || latest.Revision != 3 {
+		t.Fatalf("got revision %d of %s, update %s, want revision 3 of nginx:1 completed",
+			latest.Revision, latest.Template.Image, latest.Status.UpdateState)
+	}
+	active, running := serviceReplicas(m, latest)
+	if len(active) != 2 || running["nginx:1"] != 2 {
+		t.Errorf("got %d replicas with %v running, want 2 of nginx:1", len(active), running)
+	}
+}
@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// DefaultIdempotencyKeyTTL is how long an idempotency key is remembered
+// after the request that used it first.
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+var (
+	// ErrTaskExists is returned when a task is submitted with the ID of a
+	// task the manager already knows.
+	ErrTaskExists = errors.New("task already exists")
+	// ErrIdempotencyKeyReused is returned when an idempotency key is sent
+	// again with a different request.
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+)
+
+// Submit admits te's task against the quota of its namespace and adds it.
+// Admission and adding happen atomically, so concurrent submissions cannot
+// exceed a quota together. Tasks and events without an ID are given one.
+//
+// A non-empty key makes the submission idempotent: as long as the key is
+// remembered, submitting the same event with it again returns the task it
+// created first, and created is false.
+func (m *Manager) Submit(te task.TaskEvent, key string) (t *task.Task, created bool, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var digest string
+	if key != "" {
+		digest, err = eventDigest(te)
+		if err != nil {
+			return nil, false, err
+		}
+
+		s, err := m.SubmissionDb.Get(submissionKey(te.Task.Namespace, key))
+		if err == nil && time.Since(s.Timestamp) < m.IdempotencyKeyTTL {
+			if s.Digest != digest {
+				return nil, false, ErrIdempotencyKeyReused
+			}
+			t, err := m.TaskDb.Get(s.TaskID.String())
+			return t, false, err
+		}
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return nil, false, err
+		}
+	}
+
+	if te.ID == uuid.Nil {
+		te.ID = uuid.New()
+	}
+	if te.State != task.Completed {
+		if te.Task.ID == uuid.Nil {
+			te.Task.ID = uuid.New()
+		}
+		_, err = m.TaskDb.Get(te.Task.ID.String())
+		if err == nil {
+			return nil, false, fmt.Errorf("task %s: %w", te.Task.ID, ErrTaskExists)
+		}
+		if !errors.Is(err, store.ErrNotFound) {
+			return nil, false, err
+		}
+
+		err = m.admit(&te.Task)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+	err = m.addTask(te)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if key != "" {
+		err = m.SubmissionDb.Put(submissionKey(te.Task.Namespace, key), &task.Submission{
+			Key:       key,
+			Namespace: te.Task.Namespace,
+			Digest:    digest,
+			TaskID:    te.Task.ID,
+			Timestamp: time.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("[manager] error saving idempotency key of task %s: %v\n", te.Task.ID, err)
+		}
+	}
+
+	t, err = m.TaskDb.Get(te.Task.ID.String())
+	if errors.Is(err, store.ErrNotFound) {
+		return &te.Task, true, nil
+	}
+	return t, true, err
+}
+
+// ExpireIdempotencyKeys periodically forgets the idempotency keys older
+// than IdempotencyKeyTTL.
+func (m *Manager) ExpireIdempotencyKeys(ctx context.Context) error {
+	ticker := time.NewTicker(m.IdempotencyKeyTTL / 24)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := m.expireIdempotencyKeys()
+			if err != nil {
+				log.Printf("[manager] error expire idempotency keys: %v\n", err)
+			}
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Manager) expireIdempotencyKeys() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	submissions, err := m.SubmissionDb.List()
+	if err != nil {
+		return err
+	}
+	for _, s := range submissions {
+		if time.Since(s.Timestamp) < m.IdempotencyKeyTTL {
+			continue
+		}
+		err := m.SubmissionDb.Delete(submissionKey(s.Namespace, s.Key))
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// submissionKey is the key of the submission with the idempotency key key
+// in namespace ns. Keys are scoped to their namespace.
+func submissionKey(ns string, key string) string {
+	return ns + "/" + key
+}
+
+// eventDigest identifies te as it was submitted, before any IDs are
+// generated for it.
+func eventDigest(te task.TaskEvent) (string, error) {
+	data, err := json.Marshal(te)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
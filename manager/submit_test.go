@@ -0,0 +1,77 @@
+package manager
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+func TestSubmitGeneratesIDs(t *testing.T) {
+	m, api := newTestManager(t, 1)
+
+	te := newTaskEvent("")
+	te.ID = uuid.Nil
+	te.Task.ID = uuid.Nil
+	var tk task.Task
+	status := doRequest(t, http.MethodPost, api.URL+"/tasks", te, decodeInto(&tk))
+	if status != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", status, http.StatusCreated)
+	}
+	if tk.ID == uuid.Nil || tk.State != task.Pending {
+		t.Fatalf("got task %s in state %s, want a new ID and Pending", tk.ID, tk.State)
+	}
+	if _, err := m.TaskDb.Get(tk.ID.String()); err != nil {
+		t.Errorf("returned task is not stored: %v", err)
+	}
+}
+
+func TestSubmitExistingTaskConflicts(t *testing.T) {
+	_, api := newTestManager(t, 1)
+
+	te := newTaskEvent("")
+	if status := doRequest(t, http.MethodPost, api.URL+"/tasks", te); status != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", status, http.StatusCreated)
+	}
+	te.ID = uuid.New()
+	te.Task.Image = "nginx"
+	if status := doRequest(t, http.MethodPost, api.URL+"/tasks", te); status != http.StatusConflict {
+		t.Errorf("resubmitting task ID: got status %d, want %d", status, http.StatusConflict)
+	}
+}
+
+func TestSubmitWithIdempotencyKey(t *testing.T) {
+	m, api := newTestManager(t, 1)
+
+	te := newTaskEvent("")
+	te.Task.ID = uuid.Nil
+	key := withHeader(idempotencyKeyHeader, "run-1")
+	var first, again task.Task
+	status := doRequest(t, http.MethodPost, api.URL+"/tasks", te, key, decodeInto(&first))
+	if status != http.StatusCreated {
+		t.Fatalf("got status %d, want %d", status, http.StatusCreated)
+	}
+	status = doRequest(t, http.MethodPost, api.URL+"/tasks", te, key, decodeInto(&again))
+	if status != http.StatusOK || again.ID != first.ID {
+		t.Errorf("repeated submission: got status %d for task %s, want %d for task %s", status, again.ID, http.StatusOK, first.ID)
+	}
+	if tasks := m.GetTasks(); len(tasks) != 1 {
+		t.Errorf("got %d tasks, want 1", len(tasks))
+	}
+
+	te.Task.Image = "nginx"
+	if status := doRequest(t, http.MethodPost, api.URL+"/tasks", te, key); status != http.StatusUnprocessableEntity {
+		t.Errorf("key reused for another task: got status %d, want %d", status, http.StatusUnprocessableEntity)
+	}
+
+	// Forgotten keys can be used again.
+	m.IdempotencyKeyTTL = 0
+	if err := m.expireIdempotencyKeys(); err != nil {
+		t.Fatal(err)
+	}
+	m.IdempotencyKeyTTL = DefaultIdempotencyKeyTTL
+	if status := doRequest(t, http.MethodPost, api.URL+"/tasks", te, key); status != http.StatusCreated {
+		t.Errorf("expired key: got status %d, want %d", status, http.StatusCreated)
+	}
+}
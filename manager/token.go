@@ -0,0 +1,116 @@
+package manager
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/auth"
+	"github.com/dev6699/cube/store"
+)
+
+// bootstrapTokenName is the name of the token the manager creates when it
+// requires authentication but has no tokens yet.
+const bootstrapTokenName = "admin"
+
+var ErrTokenExists = errors.New("token already exists")
+
+// CreateToken issues a token with the given name, which expires at
+// expiresAt unless that is zero, and returns it with its secret.
+func (m *Manager) CreateToken(name string, expiresAt time.Time) (*auth.Token, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.TokenDb.Get(name)
+	if err == nil {
+		return nil, "", ErrTokenExists
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return nil, "", err
+	}
+
+	t, secret, err := auth.NewToken(name, expiresAt)
+	if err != nil {
+		return nil, "", err
+	}
+	err = m.TokenDb.Put(t.Name, t)
+	if err != nil {
+		return nil, "", err
+	}
+	return t, secret, nil
+}
+
+// GetTokens lists the tokens by name, without their hashes.
+func (m *Manager) GetTokens() ([]*auth.Token, error) {
+	tokens, err := m.TokenDb.List()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*auth.Token, 0, len(tokens))
+	for _, t := range tokens {
+		tc := *t
+		tc.Hash = ""
+		list = append(list, &tc)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// DeleteToken revokes the named token.
+func (m *Manager) DeleteToken(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := m.TokenDb.Get(name)
+	if err != nil {
+		return err
+	}
+	return m.TokenDb.Delete(name)
+}
+
+// Authenticate reports whether secret is the secret of a token that has
+// not expired.
+func (m *Manager) Authenticate(secret string) bool {
+	tokens, err := m.TokenDb.List()
+	if err != nil {
+		log.Printf("[manager] error listing tokens: %v\n", err)
+		return false
+	}
+
+	now := time.Now()
+	for _, t := range tokens {
+		if t.Verify(secret, now) {
+			return true
+		}
+	}
+	return false
+}
+
+// BootstrapToken creates a token named admin if there are no tokens, so
+// that a manager requiring authentication can be used at all. It returns
+// the new token's secret, or "" if there were tokens already.
+func (m *Manager) BootstrapToken() (string, error) {
+	n, err := m.TokenDb.Count()
+	if err != nil || n > 0 {
+		return "", err
+	}
+
+	_, secret, err := m.CreateToken(bootstrapTokenName, time.Time{})
+	if errors.Is(err, ErrTokenExists) {
+		return "", nil
+	}
+	return secret, err
+}
+
+// UseClusterToken makes the requests the manager sends to workers carry
+// token. It must be called before the manager runs.
+func (m *Manager) UseClusterToken(token string) {
+	if token == "" {
+		return
+	}
+	m.Client.Transport = &auth.Transport{Token: token, Base: m.Client.Transport}
+}
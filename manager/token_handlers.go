@@ -0,0 +1,103 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dev6699/cube/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreatedToken is a new token along with its secret, which is not shown
+// again.
+type CreatedToken struct {
+	auth.Token
+	Secret string
+}
+
+func (a *Api) CreateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var spec struct {
+		Name      string
+		ExpiresAt time.Time
+	}
+	err := d.Decode(&spec)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	t, secret, err := a.Manager.CreateToken(spec.Name, spec.ExpiresAt)
+	if errors.Is(err, ErrTokenExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	created := CreatedToken{Token: *t, Secret: secret}
+	created.Hash = ""
+	respondJSON(w, http.StatusCreated, created)
+}
+
+func (a *Api) GetTokensHandler(w http.ResponseWriter, r *http.Request) {
+	tokens, err := a.Manager.GetTokens()
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, tokens)
+}
+
+func (a *Api) DeleteTokenHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	err := a.Manager.DeleteToken(name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no token with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticate lets requests through that carry the secret of one of the
+// manager's tokens. The routes workers and the other replicas use only
+// accept the cluster token, so that clients cannot pose as either.
+func (a *Api) authenticate(next http.Handler) http.Handler {
+	allow := func(r *http.Request, secret string) bool {
+		isClusterToken := a.ClusterToken != "" && auth.Equal(secret, a.ClusterToken)
+		if internalRoute(r) {
+			return isClusterToken
+		}
+		return !isClusterToken && a.Manager.Authenticate(secret)
+	}
+	deny := func(w http.ResponseWriter, r *http.Request) {
+		respondProxyError(w, r, http.StatusUnauthorized, "a valid token is required")
+	}
+	return auth.Middleware(allow, deny)(next)
+}
+
+// internalRoute reports whether r is sent by a worker or another replica:
+// a request of the cluster protocol, or a worker registering or sending a
+// heartbeat. The cluster status at /cluster is a client route.
+func internalRoute(r *http.Request) bool {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	switch {
+	case strings.HasPrefix(path, "/cluster/"):
+		return true
+	case r.Method == http.MethodPost && path == "/nodes":
+		return true
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/nodes/") && strings.HasSuffix(path, "/heartbeat"):
+		return true
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/task"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+)
+
+func TestAuthentication(t *testing.T) {
+	m, err := New(nil, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := m.BootstrapToken()
+	if err != nil || admin == "" {
+		t.Fatalf("got bootstrap secret %q and error %v", admin, err)
+	}
+	if again, err := m.BootstrapToken(); again != "" || err != nil {
+		t.Errorf("second bootstrap: got secret %q and error %v, want none", again, err)
+	}
+
+	api := NewApi("", 0, m)
+	api.Auth = true
+	api.ClusterToken = "cluster-secret"
+	api.initRouter()
+	srv := httptest.NewServer(api.Router)
+	defer srv.Close()
+
+	var created CreatedToken
+	status := doRequest(t, http.MethodPost, srv.URL+"/tokens", map[string]any{"Name": "ci"}, withToken(admin), decodeInto(&created))
+	if status != http.StatusCreated || created.Secret == "" || created.Hash != "" {
+		t.Fatalf("create token: got status %d and %+v", status, created)
+	}
+
+	reg := node.Registration{Name: "worker-1", Address: "localhost:1"}
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   any
+		status int
+	}{
+		{"no token", http.MethodGet, "/tasks", "", nil, http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/v1/tasks", "cube_wrong", nil, http.StatusUnauthorized},
+		{"admin token", http.MethodGet, "/tasks", admin, nil, http.StatusOK},
+		{"created token", http.MethodGet, "/v1/nodes", created.Secret, nil, http.StatusOK},
+		{"cluster token on a client route", http.MethodGet, "/tasks", "cluster-secret", nil, http.StatusUnauthorized},
+		{"cluster token registering a worker", http.MethodPost, "/nodes", "cluster-secret", reg, http.StatusOK},
+		{"admin token registering a worker", http.MethodPost, "/nodes", admin, reg, http.StatusUnauthorized},
+		{"created token sending a heartbeat", http.MethodPut, "/nodes/worker-1/heartbeat", created.Secret, nil, http.StatusUnauthorized},
+		{"created token voting", http.MethodPost, "/cluster/vote", created.Secret, map[string]any{"Term": 9, "Candidate": "x"}, http.StatusUnauthorized},
+		{"created token reading the log", http.MethodGet, "/cluster/log", created.Secret, nil, http.StatusUnauthorized},
+		{"revoke token", http.MethodDelete, "/tokens/ci", admin, nil, http.StatusNoContent},
+		{"revoked token", http.MethodGet, "/tasks", created.Secret, nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		status := doRequest(t, tt.method, srv.URL+tt.path, tt.body, withToken(tt.token))
+		if status != tt.status {
+			t.Errorf("%s: got status %d, want %d", tt.name, status, tt.status)
+		}
+	}
+}
+
+func TestClusterTokenIsOnlySentToWorkers(t *testing.T) {
+	var mu sync.Mutex
+	tokens := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		tokens[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.Write([]byte("{}"))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+
+	m, err := New([]string{u.Host}, "roundrobin", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.UseClusterToken("cluster-secret")
+
+	_, err = m.getNodeStats(m.WorkerNodes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := task.Task{
+		ID:          uuid.New(),
+		HealthCheck: "/health",
+		HostPorts:   nat.PortMap{"80/tcp": {{HostPort: u.Port()}}},
+	}
+	m.mu.Lock()
+	m.assignTask(&tk, u.Host)
+	m.mu.Unlock()
+	err = m.checkTaskHealth(tk)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := tokens["/stats"]; got != "Bearer cluster-secret" {
+		t.Errorf("got Authorization %q on the worker request, want the cluster token", got)
+	}
+	if got, ok := tokens["/health"]; !ok || got != "" {
+		t.Errorf("got Authorization %q on the health check, want none", got)
+	}
+}
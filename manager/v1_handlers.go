@@ -0,0 +1,244 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// v1Routes serves version 1 of the API, which is documented by
+// v1.ManagerSpec.
+func (a *Api) v1Routes(r chi.Router) {
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		v1.RespondError(w, v1.NewError(http.StatusNotFound, fmt.Sprintf("no route %s", r.URL.Path)))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		v1.RespondError(w, v1.NewError(http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed on %s", r.Method, r.URL.Path)))
+	})
+
+	r.Get("/openapi.json", v1.SpecHandler(v1.ManagerSpec))
+	a.v1NamespacedRoutes(r)
+	r.Route("/namespaces/{namespace}", func(r chi.Router) {
+		r.Use(a.v1NamespaceCtx)
+		a.v1NamespacedRoutes(r)
+	})
+	r.Get("/nodes", a.V1ListNodesHandler)
+	r.Get("/nodes/{name}", a.V1GetNodeHandler)
+}
+
+func (a *Api) v1NamespacedRoutes(r chi.Router) {
+	r.Post("/tasks", a.V1SubmitTaskHandler)
+	r.Get("/tasks", a.V1ListTasksHandler)
+	r.Get("/tasks/{taskID}", a.V1GetTaskHandler)
+	r.Delete("/tasks/{taskID}", a.V1StopTaskHandler)
+	r.Get("/tasks/{taskID}/logs", a.V1TaskLogsHandler)
+}
+
+func (a *Api) v1NamespaceCtx(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := namespaceParam(r)
+		_, err := a.Manager.NamespaceDb.Get(name)
+		if err != nil {
+			respondV1StoreError(w, err, fmt.Sprintf("no namespace with name %v found", name))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *Api) V1SubmitTaskHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var req v1.TaskRequest
+	err := d.Decode(&req)
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, fmt.Sprintf("invalid request body: %v", err)))
+		return
+	}
+	if req.Image == "" {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, "a task needs an image"))
+		return
+	}
+	err = labels.Validate(req.Labels)
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	key := r.Header.Get(idempotencyKeyHeader)
+	if len(key) > maxIdempotencyKeyLen {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, fmt.Sprintf("%s is longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLen)))
+		return
+	}
+
+	te := task.TaskEvent{State: task.Scheduled, Task: req.TaskSpec()}
+	te.Task.Namespace = namespaceParam(r)
+	t, created, err := a.Manager.Submit(te, key)
+	switch {
+	case errors.Is(err, namespace.ErrQuotaExceeded):
+		v1.RespondError(w, &v1.Error{Status: http.StatusForbidden, Reason: v1.ReasonQuotaExceeded, Message: err.Error()})
+		return
+	case errors.Is(err, ErrTaskExists):
+		v1.RespondError(w, v1.NewError(http.StatusConflict, err.Error()))
+		return
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		v1.RespondError(w, &v1.Error{Status: http.StatusUnprocessableEntity, Reason: v1.ReasonIdempotencyKeyReused, Message: err.Error()})
+		return
+	case err != nil:
+		v1.RespondError(w, v1.NewError(http.StatusInternalServerError, fmt.Sprintf("error admitting task: %v", err)))
+		return
+	}
+
+	if !created {
+		w.Header().Set(idempotentReplayedHeader, "true")
+		v1.Respond(w, http.StatusOK, v1.FromTask(t))
+		return
+	}
+	v1.Respond(w, http.StatusCreated, v1.FromTask(t))
+}
+
+func (a *Api) V1ListTasksHandler(w http.ResponseWriter, r *http.Request) {
+	q, err := v1.ParseTaskQuery(r.URL.Query())
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	page, err := a.Manager.QueryTasks(namespaceParam(r), q)
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	v1.Respond(w, http.StatusOK, v1.TaskList{
+		Items: v1.FromTasks(page.Tasks),
+		Next:  page.Next,
+	})
+}
+
+func (a *Api) V1GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.v1Task(w, r)
+	if !ok {
+		return
+	}
+
+	v1.Respond(w, http.StatusOK, v1.FromTask(t))
+}
+
+func (a *Api) V1StopTaskHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.v1Task(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.Manager.StopTask(t.ID)
+	if err != nil {
+		respondV1StoreError(w, err, fmt.Sprintf("no task with ID %v found", t.ID))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// V1TaskLogsHandler streams the output of a task from the worker it runs on.
+func (a *Api) V1TaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.v1Task(w, r)
+	if !ok {
+		return
+	}
+	if t.Worker == "" {
+		v1.RespondError(w, v1.NewError(http.StatusConflict, fmt.Sprintf("task %v is not placed on a worker yet", t.ID)))
+		return
+	}
+	api := a.Manager.workerApi(t.Worker)
+	target, err := url.Parse(api)
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusInternalServerError, fmt.Sprintf("invalid worker address %q: %v", api, err)))
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			pr.Out.URL.Path = fmt.Sprintf("/v1/tasks/%s/logs", t.ID)
+			pr.Out.URL.RawPath = ""
+			// The worker only accepts the cluster token, which the
+			// manager's transport adds in place of the client's.
+			pr.Out.Header.Del("Authorization")
+		},
+		Transport: a.Manager.Client.Transport,
+		// Flush every write, so that followed logs arrive as they are written.
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			v1.RespondError(w, v1.NewError(http.StatusBadGateway, fmt.Sprintf("error reading logs from worker %s: %v", t.Worker, err)))
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+func (a *Api) V1ListNodesHandler(w http.ResponseWriter, r *http.Request) {
+	sel, err := labels.Parse(r.URL.Query().Get("labelSelector"))
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, fmt.Sprintf("invalid label selector: %v", err)))
+		return
+	}
+
+	list := v1.NodeList{Items: []v1.Node{}}
+	for _, n := range a.Manager.GetNodes(sel) {
+		list.Items = append(list.Items, v1.FromNode(n))
+	}
+	v1.Respond(w, http.StatusOK, list)
+}
+
+func (a *Api) V1GetNodeHandler(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	for _, n := range a.Manager.GetNodes(nil) {
+		if n.Name == name {
+			v1.Respond(w, http.StatusOK, v1.FromNode(n))
+			return
+		}
+	}
+
+	v1.RespondError(w, v1.NewError(http.StatusNotFound, fmt.Sprintf("no node with name %v found", name)))
+}
+
+// v1Task looks up the task named in the request path within the request's
+// namespace. It responds with an error and reports false if there is none.
+func (a *Api) v1Task(w http.ResponseWriter, r *http.Request) (*task.Task, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, fmt.Sprintf("invalid task ID: %v", err)))
+		return nil, false
+	}
+
+	t, err := a.Manager.TaskDb.Get(id.String())
+	if err == nil && t.Namespace != namespaceParam(r) {
+		err = store.ErrNotFound
+	}
+	if err != nil {
+		respondV1StoreError(w, err, fmt.Sprintf("no task with ID %v found", id))
+		return nil, false
+	}
+	return t, true
+}
+
+// respondV1StoreError is respondStoreError for version 1 of the API.
+func respondV1StoreError(w http.ResponseWriter, err error, notFound string) {
+	if errors.Is(err, store.ErrNotFound) {
+		v1.RespondError(w, v1.NewError(http.StatusNotFound, notFound))
+		return
+	}
+	v1.RespondError(w, v1.NewError(http.StatusInternalServerError, err.Error()))
+}
@@ -0,0 +1,125 @@
+package manager
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/task"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestV1RoutesMatchSpec(t *testing.T) {
+	api := NewApi("", 0, nil)
+	api.initRouter()
+
+	var routes []string
+	err := chi.Walk(api.Router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/v1/") {
+			routes = append(routes, method+" "+route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(routes)
+
+	documented, err := v1.Operations(v1.ManagerSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(routes, documented) {
+		t.Errorf("routes %v\ndo not match the documented operations %v", routes, documented)
+	}
+}
+
+func TestV1Tasks(t *testing.T) {
+	_, api := newTestManager(t, 1)
+	base := api.URL + "/v1/namespaces/default"
+
+	var created v1.Task
+	status := doRequest(t, http.MethodPost, base+"/tasks", v1.TaskRequest{Name: "echo", Image: "alpine", ExposedPorts: []string{"80/tcp"}}, decodeInto(&created))
+	if status != http.StatusCreated {
+		t.Fatalf("submit task: got status %d", status)
+	}
+	if created.ID == uuid.Nil || created.State != "Pending" || created.Namespace != "default" {
+		t.Errorf("got task %+v, want a Pending task with an ID in the default namespace", created)
+	}
+
+	var got v1.Task
+	if status := doRequest(t, http.MethodGet, api.URL+"/v1/tasks/"+created.ID.String(), nil, decodeInto(&got)); status != http.StatusOK {
+		t.Fatalf("get task: got status %d", status)
+	}
+	if got.ID != created.ID || !slices.Equal(got.ExposedPorts, []string{"80/tcp"}) {
+		t.Errorf("got task %+v, want %+v", got, created)
+	}
+
+	var list v1.TaskList
+	if status := doRequest(t, http.MethodGet, base+"/tasks?state=Pending", nil, decodeInto(&list)); status != http.StatusOK {
+		t.Fatalf("list tasks: got status %d", status)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != created.ID {
+		t.Errorf("got tasks %+v, want the submitted task", list.Items)
+	}
+
+	// Errors have the same shape, whatever went wrong.
+	errs := []struct {
+		method string
+		url    string
+		body   any
+		status int
+		reason v1.Reason
+	}{
+		{http.MethodPost, base + "/tasks", v1.TaskRequest{ID: created.ID, Image: "alpine"}, http.StatusConflict, v1.ReasonConflict},
+		{http.MethodPost, base + "/tasks", map[string]any{"Image": "alpine", "State": 2}, http.StatusBadRequest, v1.ReasonBadRequest},
+		{http.MethodGet, base + "/tasks/" + uuid.NewString(), nil, http.StatusNotFound, v1.ReasonNotFound},
+		{http.MethodGet, api.URL + "/v1/namespaces/missing/tasks", nil, http.StatusNotFound, v1.ReasonNotFound},
+		{http.MethodGet, api.URL + "/v1/jobs", nil, http.StatusNotFound, v1.ReasonNotFound},
+		{http.MethodPut, base + "/tasks", nil, http.StatusMethodNotAllowed, v1.ReasonMethodNotAllowed},
+	}
+	for _, e := range errs {
+		var body v1.Error
+		status := doRequest(t, e.method, e.url, e.body, decodeInto(&body))
+		if status != e.status || body.Status != e.status || body.Reason != e.reason || body.Message == "" {
+			t.Errorf("%s %s: got status %d and %+v, want status %d with reason %s", e.method, e.url, status, body, e.status, e.reason)
+		}
+	}
+}
+
+func TestV1TaskLogs(t *testing.T) {
+	m, api := newTestManager(t, 1)
+
+	placed := &task.Task{ID: uuid.New(), Namespace: "default", Image: "alpine", State: task.Running, Worker: "worker-0"}
+	pending := &task.Task{ID: uuid.New(), Namespace: "default", Image: "alpine", State: task.Pending}
+	for _, tk := range []*task.Task{placed, pending} {
+		if err := m.TaskDb.Put(tk.ID.String(), tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := http.Get(api.URL + "/v1/tasks/" + placed.ID.String() + "/logs?tail=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("logs of %s, tail 5\n", placed.ID)
+	if resp.StatusCode != http.StatusOK || string(body) != want {
+		t.Errorf("got status %d and %q, want the worker's logs %q", resp.StatusCode, body, want)
+	}
+
+	var e v1.Error
+	status := doRequest(t, http.MethodGet, api.URL+"/v1/tasks/"+pending.ID.String()+"/logs", nil, decodeInto(&e))
+	if status != http.StatusConflict || e.Reason != v1.ReasonConflict {
+		t.Errorf("logs of a pending task: got status %d and %+v", status, e)
+	}
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dev6699/cube/client"
+	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/watch"
+	"github.com/google/uuid"
+)
+
+func TestWatchIsScopedToNamespace(t *testing.T) {
+	m, srv := newTestManager(t, 0)
+	newTask := func(ns string) *task.Task {
+		return &task.Task{ID: uuid.New(), Namespace: ns, State: task.Pending}
+	}
+	for _, tk := range []*task.Task{newTask("default"), newTask("team-a")} {
+		err := m.TaskDb.Put(tk.ID.String(), tk)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c := client.New(srv.URL)
+	c.Namespace = "team-a"
+	w, err := c.Watch(ctx, watch.KindTask, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	m.Hub.Publish(watch.Event{Type: watch.Added, Kind: watch.KindTask, Task: newTask("default")})
+	m.Hub.Publish(watch.Event{Type: watch.Added, Kind: watch.KindTask, Task: newTask("team-a")})
+	for i := 0; i < 2; i++ {
+		e, err := w.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Task.Namespace != "team-a" {
+			t.Errorf("got an event of a task in namespace %s", e.Task.Namespace)
+		}
+	}
+}
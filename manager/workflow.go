@@ -0,0 +1,242 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/workflow"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWorkflowExists = errors.New("workflow already exists")
+)
+
+func (m *Manager) CreateWorkflow(w *workflow.Workflow) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := w.Validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = m.WorkflowDb.Get(w.Key())
+	if err == nil {
+		return ErrWorkflowExists
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	w.CreatedAt = time.Now().UTC()
+	w.Status = workflow.Status{
+		State:     workflow.Running,
+		StartTime: w.CreatedAt,
+	}
+	for _, s := range w.Steps {
+		w.Status.Steps = append(w.Status.Steps, workflow.StepStatus{
+			Name:  s.Name,
+			State: workflow.StepPending,
+		})
+	}
+	return m.WorkflowDb.Put(w.Key(), w)
+}
+
+// GetWorkflows returns the workflows of namespace ns.
+func (m *Manager) GetWorkflows(ns string) []*workflow.Workflow {
+	all, err := m.WorkflowDb.List()
+	if err != nil {
+		return []*workflow.Workflow{}
+	}
+
+	workflows := []*workflow.Workflow{}
+	for _, w := range all {
+		if w.Namespace == ns {
+			workflows = append(workflows, w)
+		}
+	}
+
+	sort.Slice(workflows, func(i, j int) bool {
+		return workflows[i].Name < workflows[j].Name
+	})
+	return workflows
+}
+
+// DeleteWorkflow stops the running steps of a workflow and removes it.
+func (m *Manager) DeleteWorkflow(ns string, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w, err := m.WorkflowDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		return err
+	}
+
+	for _, s := range w.Status.Steps {
+		if s.State != workflow.StepRunning {
+			continue
+		}
+
+		err := m.requestStop(s.TaskID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			return err
+		}
+	}
+	return m.WorkflowDb.Delete(namespace.Key(ns, name))
+}
+
+// RunWorkflows schedules workflow steps as their dependencies complete.
+func (m *Manager) RunWorkflows(ctx context.Context) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.runWorkflows()
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *Manager) runWorkflows() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	workflows, err := m.WorkflowDb.List()
+	if err != nil {
+		log.Printf("[manager] error listing workflows: %v\n", err)
+		return
+	}
+	for _, w := range workflows {
+		if w.Finished() {
+			continue
+		}
+
+		err := m.runWorkflow(w)
+		if err != nil {
+			log.Printf("[manager] error running workflow %s: %v\n", w.Name, err)
+		}
+	}
+}
+
+func (m *Manager) runWorkflow(w *workflow.Workflow) error {
+	order, err := w.Order()
+	if err != nil {
+		return err
+	}
+
+	status := w.Status
+	status.Steps = append([]workflow.StepStatus(nil), w.Status.Steps...)
+	states := make(map[string]workflow.StepState)
+
+	// Visiting steps in topological order lets a completed step unblock
+	// its dependents in the same pass.
+	for _, i := range order {
+		step := w.Steps[i]
+		ss := &status.Steps[i]
+
+		if ss.State == workflow.StepRunning {
+			m.updateStepState(ss)
+		}
+
+		if ss.State == workflow.StepPending {
+			ready := true
+			for _, dep := range step.DependsOn {
+				switch states[dep] {
+				case workflow.StepCompleted:
+				case workflow.StepFailed, workflow.StepSkipped:
+					ss.State = workflow.StepSkipped
+					ss.Message = fmt.Sprintf("dependency %s %s", dep, states[dep])
+					ready = false
+				default:
+					ready = false
+				}
+				if ss.State == workflow.StepSkipped {
+					break
+				}
+			}
+
+			if ready {
+				t := w.NewTask(i)
+				err := m.admit(&t)
+				if err == nil {
+					log.Printf("[manager] workflow %s: starting step %s as task %s\n", w.Name, step.Name, t.ID)
+					err = m.addTask(task.TaskEvent{
+						ID:        uuid.New(),
+						State:     task.Scheduled,
+						Timestamp: time.Now(),
+						Task:      t,
+					})
+				}
+				if err != nil {
+					ss.Message = err.Error()
+				} else {
+					ss.State = workflow.StepRunning
+					ss.TaskID = t.ID
+					ss.Message = ""
+				}
+			}
+		}
+
+		states[step.Name] = ss.State
+	}
+
+	finished, failed := true, false
+	for _, ss := range status.Steps {
+		switch ss.State {
+		case workflow.StepPending, workflow.StepRunning:
+			finished = false
+		case workflow.StepFailed, workflow.StepSkipped:
+			failed = true
+		}
+	}
+	if finished {
+		status.State = workflow.Succeeded
+		if failed {
+			status.State = workflow.Failed
+		}
+		status.CompletionTime = time.Now().UTC()
+		log.Printf("[manager] workflow %s: %s\n", w.Name, status.State)
+	}
+
+	if reflect.DeepEqual(status, w.Status) {
+		return nil
+	}
+	w.Status = status
+	return m.WorkflowDb.Put(w.Key(), w)
+}
+
+// updateStepState moves a running step to the state of its task once the
+// task has finished.
+func (m *Manager) updateStepState(ss *workflow.StepStatus) {
+	t, err := m.TaskDb.Get(ss.TaskID.String())
+	if err != nil {
+		ss.State = workflow.StepFailed
+		ss.Message = fmt.Sprintf("task %s not found", ss.TaskID)
+		return
+	}
+
+	switch {
+	case t.State == task.Completed && t.DesiredState == task.Completed:
+		ss.State = workflow.StepFailed
+		ss.Message = "task was stopped"
+	case t.State == task.Completed:
+		ss.State = workflow.StepCompleted
+		ss.Message = ""
+	case t.State == task.Failed:
+		ss.State = workflow.StepFailed
+		ss.Message = "task failed"
+	}
+}
@@ -0,0 +1,68 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/workflow"
+	"github.com/go-chi/chi/v5"
+)
+
+func (a *Api) CreateWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	d := json.NewDecoder(r.Body)
+	d.DisallowUnknownFields()
+
+	var wf workflow.Workflow
+	err := d.Decode(&wf)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Error unmarshalling body: %v\n", err))
+		return
+	}
+
+	err = setNamespace(r, &wf.Namespace)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = a.Manager.CreateWorkflow(&wf)
+	if errors.Is(err, ErrWorkflowExists) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, wf)
+}
+
+func (a *Api) GetWorkflowsHandler(w http.ResponseWriter, r *http.Request) {
+	respondJSON(w, http.StatusOK, a.Manager.GetWorkflows(namespaceParam(r)))
+}
+
+func (a *Api) GetWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	wf, err := a.Manager.WorkflowDb.Get(namespace.Key(ns, name))
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no workflow with name %v found", name))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, wf)
+}
+
+func (a *Api) DeleteWorkflowHandler(w http.ResponseWriter, r *http.Request) {
+	ns, name := namespaceParam(r), chi.URLParam(r, "name")
+	err := a.Manager.DeleteWorkflow(ns, name)
+	if err != nil {
+		respondStoreError(w, err, fmt.Sprintf("no workflow with name %v found", name))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
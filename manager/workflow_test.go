@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/dev6699/cube/workflow"
+)
+
+// finishOnWorkers makes the fake workers report the running tasks with the
+// given image as ended in state.
+func finishOnWorkers(fws []*fakeWorker, image string, state task.State) int {
+	n := 0
+	for _, fw := range fws {
+		fw.mu.Lock()
+		for _, tk := range fw.tasks {
+			if tk.Image == image && tk.State == task.Running {
+				tk.State = state
+				n++
+			}
+		}
+		fw.mu.Unlock()
+	}
+	return n
+}
+
+func TestWorkflowFailureSkipsDependents(t *testing.T) {
+	m, _, fws := newDispatchTest(t, 2)
+	w := &workflow.Workflow{
+		Name:      "etl",
+		Namespace: namespace.Default,
+		Steps: []workflow.Step{
+			{Name: "extract", Task: task.Task{Image: "extract"}},
+			{Name: "transform", DependsOn: []string{"extract"}, Task: task.Task{Image: "transform"}},
+			{Name: "validate", DependsOn: []string{"extract"}, Task: task.Task{Image: "validate"}},
+			{Name: "load", DependsOn: []string{"transform"}, Task: task.Task{Image: "load"}},
+			{Name: "report", DependsOn: []string{"load", "validate"}, Task: task.Task{Image: "report"}},
+		},
+	}
+	err := m.CreateWorkflow(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := func() *workflow.Workflow {
+		m.runWorkflows()
+		dispatchAll(t, m)
+		m.updateTasks()
+		latest, err := m.WorkflowDb.Get(w.Key())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return latest
+	}
+	stepStates := func(w *workflow.Workflow) map[string]workflow.StepState {
+		states := make(map[string]workflow.StepState)
+		for _, ss := range w.Status.Steps {
+			states[ss.Name] = ss.State
+		}
+		return states
+	}
+
+	step()
+	if n := finishOnWorkers(fws, "extract", task.Completed); n != 1 {
+		t.Fatalf("%d extract tasks ran, want 1", n)
+	}
+	m.updateTasks()
+	states := stepStates(step())
+	if states["transform"] != workflow.StepRunning || states["validate"] != workflow.StepRunning || states["load"] != workflow.StepPending {
+		t.Fatalf("got steps %v after extract completed, want transform and validate running", states)
+	}
+
+	finishOnWorkers(fws, "transform", task.Failed)
+	finishOnWorkers(fws, "validate", task.Completed)
+	m.updateTasks()
+	latest := step()
+	states = stepStates(latest)
+	want := map[string]workflow.StepState{
+		"extract":   workflow.StepCompleted,
+		"transform": workflow.StepFailed,
+		"validate":  workflow.StepCompleted,
+		"load":      workflow.StepSkipped,
+		"report":    workflow.StepSkipped,
+	}
+	for name, state := range want {
+		if states[name] != state {
+			t.Errorf("step %s is %s, want %s", name, states[name], state)
+		}
+	}
+	if latest.Status.State != workflow.Failed {
+		t.Errorf("workflow is %s, want it Failed", latest.Status.State)
+	}
+
+	for _, tk := range m.GetTasks() {
+		if tk.Image == "load" || tk.Image == "report" {
+			t.Errorf("skipped step %s got task %s", tk.Image, tk.ID)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package namespace
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/docker/go-units"
+)
+
+// Default is the namespace of resources submitted without one. It always
+// exists.
+const Default = "default"
+
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Namespace groups tasks and the resources that create them. Its quota caps
+// what the tasks in the namespace may request.
+type Namespace struct {
+	Name      string
+	Quota     Resources
+	CreatedAt time.Time
+	Status    Status
+}
+
+// Resources is an amount of tasks, CPU and memory (in bytes). In a quota a
+// zero value means unlimited.
+type Resources struct {
+	Tasks  int
+	Cpu    float64
+	Memory int64
+}
+
+// Status reports the resources requested by the active tasks of the
+// namespace.
+type Status struct {
+	Used Resources
+}
+
+func (n *Namespace) Validate() error {
+	if !validName.MatchString(n.Name) {
+		return fmt.Errorf("invalid namespace name %q", n.Name)
+	}
+	q := n.Quota
+	if q.Tasks < 0 || q.Cpu < 0 || q.Memory < 0 {
+		return fmt.Errorf("quota values must not be negative")
+	}
+	return nil
+}
+
+// Admit checks whether a task requesting req fits into the quota given the
+// resources already used. The returned error wraps ErrQuotaExceeded.
+func (n *Namespace) Admit(used Resources, req Resources) error {
+	q := n.Quota
+	var exceeded []string
+	if q.Tasks > 0 && used.Tasks+req.Tasks > q.Tasks {
+		exceeded = append(exceeded, fmt.Sprintf("tasks: used %d, limit %d", used.Tasks, q.Tasks))
+	}
+	if q.Cpu > 0 && used.Cpu+req.Cpu > q.Cpu {
+		exceeded = append(exceeded, fmt.Sprintf("cpu: used %g, requested %g, limit %g", used.Cpu, req.Cpu, q.Cpu))
+	}
+	if q.Memory > 0 && used.Memory+req.Memory > q.Memory {
+		exceeded = append(exceeded, fmt.Sprintf("memory: used %s, requested %s, limit %s",
+			units.BytesSize(float64(used.Memory)), units.BytesSize(float64(req.Memory)), units.BytesSize(float64(q.Memory))))
+	}
+
+	if len(exceeded) > 0 {
+		return fmt.Errorf("%w in namespace %s (%s)", ErrQuotaExceeded, n.Name, strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+// Key returns the store key of the named resource in namespace.
+func Key(namespace string, name string) string {
+	return namespace + "/" + name
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"time"
 
@@ -21,19 +22,93 @@ type Node struct {
 	DiskAllocated   int64
 	Stats           stats.Stats
 	Role            string
+	Labels          map[string]string
+	Annotations     map[string]string
+	LastHeartbeat   time.Time
+	Status          Status
+	LastSeen        time.Time
+	Unschedulable   bool
+	Draining        bool
+}
+
+// Status is the health of a node as observed by the manager.
+type Status string
+
+const (
+	// Ready nodes answer the manager and accept new tasks.
+	Ready Status = "Ready"
+	// NotReady nodes missed their last poll; their tasks are left alone
+	// until the grace period runs out.
+	NotReady Status = "NotReady"
+	// Unknown nodes have not been heard from yet, or for longer than the
+	// grace period, in which case their tasks have been rescheduled.
+	Unknown Status = "Unknown"
+)
+
+// Registration is what a worker announces to the manager when it joins the
+// cluster. Address is the host:port the manager reaches the worker API on,
+// Memory is in KiB and Disk in bytes, as reported by the worker's stats.
+type Registration struct {
+	Name    string
+	Address string
+	Cores   int
+	Memory  int64
+	Disk    int64
+	Labels  map[string]string
 }
 
 func New(name string, api string, role string) *Node {
 	return &Node{
-		Name: name,
-		Api:  api,
-		Role: role,
+		Name:   name,
+		Api:    api,
+		Role:   role,
+		Status: Unknown,
 	}
 }
 
-func (n *Node) GetStats() (*stats.Stats, error) {
+// Clone returns a copy of n that shares no maps with it.
+func (n *Node) Clone() *Node {
+	c := *n
+	c.Labels = maps.Clone(n.Labels)
+	c.Annotations = maps.Clone(n.Annotations)
+	return &c
+}
+
+// Patch changes the labels and annotations of a node. Keys mapped to nil
+// are removed, all others are set.
+type Patch struct {
+	Labels      map[string]*string
+	Annotations map[string]*string
+}
+
+// Apply updates the node's labels and annotations from p.
+func (n *Node) Apply(p Patch) {
+	n.Labels = applyPatch(n.Labels, p.Labels)
+	n.Annotations = applyPatch(n.Annotations, p.Annotations)
+}
+
+func applyPatch(m map[string]string, patch map[string]*string) map[string]string {
+	for k, v := range patch {
+		if v == nil {
+			delete(m, k)
+			continue
+		}
+		if m == nil {
+			m = make(map[string]string)
+		}
+		m[k] = *v
+	}
+	return m
+}
+
+// GetStats fetches the stats of the node with client, or with
+// http.DefaultClient if client is nil.
+func (n *Node) GetStats(client *http.Client) (*stats.Stats, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
 	url := fmt.Sprintf("%s/stats", n.Api)
-	resp, err := httpWithRetry(http.Get, url, 10)
+	resp, err := httpWithRetry(client.Get, url, 10)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,148 @@
+package queue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"sync"
+
+	"github.com/boltdb/bolt"
+)
+
+// BoltPriorityQueue is a PriorityQueue kept in a Bolt database, so queued
+// items survive a restart. Items are keyed by their inverted priority
+// followed by a sequence number, which makes the key order of the bucket
+// the dequeue order.
+type BoltPriorityQueue[T any] struct {
+	mu       sync.Mutex
+	Db       *bolt.DB
+	Bucket   string
+	priority func(T) int
+	length   int
+}
+
+// NewBoltPriorityQueue opens the queue stored in bucket of file, creating
+// it if needed. Items already in the bucket are handed out first, in the
+// order they were queued in.
+func NewBoltPriorityQueue[T any](file string, mode os.FileMode, bucket string, priority func(T) int) (*BoltPriorityQueue[T], error) {
+	db, err := bolt.Open(file, mode, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &BoltPriorityQueue[T]{
+		Db:       db,
+		Bucket:   bucket,
+		priority: priority,
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		q.length = b.Stats().KeyN
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return q, nil
+}
+
+func (q *BoltPriorityQueue[T]) Close() error {
+	return q.Db.Close()
+}
+
+// Enqueue adds an item behind the items of the same or higher priority
+func (q *BoltPriorityQueue[T]) Enqueue(value T) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err = q.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(q.Bucket))
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		return b.Put(itemKey(q.priority(value), seq), buf)
+	})
+	if err != nil {
+		return err
+	}
+
+	q.length++
+	return nil
+}
+
+// Dequeue takes the item with the highest priority off the queue. An item
+// that cannot be decoded is removed all the same, so that it does not
+// block the queue.
+func (q *BoltPriorityQueue[T]) Dequeue() (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var t T
+	var buf []byte
+	err := q.Db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(q.Bucket)).Cursor()
+		k, v := c.First()
+		if k == nil {
+			return ErrEmpty
+		}
+
+		// v is only valid during the transaction.
+		buf = append(buf, v...)
+		return c.Delete()
+	})
+	if err != nil {
+		return t, err
+	}
+
+	q.length--
+	err = json.Unmarshal(buf, &t)
+	return t, err
+}
+
+// Len returns the number of items in the queue
+func (q *BoltPriorityQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.length
+}
+
+// List returns the queued items in the order they would be dequeued
+func (q *BoltPriorityQueue[T]) List() ([]T, error) {
+	values := []T{}
+	err := q.Db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(q.Bucket))
+		return b.ForEach(func(k, v []byte) error {
+			var t T
+			err := json.Unmarshal(v, &t)
+			if err != nil {
+				return err
+			}
+			values = append(values, t)
+			return nil
+		})
+	})
+	return values, err
+}
+
+// itemKey orders items by descending priority, then by ascending seq.
+// Flipping the sign bit of the priority makes it sort as an unsigned
+// number; inverting it puts the highest priority first.
+func itemKey(priority int, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, ^(uint64(priority) ^ 1<<63))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"container/heap"
+	"errors"
+	"slices"
+	"sync"
+)
+
+// PriorityQueue hands out items with the highest priority first. Items of
+// equal priority come out in the order they were enqueued. Implementations
+// are safe for concurrent use.
+type PriorityQueue[T any] interface {
+	Enqueue(value T) error
+	Dequeue() (T, error)
+	Len() int
+	List() ([]T, error)
+}
+
+var (
+	ErrEmpty = errors.New("queue is empty")
+)
+
+// InMemoryPriorityQueue is a PriorityQueue whose items are lost when the
+// process exits.
+type InMemoryPriorityQueue[T any] struct {
+	mu       sync.Mutex
+	items    items[T]
+	seq      uint64
+	priority func(T) int
+}
+
+type item[T any] struct {
+	value    T
+	priority int
+	seq      uint64
+}
+
+// NewInMemoryPriorityQueue creates a queue that orders items by the given
+// priority function.
+func NewInMemoryPriorityQueue[T any](priority func(T) int) *InMemoryPriorityQueue[T] {
+	return &InMemoryPriorityQueue[T]{
+		priority: priority,
+	}
+}
+
+// Enqueue adds an item behind the items of the same or higher priority
+func (q *InMemoryPriorityQueue[T]) Enqueue(value T) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.seq++
+	heap.Push(&q.items, item[T]{
+		value:    value,
+		priority: q.priority(value),
+		seq:      q.seq,
+	})
+	return nil
+}
+
+// Dequeue takes the item with the highest priority off the queue
+func (q *InMemoryPriorityQueue[T]) Dequeue() (T, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var t T
+	if len(q.items) == 0 {
+		return t, ErrEmpty
+	}
+	return heap.Pop(&q.items).(item[T]).value, nil
+}
+
+// Len returns the number of items in the queue
+func (q *InMemoryPriorityQueue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.items)
+}
+
+// List returns the queued items in the order they would be dequeued
+func (q *InMemoryPriorityQueue[T]) List() ([]T, error) {
+	q.mu.Lock()
+	sorted := slices.Clone(q.items)
+	q.mu.Unlock()
+
+	slices.SortFunc(sorted, func(a, b item[T]) int {
+		if before(a, b) {
+			return -1
+		}
+		return 1
+	})
+	values := []T{}
+	for _, it := range sorted {
+		values = append(values, it.value)
+	}
+	return values, nil
+}
+
+// items implements heap.Interface.
+type items[T any] []item[T]
+
+func (h items[T]) Len() int { return len(h) }
+
+func (h items[T]) Less(i, j int) bool { return before(h[i], h[j]) }
+
+// before reports whether a is dequeued before b.
+func before[T any](a, b item[T]) bool {
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (h items[T]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *items[T]) Push(x any) { *h = append(*h, x.(item[T])) }
+
+func (h *items[T]) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	*h = old[:n-1]
+	return it
+}
@@ -0,0 +1,53 @@
+package queue
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestPriorityQueueOrder(t *testing.T) {
+	type job struct {
+		name     string
+		priority int
+	}
+	q := NewInMemoryPriorityQueue(func(j job) int { return j.priority })
+	for _, j := range []job{{"a", 0}, {"b", 10}, {"c", 0}, {"d", 10}, {"e", 5}} {
+		q.Enqueue(j)
+	}
+
+	var got string
+	for q.Len() > 0 {
+		j, _ := q.Dequeue()
+		got += j.name
+	}
+	if want := "bdeac"; got != want {
+		t.Errorf("got order %q, want %q", got, want)
+	}
+}
+
+func TestPriorityQueueConcurrentAccess(t *testing.T) {
+	q := NewInMemoryPriorityQueue(func(i int) int { return i % 3 })
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	dequeued := 0
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				q.Enqueue(w*100 + i)
+				if _, err := q.Dequeue(); err == nil {
+					mu.Lock()
+					dequeued++
+					mu.Unlock()
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if dequeued+q.Len() != 800 {
+		t.Errorf("got %d dequeued and %d queued items, want 800 in total", dequeued, q.Len())
+	}
+}
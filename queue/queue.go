@@ -1,6 +1,10 @@
 package queue
 
+import "sync"
+
+// Queue is a FIFO queue that is safe for concurrent use.
 type Queue[T any] struct {
+	mu     sync.Mutex
 	start  *node[T]
 	end    *node[T]
 	length int
@@ -22,6 +26,9 @@ func New[T any]() *Queue[T] {
 
 // Dequeue takes the next item off the front of the queue
 func (q *Queue[T]) Dequeue() (T, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	var t T
 	if q.length == 0 {
 		return t, false
@@ -41,6 +48,9 @@ func (q *Queue[T]) Dequeue() (T, bool) {
 
 // Enqueue puts an item on the end of a queue
 func (q *Queue[T]) Enqueue(value T) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	n := &node[T]{
 		value: value,
 		next:  nil,
@@ -59,11 +69,17 @@ func (q *Queue[T]) Enqueue(value T) {
 
 // Len returns the number of items in the queue
 func (q *Queue[T]) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	return q.length
 }
 
 // Peek returns the first item in the queue without removing it
 func (q *Queue[T]) Peek() interface{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
 	if q.length == 0 {
 		return nil
 	}
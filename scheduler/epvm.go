@@ -3,6 +3,7 @@ package scheduler
 import (
 	"log"
 	"math"
+	"net/http"
 	"time"
 
 	"github.com/dev6699/cube/node"
@@ -18,6 +19,9 @@ const (
 type Epvm struct {
 	Name       string
 	LastWorker int
+	// Client fetches the stats of the nodes. It defaults to
+	// http.DefaultClient.
+	Client *http.Client
 }
 
 func (e *Epvm) SelectCandidateNodes(t task.Task, nodes []*node.Node) []*node.Node {
@@ -39,7 +43,7 @@ func (e *Epvm) Score(t task.Task, nodes []*node.Node) map[string]float64 {
 	maxJobs := 4.0
 
 	for _, node := range nodes {
-		cpuUsage, err := calculateCpuUsage(node)
+		cpuUsage, err := calculateCpuUsage(e.Client, node)
 		if err != nil {
 			log.Printf("error calculating CPU usage for node %s, skipping: %v", node.Name, err)
 			continue
@@ -65,15 +69,15 @@ func calculateLoad(usage float64, capacity float64) float64 {
 }
 
 // https://stackoverflow.com/questions/23367857/accurate-calculation-of-cpu-usage-given-in-percentage-in-linux/23376195#23376195
-func calculateCpuUsage(node *node.Node) (*float64, error) {
-	stat1, err := node.GetStats()
+func calculateCpuUsage(client *http.Client, node *node.Node) (*float64, error) {
+	stat1, err := node.GetStats(client)
 	if err != nil {
 		return nil, err
 	}
 
 	time.Sleep(3 * time.Second)
 
-	stat2, err := node.GetStats()
+	stat2, err := node.GetStats(client)
 	if err != nil {
 		return nil, err
 	}
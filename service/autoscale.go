@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/dev6699/cube/task"
+)
+
+const (
+	MetricCpu    = "cpu"
+	MetricMemory = "memory"
+
+	defaultScaleDownStabilizationSeconds = 300
+)
+
+// Autoscale adjusts a service's replicas between MinReplicas and
+// MaxReplicas so that the average utilization of its tasks stays close to
+// TargetUtilization, a percentage of each task's Cpu or Memory. Scaling
+// recommendations are smoothed over the stabilization windows: scaling up
+// uses the lowest and scaling down the highest recommendation in the window.
+// A nil ScaleDownStabilizationSeconds defaults to 300; 0 scales down at once.
+type Autoscale struct {
+	MinReplicas                   int
+	MaxReplicas                   int
+	Metric                        string
+	TargetUtilization             int
+	ScaleUpStabilizationSeconds   int
+	ScaleDownStabilizationSeconds *int
+}
+
+func (a *Autoscale) SetDefaults() {
+	if a.Metric == "" {
+		a.Metric = MetricCpu
+	}
+	if a.ScaleDownStabilizationSeconds == nil {
+		seconds := defaultScaleDownStabilizationSeconds
+		a.ScaleDownStabilizationSeconds = &seconds
+	}
+}
+
+func (a *Autoscale) Validate() error {
+	if a.MinReplicas < 1 {
+		return fmt.Errorf("autoscale min replicas must be at least 1")
+	}
+	if a.MaxReplicas < a.MinReplicas {
+		return fmt.Errorf("autoscale max replicas must not be less than min replicas")
+	}
+	if a.TargetUtilization <= 0 {
+		return fmt.Errorf("autoscale target utilization must be positive")
+	}
+	if a.ScaleUpStabilizationSeconds < 0 || (a.ScaleDownStabilizationSeconds != nil && *a.ScaleDownStabilizationSeconds < 0) {
+		return fmt.Errorf("autoscale stabilization windows must not be negative")
+	}
+	switch a.Metric {
+	case MetricCpu, MetricMemory:
+	default:
+		return fmt.Errorf("invalid autoscale metric %q", a.Metric)
+	}
+	return nil
+}
+
+// Utilization returns u as a percentage of the resources of t. Tasks without
+// a Cpu request are measured against a single core and tasks without a
+// Memory request against the container's memory limit.
+func (a *Autoscale) Utilization(t *task.Task, u task.Usage) float64 {
+	if a.Metric == MetricMemory {
+		limit := float64(t.Memory)
+		if limit <= 0 {
+			limit = float64(u.MemoryLimit)
+		}
+		if limit <= 0 {
+			return 0
+		}
+		return float64(u.MemoryUsage) / limit * 100
+	}
+
+	cpu := t.Cpu
+	if cpu <= 0 {
+		cpu = 1
+	}
+	return u.Cpu / cpu * 100
+}
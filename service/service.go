@@ -0,0 +1,266 @@
+package service
+
+import (
+	"fmt"
+	"maps"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// Kind is the owner kind recorded on tasks created for a service.
+const Kind = "Service"
+
+const (
+	FailureActionPause    = "pause"
+	FailureActionRollback = "rollback"
+
+	defaultHealthTimeoutSeconds = 60
+	defaultRevisionHistoryLimit = 10
+)
+
+type UpdateState string
+
+const (
+	UpdateUpdating    UpdateState = "updating"
+	UpdatePaused      UpdateState = "paused"
+	UpdateRollingBack UpdateState = "rolling_back"
+	UpdateCompleted   UpdateState = "completed"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Service keeps Replicas copies of Template running. Every change to
+// Template creates a new revision which replaces the tasks of the previous
+// one according to UpdateConfig. UID is assigned when the service is
+// created and recorded on its tasks.
+type Service struct {
+	Name                 string
+	Namespace            string
+	UID                  uuid.UUID
+	Replicas             int
+	Template             task.Task
+	UpdateConfig         UpdateConfig
+	Autoscale            *Autoscale
+	DisruptionBudget     *DisruptionBudget
+	Revision             int
+	RevisionHistoryLimit int
+	History              []Revision
+	CreatedAt            time.Time
+	Status               Status
+}
+
+// UpdateConfig controls how tasks are replaced during a rolling update.
+// MaxUnavailable is how many replicas may be below the desired count and
+// MaxSurge how many may be above it. A new task that is not healthy within
+// HealthTimeoutSeconds of starting fails the update, which then pauses or
+// rolls back depending on FailureAction.
+type UpdateConfig struct {
+	MaxUnavailable       int
+	MaxSurge             int
+	HealthTimeoutSeconds int
+	FailureAction        string
+}
+
+// DisruptionBudget limits how many replicas voluntary disruptions, such as
+// draining a node, may take down at the same time. A service without one
+// allows a single replica to be unavailable.
+type DisruptionBudget struct {
+	MaxUnavailable int
+}
+
+type Revision struct {
+	Number    int
+	Template  task.Task
+	CreatedAt time.Time
+}
+
+type Status struct {
+	RunningReplicas int
+	PendingReplicas int
+	UpdatedReplicas int
+	UpdateState     UpdateState
+	Message         string
+	Utilization     int
+	LastScaleTime   time.Time
+}
+
+// ScaleRequest is the body of a request changing a service's replicas.
+type ScaleRequest struct {
+	Replicas int
+}
+
+// RollbackRequest is the body of a rollback request. A zero Revision rolls
+// back to the revision before the current one.
+type RollbackRequest struct {
+	Revision int
+}
+
+func (s *Service) Validate() error {
+	if !validName.MatchString(s.Name) {
+		return fmt.Errorf("invalid service name %q", s.Name)
+	}
+	if s.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	if s.Template.Image == "" {
+		return fmt.Errorf("template image is required")
+	}
+	err := labels.Validate(s.Template.Labels)
+	if err != nil {
+		return err
+	}
+
+	u := s.UpdateConfig
+	if u.MaxUnavailable < 0 || u.MaxSurge < 0 || u.HealthTimeoutSeconds < 0 {
+		return fmt.Errorf("update config values must not be negative")
+	}
+	switch u.FailureAction {
+	case "", FailureActionPause, FailureActionRollback:
+	default:
+		return fmt.Errorf("invalid failure action %q", u.FailureAction)
+	}
+
+	if s.DisruptionBudget != nil && s.DisruptionBudget.MaxUnavailable < 0 {
+		return fmt.Errorf("disruption budget must not be negative")
+	}
+
+	if s.Autoscale != nil {
+		return s.Autoscale.Validate()
+	}
+	return nil
+}
+
+// AllowedDisruptions returns how many more replicas may be taken down
+// voluntarily while running of them are running.
+func (s *Service) AllowedDisruptions(running int) int {
+	maxUnavailable := 1
+	if s.DisruptionBudget != nil {
+		maxUnavailable = s.DisruptionBudget.MaxUnavailable
+	}
+	return max(maxUnavailable-max(s.Replicas-running, 0), 0)
+}
+
+// SetDefaults fills in the zero values of the update configuration.
+func (s *Service) SetDefaults() {
+	if s.UpdateConfig.MaxUnavailable == 0 && s.UpdateConfig.MaxSurge == 0 {
+		s.UpdateConfig.MaxSurge = 1
+	}
+	if s.UpdateConfig.HealthTimeoutSeconds == 0 {
+		s.UpdateConfig.HealthTimeoutSeconds = defaultHealthTimeoutSeconds
+	}
+	if s.UpdateConfig.FailureAction == "" {
+		s.UpdateConfig.FailureAction = FailureActionPause
+	}
+	if s.RevisionHistoryLimit <= 0 {
+		s.RevisionHistoryLimit = defaultRevisionHistoryLimit
+	}
+	if s.Autoscale != nil {
+		s.Autoscale.SetDefaults()
+	}
+}
+
+// SetTemplate records t as a new revision if it differs from the current
+// template and reports whether it did.
+func (s *Service) SetTemplate(t task.Task) bool {
+	if s.Revision > 0 && reflect.DeepEqual(s.Template, t) {
+		return false
+	}
+
+	s.Revision = s.latestRevision() + 1
+	s.Template = t
+	s.History = append(s.History, Revision{
+		Number:    s.Revision,
+		Template:  t,
+		CreatedAt: time.Now().UTC(),
+	})
+	if len(s.History) > s.RevisionHistoryLimit {
+		s.History = s.History[len(s.History)-s.RevisionHistoryLimit:]
+	}
+	return true
+}
+
+// Rollback makes the template of revision n current again as a new
+// revision. A zero n selects the revision preceding the current one.
+func (s *Service) Rollback(n int) error {
+	if n == 0 {
+		for _, r := range s.History {
+			if r.Number < s.Revision && r.Number > n {
+				n = r.Number
+			}
+		}
+		if n == 0 {
+			return fmt.Errorf("service %s has no previous revision", s.Name)
+		}
+	}
+
+	for _, r := range s.History {
+		if r.Number == n {
+			if r.Number == s.Revision {
+				return fmt.Errorf("revision %d is already current", n)
+			}
+			s.SetTemplate(r.Template)
+			return nil
+		}
+	}
+	return fmt.Errorf("revision %d not found in history", n)
+}
+
+func (s *Service) latestRevision() int {
+	latest := s.Revision
+	for _, r := range s.History {
+		latest = max(latest, r.Number)
+	}
+	return latest
+}
+
+// Revisions returns the revision history ordered from oldest to newest.
+func (s *Service) Revisions() []Revision {
+	revisions := append([]Revision(nil), s.History...)
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Number < revisions[j].Number
+	})
+	return revisions
+}
+
+// NewTask returns a fresh task built from the current template.
+func (s *Service) NewTask() task.Task {
+	t := s.Template
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s", s.Name, t.ID.String()[:8])
+	t.State = task.Pending
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.StartTime = time.Time{}
+	t.FinishTime = time.Time{}
+	t.RestartCount = 0
+	t.Labels = maps.Clone(s.Template.Labels)
+	t.Annotations = maps.Clone(s.Template.Annotations)
+	t.Namespace = s.Namespace
+	t.DesiredState = task.Running
+	t.Owner = &task.Owner{
+		Kind:     Kind,
+		Name:     s.Name,
+		UID:      s.UID,
+		Revision: s.Revision,
+	}
+	return t
+}
+
+// Owns reports whether t was created for the service, and not for an
+// earlier service of the same name.
+func (s *Service) Owns(t *task.Task) bool {
+	return t.Owner != nil && t.Owner.Kind == Kind && t.Owner.Name == s.Name &&
+		t.Owner.UID == s.UID && t.Namespace == s.Namespace
+}
+
+// Key returns the store key of the service, which is unique across namespaces.
+func (s *Service) Key() string {
+	return namespace.Key(s.Namespace, s.Name)
+}
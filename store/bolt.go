@@ -72,6 +72,17 @@ func (s *BoltStore[T]) Get(key string) (T, error) {
 	return t, err
 }
 
+func (s *BoltStore[T]) Delete(key string) error {
+	return s.Db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		if b.Get([]byte(key)) == nil {
+			return ErrNotFound
+		}
+
+		return b.Delete([]byte(key))
+	})
+}
+
 func (s *BoltStore[T]) List() ([]T, error) {
 	values := []T{}
 	err := s.Db.View(func(tx *bolt.Tx) error {
@@ -89,6 +100,18 @@ func (s *BoltStore[T]) List() ([]T, error) {
 	return values, err
 }
 
+func (s *BoltStore[T]) Keys() ([]string, error) {
+	keys := []string{}
+	err := s.Db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(s.Bucket))
+		return b.ForEach(func(k, v []byte) error {
+			keys = append(keys, string(k))
+			return nil
+		})
+	})
+	return keys, err
+}
+
 func (s *BoltStore[T]) Count() (int, error) {
 	taskCount := 0
 	err := s.Db.View(func(tx *bolt.Tx) error {
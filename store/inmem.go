@@ -1,38 +1,91 @@
 package store
 
+import (
+	"encoding/json"
+	"sync"
+)
+
+// InMemoryStore keeps values JSON encoded, like BoltStore, so callers get
+// their own copy from Get and List and may use the store from several
+// goroutines.
 type InMemoryStore[T any] struct {
-	Db map[string]T
+	mu sync.RWMutex
+	Db map[string][]byte
 }
 
 func NewInMemoryStore[T any]() *InMemoryStore[T] {
 	return &InMemoryStore[T]{
-		Db: make(map[string]T),
+		Db: make(map[string][]byte),
 	}
 }
 
 func (i *InMemoryStore[T]) Put(key string, value T) error {
-	i.Db[key] = value
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.Db[key] = buf
 	return nil
 }
 
 func (i *InMemoryStore[T]) Get(key string) (T, error) {
+	i.mu.RLock()
+	buf, ok := i.Db[key]
+	i.mu.RUnlock()
+
 	var v T
-	var ok bool
-	v, ok = i.Db[key]
 	if !ok {
 		return v, ErrNotFound
 	}
-	return v, nil
+	err := json.Unmarshal(buf, &v)
+	return v, err
+}
+
+func (i *InMemoryStore[T]) Delete(key string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	_, ok := i.Db[key]
+	if !ok {
+		return ErrNotFound
+	}
+	delete(i.Db, key)
+	return nil
 }
 
 func (i *InMemoryStore[T]) List() ([]T, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	values := []T{}
-	for _, v := range i.Db {
+	for _, buf := range i.Db {
+		var v T
+		err := json.Unmarshal(buf, &v)
+		if err != nil {
+			return nil, err
+		}
 		values = append(values, v)
 	}
 	return values, nil
 }
 
+func (i *InMemoryStore[T]) Keys() ([]string, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	keys := []string{}
+	for k := range i.Db {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
 func (i *InMemoryStore[T]) Count() (int, error) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
 	return len(i.Db), nil
 }
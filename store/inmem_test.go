@@ -0,0 +1,90 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type item struct {
+	Name string
+	Tags map[string]string
+}
+
+func TestInMemoryStoreReturnsCopies(t *testing.T) {
+	s := NewInMemoryStore[*item]()
+	v := &item{Name: "a", Tags: map[string]string{"k": "v"}}
+	err := s.Put("a", v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v.Name = "changed"
+	got, err := s.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "a" {
+		t.Errorf("got name %q after changing the stored value, want %q", got.Name, "a")
+	}
+
+	got.Tags["k"] = "changed"
+	again, err := s.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Tags["k"] != "v" {
+		t.Errorf("got tag %q after changing a returned value, want %q", again.Tags["k"], "v")
+	}
+}
+
+func TestInMemoryStoreConcurrentAccess(t *testing.T) {
+	s := NewInMemoryStore[*item]()
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				key := fmt.Sprintf("%d-%d", w, i)
+				err := s.Put(key, &item{Name: key})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, err = s.Get(key)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				_, err = s.List()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if i%2 == 0 {
+					err = s.Delete(key)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	n, err := s.Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 400 {
+		t.Errorf("got %d values, want 400", n)
+	}
+	_, err = s.Get("0-0")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("got error %v for a deleted key, want %v", err, ErrNotFound)
+	}
+}
@@ -5,7 +5,9 @@ import "errors"
 type Store[T any] interface {
 	Put(key string, value T) error
 	Get(key string) (T, error)
+	Delete(key string) error
 	List() ([]T, error)
+	Keys() ([]string, error)
 	Count() (int, error)
 }
 
@@ -2,15 +2,19 @@ package task
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"math"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
 )
 
 type DockerResult struct {
@@ -38,8 +42,11 @@ func NewConfig(t *Task) *Config {
 	return &Config{
 		Name:          t.Name,
 		Image:         t.Image,
+		Cmd:           t.Cmd,
 		RestartPolicy: t.RestartPolicy,
 		Env:           t.Env,
+		Cpu:           t.Cpu,
+		Memory:        t.Memory,
 	}
 }
 
@@ -77,6 +84,7 @@ func (d *Docker) Run(ctx context.Context) (*DockerResult, error) {
 	}
 	cc := container.Config{
 		Image:        d.Config.Image,
+		Cmd:          d.Config.Cmd,
 		Tty:          false,
 		Env:          d.Config.Env,
 		ExposedPorts: d.Config.ExposedPorts,
@@ -132,6 +140,73 @@ func (d *Docker) Stop(ctx context.Context, id string) (*DockerResult, error) {
 	}, nil
 }
 
+// Usage is a single sample of a task's resource consumption. Cpu is the
+// number of cores in use.
+type Usage struct {
+	TaskID      uuid.UUID
+	Timestamp   time.Time
+	Cpu         float64
+	MemoryUsage uint64
+	MemoryLimit uint64
+}
+
+func (d *Docker) Stats(ctx context.Context, containerID string) (*Usage, error) {
+	resp, err := d.Client.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var s types.StatsJSON
+	err = json.NewDecoder(resp.Body).Decode(&s)
+	if err != nil {
+		return nil, err
+	}
+
+	cpus := float64(s.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(s.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	var cpu float64
+	cpuDelta := float64(s.CPUStats.CPUUsage.TotalUsage) - float64(s.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(s.CPUStats.SystemUsage) - float64(s.PreCPUStats.SystemUsage)
+	if cpuDelta > 0 && systemDelta > 0 {
+		cpu = cpuDelta / systemDelta * cpus
+	}
+
+	return &Usage{
+		Timestamp:   s.Read,
+		Cpu:         cpu,
+		MemoryUsage: s.MemoryStats.Usage,
+		MemoryLimit: s.MemoryStats.Limit,
+	}, nil
+}
+
+// Logs writes the output of the container to w, both stdout and stderr.
+// With tail greater than zero only the last tail lines are written. With
+// follow set, Logs keeps writing new output until the container stops or
+// ctx is done.
+func (d *Docker) Logs(ctx context.Context, containerID string, follow bool, tail int, w io.Writer) error {
+	opts := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+	}
+	if tail > 0 {
+		opts.Tail = strconv.Itoa(tail)
+	}
+
+	out, err := d.Client.ContainerLogs(ctx, containerID, opts)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = stdcopy.StdCopy(w, w, out)
+	return err
+}
+
 func (d *Docker) Inspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
 	return d.Client.ContainerInspect(ctx, containerID)
 }
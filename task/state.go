@@ -1,5 +1,10 @@
 package task
 
+import (
+	"fmt"
+	"strings"
+)
+
 type State int
 
 const (
@@ -8,6 +13,10 @@ const (
 	Running
 	Completed
 	Failed
+	// Preempted tasks were stopped by the manager to be placed again,
+	// possibly on the same worker. Unlike Completed tasks they may be
+	// scheduled again.
+	Preempted
 )
 
 func (s State) String() string {
@@ -22,17 +31,30 @@ func (s State) String() string {
 		return "Completed"
 	case Failed:
 		return "Failed"
+	case Preempted:
+		return "Preempted"
 	default:
 		return "Unknown"
 	}
 }
 
+// ParseState returns the state named s, ignoring case.
+func ParseState(s string) (State, error) {
+	for st := Pending; st <= Preempted; st++ {
+		if strings.EqualFold(st.String(), s) {
+			return st, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown task state %q", s)
+}
+
 var stateTransitionMap = map[State][]State{
 	Pending:   {Scheduled},
 	Scheduled: {Scheduled, Running, Failed},
-	Running:   {Scheduled, Running, Completed, Failed},
+	Running:   {Scheduled, Running, Completed, Failed, Preempted},
 	Completed: {},
 	Failed:    {Scheduled},
+	Preempted: {Scheduled},
 }
 
 func contains(states []State, state State) bool {
@@ -0,0 +1,26 @@
+package task
+
+import "testing"
+
+func TestStateTransitions(t *testing.T) {
+	tests := []struct {
+		src   State
+		dst   State
+		valid bool
+	}{
+		{Pending, Scheduled, true},
+		{Scheduled, Running, true},
+		{Running, Completed, true},
+		{Running, Preempted, true},
+		{Preempted, Scheduled, true},
+		{Failed, Scheduled, true},
+		{Completed, Scheduled, false},
+		{Completed, Running, false},
+		{Pending, Preempted, false},
+	}
+	for _, tt := range tests {
+		if got := ValidStateTransiton(tt.src, tt.dst); got != tt.valid {
+			t.Errorf("%s to %s: got valid %v, want %v", tt.src, tt.dst, got, tt.valid)
+		}
+	}
+}
@@ -12,8 +12,11 @@ type Task struct {
 	ContainerID   string
 	Env           []string
 	Name          string
+	Namespace     string
 	State         State
 	Image         string
+	Cmd           []string
+	Cpu           float64
 	Memory        int64
 	Disk          int64
 	ExposedPorts  nat.PortSet
@@ -24,6 +27,35 @@ type Task struct {
 	FinishTime    time.Time
 	HealthCheck   string
 	RestartCount  int
+	// Priority orders pending tasks, higher first. A task that fits on no
+	// node may preempt running tasks of lower priority unless they are
+	// NonPreemptible.
+	Priority       int
+	NonPreemptible bool
+	// Labels identify the task for selectors, Annotations carry free-form
+	// metadata that is never used for selection.
+	Labels      map[string]string
+	Annotations map[string]string
+	// DesiredState is the state the manager is driving the task towards;
+	// it becomes Completed once a stop has been requested.
+	DesiredState State
+	Owner        *Owner
+	// Worker is the name of the node the task was placed on. It is empty
+	// while the task waits to be scheduled.
+	Worker string
+}
+
+// Owner identifies the resource that created a task, such as a service.
+// UID tells apart resources that were deleted and created again under the
+// same name. Revision is the owner's template revision the task was
+// created from and Index the task's position within its owner, such as a
+// job's completion index.
+type Owner struct {
+	Kind     string
+	Name     string
+	UID      uuid.UUID
+	Revision int
+	Index    int
 }
 
 type TaskEvent struct {
@@ -31,4 +63,29 @@ type TaskEvent struct {
 	State     State
 	Timestamp time.Time
 	Task      Task
+	// Attempts counts how often the task could not be delivered to a
+	// worker, and FailedWorkers lists those workers so that the next
+	// attempt is placed elsewhere.
+	Attempts      int      `json:",omitempty"`
+	FailedWorkers []string `json:",omitempty"`
+}
+
+// DeadLetter is a task the manager gave up delivering to workers.
+type DeadLetter struct {
+	Task          Task
+	Attempts      int
+	FailedWorkers []string
+	Error         string
+	Timestamp     time.Time
+}
+
+// Submission records a task submitted with an idempotency key, so that a
+// repeated request returns the task it created instead of adding another.
+// Digest identifies the request, to detect a key reused for another one.
+type Submission struct {
+	Key       string
+	Namespace string
+	Digest    string
+	TaskID    uuid.UUID
+	Timestamp time.Time
 }
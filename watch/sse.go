@@ -0,0 +1,66 @@
+package watch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteEvent writes e to w as a Server-Sent Event.
+func WriteEvent(w io.Writer, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ResourceVersion, e.Kind, data)
+	return err
+}
+
+// Decoder reads events from a Server-Sent Events stream.
+type Decoder struct {
+	s *bufio.Scanner
+}
+
+func NewDecoder(r io.Reader) *Decoder {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	return &Decoder{s: s}
+}
+
+// Decode returns the next event in the stream. Comments and fields other
+// than data are ignored.
+func (d *Decoder) Decode() (*Event, error) {
+	var data bytes.Buffer
+	for d.s.Scan() {
+		line := d.s.Text()
+		if line == "" {
+			if data.Len() == 0 {
+				continue
+			}
+
+			var e Event
+			err := json.Unmarshal(data.Bytes(), &e)
+			if err != nil {
+				return nil, err
+			}
+			return &e, nil
+		}
+
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(v, " "))
+		}
+	}
+
+	err := d.s.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	return nil, err
+}
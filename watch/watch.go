@@ -0,0 +1,151 @@
+package watch
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/task"
+)
+
+type EventType string
+
+const (
+	Added    EventType = "ADDED"
+	Modified EventType = "MODIFIED"
+	Deleted  EventType = "DELETED"
+)
+
+type Kind string
+
+const (
+	KindTask Kind = "Task"
+	KindNode Kind = "Node"
+)
+
+// Event describes a change to a single object. ResourceVersion increases
+// monotonically and can be passed back to resume a watch.
+type Event struct {
+	ResourceVersion uint64
+	Type            EventType
+	Kind            Kind
+	Task            *task.Task `json:",omitempty"`
+	Node            *node.Node `json:",omitempty"`
+}
+
+var (
+	ErrExpired = errors.New("resource version is too old")
+)
+
+// subscriberBuffer is the number of events a subscriber may fall behind
+// before it is dropped.
+const subscriberBuffer = 256
+
+// Hub assigns resource versions to events, keeps a bounded history so that
+// watchers can resume, and fans events out to subscribers.
+type Hub struct {
+	mu          sync.Mutex
+	version     uint64
+	history     []Event
+	capacity    int
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	C    chan Event
+	hub  *Hub
+	once sync.Once
+}
+
+// NewHub returns a hub keeping the latest capacity events. Its resource
+// versions start at the current time in microseconds, so that the versions
+// of a restarted manager, or of a replica that took over as leader, are
+// larger than any the previous one handed out, and versions from before
+// are recognized as expired.
+func NewHub(capacity int) *Hub {
+	return &Hub{
+		version:     uint64(time.Now().UnixMicro()),
+		capacity:    capacity,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Version returns the resource version of the latest published event.
+func (h *Hub) Version() uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.version
+}
+
+// Publish stamps e with the next resource version and delivers it to every
+// subscriber. Subscribers that cannot keep up are closed so that they can
+// resume from their last seen version.
+func (h *Hub) Publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.version++
+	e.ResourceVersion = h.version
+	h.history = append(h.history, e)
+	if len(h.history) > h.capacity {
+		h.history = h.history[len(h.history)-h.capacity:]
+	}
+
+	for s := range h.subscribers {
+		select {
+		case s.C <- e:
+		default:
+			h.remove(s)
+		}
+	}
+}
+
+// Subscribe returns a subscription receiving every event published after
+// the given resource version. It returns ErrExpired if the events since
+// then are no longer kept, or if the version was not handed out by this
+// hub.
+func (h *Hub) Subscribe(since uint64) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if since > h.version {
+		return nil, ErrExpired
+	}
+
+	var backlog []Event
+	if since < h.version {
+		if len(h.history) == 0 || h.history[0].ResourceVersion > since+1 {
+			return nil, ErrExpired
+		}
+		for _, e := range h.history {
+			if e.ResourceVersion > since {
+				backlog = append(backlog, e)
+			}
+		}
+	}
+
+	s := &Subscription{
+		C:   make(chan Event, len(backlog)+subscriberBuffer),
+		hub: h,
+	}
+	for _, e := range backlog {
+		s.C <- e
+	}
+	h.subscribers[s] = struct{}{}
+	return s, nil
+}
+
+// Close stops delivery to the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+func (h *Hub) remove(s *Subscription) {
+	s.once.Do(func() {
+		delete(h.subscribers, s)
+		close(s.C)
+	})
+}
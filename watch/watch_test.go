@@ -0,0 +1,48 @@
+package watch
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscribe(t *testing.T) {
+	old := NewHub(10)
+	old.Publish(Event{Type: Added, Kind: KindTask})
+	stale := old.Version()
+
+	// A hub started later starts at a later version.
+	time.Sleep(time.Millisecond)
+	h := NewHub(10)
+	start := h.Version()
+	for i := 0; i < 3; i++ {
+		h.Publish(Event{Type: Modified, Kind: KindTask})
+	}
+
+	tests := []struct {
+		name    string
+		since   uint64
+		backlog int
+		err     error
+	}{
+		{"current", h.Version(), 0, nil},
+		{"resumed", start + 1, 2, nil},
+		{"from the start", start, 3, nil},
+		{"from an earlier hub", stale, 0, ErrExpired},
+		{"from the future", h.Version() + 1, 0, ErrExpired},
+	}
+	for _, tt := range tests {
+		s, err := h.Subscribe(tt.since)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: got error %v, want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if len(s.C) != tt.backlog {
+			t.Errorf("%s: got %d events, want %d", tt.name, len(s.C), tt.backlog)
+		}
+		s.Close()
+	}
+}
@@ -1,9 +1,13 @@
 package worker
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/auth"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -12,6 +16,9 @@ type Api struct {
 	Port    int
 	Worker  *Worker
 	Router  *chi.Mux
+	// Token is the cluster token the manager presents. When it is set,
+	// requests without it are rejected.
+	Token string
 }
 
 func NewApi(address string, port int, worker *Worker) *Api {
@@ -30,12 +37,34 @@ func (a *Api) Start() error {
 
 func (a *Api) initRouter() {
 	a.Router = chi.NewRouter()
+	if a.Token != "" {
+		a.Router.Use(a.authenticate)
+	}
 	a.Router.Route("/tasks", func(r chi.Router) {
 		r.Post("/", a.StartTaskHandler)
 		r.Get("/", a.GetTasksHandler)
+		r.Get("/stats", a.GetTaskUsageHandler)
 		r.Delete("/{taskID}", a.StopTaskHandler)
 	})
 	a.Router.Route("/stats", func(r chi.Router) {
 		r.Get("/", a.GetStatsHandler)
 	})
+	a.Router.Route("/v1", a.v1Routes)
+}
+
+// authenticate rejects requests that do not carry the cluster token.
+func (a *Api) authenticate(next http.Handler) http.Handler {
+	allow := func(r *http.Request, token string) bool {
+		return auth.Equal(token, a.Token)
+	}
+	deny := func(w http.ResponseWriter, r *http.Request) {
+		msg := "the cluster token is required"
+		if strings.HasPrefix(r.URL.Path, "/v1/") {
+			v1.RespondError(w, v1.NewError(http.StatusUnauthorized, msg))
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(ErrResponse{HTTPStatusCode: http.StatusUnauthorized, Message: msg})
+	}
+	return auth.Middleware(allow, deny)(next)
 }
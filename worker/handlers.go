@@ -37,9 +37,24 @@ func (a *Api) StartTaskHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	a.Worker.AddTask(te.Task)
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(te.Task)
+	if te.Task.State != task.Scheduled {
+		w.WriteHeader(http.StatusBadRequest)
+		e := ErrResponse{
+			HTTPStatusCode: http.StatusBadRequest,
+			Message:        fmt.Sprintf("task %v must be in state %v to be started", te.Task.ID, task.Scheduled),
+		}
+		json.NewEncoder(w).Encode(e)
+		return
+	}
+
+	ack, created := a.Worker.Accept(te)
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusOK)
+	}
+	json.NewEncoder(w).Encode(ack)
 }
 
 func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
@@ -78,6 +93,10 @@ func (a *Api) StopTaskHandler(w http.ResponseWriter, r *http.Request) {
 
 	taskCopy := *taskToStop
 	taskCopy.State = task.Completed
+	// The manager preempts the tasks it is going to place again.
+	if r.URL.Query().Get("preempt") == "true" {
+		taskCopy.State = task.Preempted
+	}
 	a.Worker.AddTask(taskCopy)
 	w.WriteHeader(http.StatusNoContent)
 }
@@ -87,3 +106,14 @@ func (a *Api) GetStatsHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 	json.NewEncoder(w).Encode(a.Worker.Stats)
 }
+
+func (a *Api) GetTaskUsageHandler(w http.ResponseWriter, r *http.Request) {
+	usage := a.Worker.TaskUsage()
+	if usage == nil {
+		usage = []task.Usage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(usage)
+}
@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/dev6699/cube/auth"
+	"github.com/dev6699/cube/node"
+	"github.com/dev6699/cube/stats"
+)
+
+// HeartbeatInterval is how often a worker that joined a manager reports
+// that it is alive.
+const HeartbeatInterval = 5 * time.Second
+
+var errNotRegistered = errors.New("worker is not registered")
+
+// Join registers the worker with one of managers and sends heartbeats
+// until ctx is done. advertise is the host:port the manager should use to
+// reach this worker. Registration is retried while the manager is
+// unreachable and repeated whenever the manager has forgotten the worker,
+// e.g. after a manager restart or when another replica took over. When
+// several managers are given, the worker moves on to the next one after a
+// failed request.
+func (w *Worker) Join(ctx context.Context, managers []string, advertise string, labels map[string]string) error {
+	if len(managers) == 0 {
+		return fmt.Errorf("no manager to join")
+	}
+
+	ticker := time.NewTicker(HeartbeatInterval)
+	defer ticker.Stop()
+	client := &http.Client{
+		Timeout:   HeartbeatInterval,
+		Transport: &auth.Transport{Token: w.ClusterToken},
+	}
+
+	name := ""
+	for i := 0; ; {
+		manager := managers[i%len(managers)]
+		var err error
+		if name == "" {
+			name, err = w.register(client, manager, advertise, labels)
+			if err == nil {
+				log.Printf("[worker] joined manager %s as %s\n", manager, name)
+			}
+		} else {
+			err = heartbeat(client, manager, name)
+			if errors.Is(err, errNotRegistered) {
+				name = ""
+			}
+		}
+		if err != nil && !errors.Is(err, errNotRegistered) {
+			log.Printf("[worker] error talking to manager %s: %v\n", manager, err)
+			i++
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// register announces the worker and its capacity to the manager and
+// returns the name the manager knows it by.
+func (w *Worker) register(client *http.Client, manager string, advertise string, labels map[string]string) (string, error) {
+	reg := node.Registration{
+		Name:    w.Name,
+		Address: advertise,
+		Cores:   runtime.NumCPU(),
+		Memory:  int64(stats.GetMemoryInfo().MemTotal),
+		Disk:    int64(stats.GetDiskInfo().All),
+		Labels:  labels,
+	}
+	data, err := json.Marshal(reg)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("http://%s/nodes", manager)
+	resp, err := client.Post(url, "application/json", bytes.NewBuffer(data))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("invalid status code: %d", resp.StatusCode)
+	}
+
+	var n node.Node
+	err = json.NewDecoder(resp.Body).Decode(&n)
+	if err != nil {
+		return "", err
+	}
+	return n.Name, nil
+}
+
+func heartbeat(client *http.Client, manager string, name string) error {
+	url := fmt.Sprintf("http://%s/nodes/%s/heartbeat", manager, name)
+	req, err := http.NewRequest(http.MethodPut, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return errNotRegistered
+	default:
+		return fmt.Errorf("invalid status code: %d", resp.StatusCode)
+	}
+}
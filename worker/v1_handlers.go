@@ -0,0 +1,128 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/store"
+	"github.com/dev6699/cube/task"
+	"github.com/docker/docker/client"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// v1Routes serves version 1 of the API, which is documented by
+// v1.WorkerSpec.
+func (a *Api) v1Routes(r chi.Router) {
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		v1.RespondError(w, v1.NewError(http.StatusNotFound, fmt.Sprintf("no route %s", r.URL.Path)))
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		v1.RespondError(w, v1.NewError(http.StatusMethodNotAllowed, fmt.Sprintf("method %s is not allowed on %s", r.Method, r.URL.Path)))
+	})
+
+	r.Get("/openapi.json", v1.SpecHandler(v1.WorkerSpec))
+	r.Get("/tasks", a.V1ListTasksHandler)
+	r.Get("/tasks/{taskID}", a.V1GetTaskHandler)
+	r.Delete("/tasks/{taskID}", a.V1StopTaskHandler)
+	r.Get("/tasks/{taskID}/logs", a.V1TaskLogsHandler)
+}
+
+func (a *Api) V1ListTasksHandler(w http.ResponseWriter, r *http.Request) {
+	v1.Respond(w, http.StatusOK, v1.TaskList{Items: v1.FromTasks(a.Worker.GetTasks())})
+}
+
+func (a *Api) V1GetTaskHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.v1Task(w, r)
+	if !ok {
+		return
+	}
+
+	v1.Respond(w, http.StatusOK, v1.FromTask(t))
+}
+
+func (a *Api) V1StopTaskHandler(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.v1Task(w, r)
+	if !ok {
+		return
+	}
+
+	taskCopy := *t
+	taskCopy.State = task.Completed
+	a.Worker.AddTask(taskCopy)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// v1Task looks up the task named in the request path. It responds with an
+// error and reports false if there is none.
+func (a *Api) v1Task(w http.ResponseWriter, r *http.Request) (*task.Task, bool) {
+	id, err := uuid.Parse(chi.URLParam(r, "taskID"))
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, fmt.Sprintf("invalid task ID: %v", err)))
+		return nil, false
+	}
+
+	t, err := a.Worker.Db.Get(id.String())
+	if errors.Is(err, store.ErrNotFound) {
+		v1.RespondError(w, v1.NewError(http.StatusNotFound, fmt.Sprintf("no task with ID %v found", id)))
+		return nil, false
+	}
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusInternalServerError, err.Error()))
+		return nil, false
+	}
+	return t, true
+}
+
+func (a *Api) V1TaskLogsHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := v1.ParseLogOptions(r.URL.Query())
+	if err != nil {
+		v1.RespondError(w, v1.NewError(http.StatusBadRequest, err.Error()))
+		return
+	}
+	t, ok := a.v1Task(w, r)
+	if !ok {
+		return
+	}
+	if t.ContainerID == "" {
+		v1.RespondError(w, v1.NewError(http.StatusConflict, fmt.Sprintf("task %v has no container yet", t.ID)))
+		return
+	}
+
+	out := &logWriter{w: w}
+	err = a.Worker.TaskLogs(r.Context(), *t, opts.Follow, opts.Tail, out)
+	if err == nil || out.started {
+		if err != nil && r.Context().Err() == nil {
+			log.Printf("[worker] error streaming logs of task %s: %v\n", t.ID, err)
+		}
+		return
+	}
+	if client.IsErrNotFound(err) {
+		v1.RespondError(w, v1.NewError(http.StatusNotFound, fmt.Sprintf("the container of task %v is gone", t.ID)))
+		return
+	}
+	v1.RespondError(w, v1.NewError(http.StatusInternalServerError, fmt.Sprintf("error reading logs: %v", err)))
+}
+
+// logWriter streams logs to a response. The response starts with the first
+// write, so that errors before it can still be reported.
+type logWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (l *logWriter) Write(p []byte) (int, error) {
+	if !l.started {
+		l.w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		l.w.WriteHeader(http.StatusOK)
+		l.started = true
+	}
+	n, err := l.w.Write(p)
+	if f, ok := l.w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	v1 "github.com/dev6699/cube/api/v1"
+	"github.com/dev6699/cube/task"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func TestV1RoutesMatchSpec(t *testing.T) {
+	api := NewApi("", 0, nil)
+	api.initRouter()
+
+	var routes []string
+	err := chi.Walk(api.Router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if strings.HasPrefix(route, "/v1/") {
+			routes = append(routes, method+" "+route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(routes)
+
+	documented, err := v1.Operations(v1.WorkerSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(routes, documented) {
+		t.Errorf("routes %v\ndo not match the documented operations %v", routes, documented)
+	}
+}
+
+func TestV1Tasks(t *testing.T) {
+	w, err := New("worker", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tk := &task.Task{ID: uuid.New(), Name: "echo", Image: "alpine", State: task.Running}
+	if err := w.Db.Put(tk.ID.String(), tk); err != nil {
+		t.Fatal(err)
+	}
+	api := NewApi("", 0, w)
+	api.initRouter()
+	srv := httptest.NewServer(api.Router)
+	defer srv.Close()
+
+	get := func(path string, out any) int {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	var list v1.TaskList
+	if status := get("/v1/tasks", &list); status != http.StatusOK {
+		t.Fatalf("list tasks: got status %d", status)
+	}
+	if len(list.Items) != 1 || list.Items[0].ID != tk.ID || list.Items[0].State != "Running" {
+		t.Errorf("got tasks %+v, want the running task", list.Items)
+	}
+
+	var e v1.Error
+	if status := get("/v1/tasks/"+uuid.NewString(), &e); status != http.StatusNotFound || e.Reason != v1.ReasonNotFound {
+		t.Errorf("get unknown task: got status %d and %+v", status, e)
+	}
+}
+
+func TestClusterToken(t *testing.T) {
+	w, err := New("worker", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewApi("", 0, w)
+	api.Token = "cluster-secret"
+	api.initRouter()
+	srv := httptest.NewServer(api.Router)
+	defer srv.Close()
+
+	for token, want := range map[string]int{"": http.StatusUnauthorized, "wrong": http.StatusUnauthorized, "cluster-secret": http.StatusOK} {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/v1/tasks", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("token %q: got status %d, want %d", token, resp.StatusCode, want)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/dev6699/cube/queue"
@@ -12,14 +14,51 @@ import (
 	"github.com/dev6699/cube/store"
 	"github.com/dev6699/cube/task"
 	"github.com/docker/docker/api/types"
+	"github.com/google/uuid"
 )
 
+// DefaultStartLease is how long a worker takes at most to start a task it
+// accepted, including pulling its image.
+const DefaultStartLease = 2 * time.Minute
+
+// acceptedTTL is how long the worker remembers the event a task was
+// accepted with, well beyond the time a manager repeats a request in.
+const acceptedTTL = 10 * time.Minute
+
 type Worker struct {
 	Name      string
 	Queue     queue.Queue[task.Task]
 	Db        store.Store[*task.Task]
 	TaskCount int
 	Stats     *stats.Stats
+	// StartLease is the time within which the worker promises to start
+	// the tasks it accepts.
+	StartLease time.Duration
+	// ClusterToken is sent with the requests to the manager.
+	ClusterToken string
+
+	wake chan struct{}
+
+	mu sync.Mutex
+	// taskUsage is the latest sample of the usage of the running tasks.
+	taskUsage []task.Usage
+	// accepted maps the tasks the worker accepted to the event that
+	// started them last. Entries are dropped when the task is stopped or
+	// after acceptedTTL.
+	accepted map[uuid.UUID]acceptance
+}
+
+type acceptance struct {
+	event uuid.UUID
+	at    time.Time
+}
+
+// Ack is the worker's answer to a task it accepted. The worker promises to
+// start the task within Lease; a manager that does not see it running by
+// then may place it elsewhere.
+type Ack struct {
+	TaskID uuid.UUID
+	Lease  time.Duration
 }
 
 func New(name string, taskDbType string) (*Worker, error) {
@@ -39,19 +78,29 @@ func New(name string, taskDbType string) (*Worker, error) {
 	}
 
 	return &Worker{
-		Name:  name,
-		Queue: queue.Queue[task.Task]{},
-		Db:    s,
+		Name:       name,
+		Queue:      queue.Queue[task.Task]{},
+		Db:         s,
+		StartLease: DefaultStartLease,
+		wake:       make(chan struct{}, 1),
+		accepted:   make(map[uuid.UUID]acceptance),
 	}, nil
 }
 
 func (w *Worker) CollectStats(ctx context.Context) error {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-time.NewTicker(15 * time.Second).C:
+		case <-ticker.C:
 			log.Println("[worker] collecting stats")
 			w.Stats = stats.GetStats()
 			w.Stats.TaskCount = w.TaskCount
+			usage := w.collectTaskUsage(ctx)
+			w.mu.Lock()
+			w.taskUsage = usage
+			w.mu.Unlock()
 
 		case <-ctx.Done():
 			return nil
@@ -59,21 +108,63 @@ func (w *Worker) CollectStats(ctx context.Context) error {
 	}
 }
 
+// TaskUsage returns the latest sample of the usage of the running tasks.
+func (w *Worker) TaskUsage() []task.Usage {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.taskUsage
+}
+
+// collectTaskUsage samples the resource usage of every running task.
+func (w *Worker) collectTaskUsage(ctx context.Context) []task.Usage {
+	usage := []task.Usage{}
+	for _, t := range w.GetTasks() {
+		if t.State != task.Running {
+			continue
+		}
+
+		config := task.NewConfig(t)
+		d, err := task.NewDocker(config)
+		if err != nil {
+			log.Printf("[worker] failed to collect usage of task %s: %v\n", t.ID, err)
+			continue
+		}
+
+		u, err := d.Stats(ctx, t.ContainerID)
+		if err != nil {
+			log.Printf("[worker] failed to collect usage of task %s: %v\n", t.ID, err)
+			continue
+		}
+
+		u.TaskID = t.ID
+		usage = append(usage, *u)
+	}
+	return usage
+}
+
+// RunTasks runs queued tasks as soon as they are added, with a periodic
+// pass to pick up anything missed.
 func (w *Worker) RunTasks(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-time.NewTicker(10 * time.Second).C:
-			if w.Queue.Len() != 0 {
-				log.Println("[worker] running tasks")
-				_, err := w.runTask(ctx)
-				if err != nil {
-					log.Printf("[worker] error running task: %v\n", err)
-				}
-			}
-
+		case <-w.wake:
+		case <-ticker.C:
 		case <-ctx.Done():
 			return nil
 		}
+
+		if w.Queue.Len() != 0 {
+			log.Println("[worker] running tasks")
+		}
+		for w.Queue.Len() != 0 && ctx.Err() == nil {
+			_, err := w.runTask(ctx)
+			if err != nil {
+				log.Printf("[worker] error running task: %v\n", err)
+			}
+		}
 	}
 }
 
@@ -103,7 +194,7 @@ func (w *Worker) runTask(ctx context.Context) (*task.DockerResult, error) {
 		case task.Scheduled:
 			result, err = w.StartTask(ctx, taskQueued)
 
-		case task.Completed:
+		case task.Completed, task.Preempted:
 			result, err = w.StopTask(ctx, taskQueued)
 
 		default:
@@ -143,6 +234,8 @@ func (w *Worker) StartTask(ctx context.Context, t task.Task) (*task.DockerResult
 	return result, nil
 }
 
+// StopTask stops the container of t and records t as Completed, or as
+// Preempted if the manager is going to place it again.
 func (w *Worker) StopTask(ctx context.Context, t task.Task) (*task.DockerResult, error) {
 	config := task.NewConfig(&t)
 	d, err := task.NewDocker(config)
@@ -156,18 +249,59 @@ func (w *Worker) StopTask(ctx context.Context, t task.Task) (*task.DockerResult,
 	}
 
 	t.FinishTime = time.Now().UTC()
-	t.State = task.Completed
+	if t.State != task.Preempted {
+		t.State = task.Completed
+	}
 	err = w.Db.Put(t.ID.String(), &t)
 	if err != nil {
 		return nil, err
 	}
+	w.forget(t.ID)
 
 	w.TaskCount--
 	return result, nil
 }
 
+// Accept queues the task of te to be started. It reports false if te was
+// accepted before, so that a manager that did not get the answer to its
+// request can repeat it without the task being started twice. A task
+// placed on the worker again comes with a new event and is queued again.
+func (w *Worker) Accept(te task.TaskEvent) (Ack, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	now := time.Now()
+	for id, a := range w.accepted {
+		if now.Sub(a.at) > acceptedTTL {
+			delete(w.accepted, id)
+		}
+	}
+
+	ack := Ack{
+		TaskID: te.Task.ID,
+		Lease:  w.StartLease,
+	}
+	if a, ok := w.accepted[te.Task.ID]; ok && a.event == te.ID {
+		return ack, false
+	}
+	w.accepted[te.Task.ID] = acceptance{event: te.ID, at: now}
+	w.AddTask(te.Task)
+	return ack, true
+}
+
+// forget drops the event the task with the given ID was accepted with.
+func (w *Worker) forget(id uuid.UUID) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.accepted, id)
+}
+
 func (w *Worker) AddTask(t task.Task) {
 	w.Queue.Enqueue(t)
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
 }
 
 func (w *Worker) GetTasks() []*task.Task {
@@ -179,9 +313,12 @@ func (w *Worker) GetTasks() []*task.Task {
 }
 
 func (w *Worker) UpdateTasks(ctx context.Context) error {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
 	for {
 		select {
-		case <-time.NewTicker(15 * time.Second).C:
+		case <-ticker.C:
 			log.Println("[worker] updating tasks")
 			err := w.updateTasks(ctx)
 			if err != nil {
@@ -216,7 +353,15 @@ func (w *Worker) updateTasks(ctx context.Context) error {
 
 		if resp.State.Status == "exited" {
 			t.State = task.Failed
+			if resp.State.ExitCode == 0 {
+				t.State = task.Completed
+			}
+			finishedAt, err := time.Parse(time.RFC3339Nano, resp.State.FinishedAt)
+			if err == nil {
+				t.FinishTime = finishedAt.UTC()
+			}
 			w.Db.Put(key, t)
+			w.TaskCount--
 			continue
 		}
 
@@ -227,6 +372,16 @@ func (w *Worker) updateTasks(ctx context.Context) error {
 	return nil
 }
 
+// TaskLogs writes the output of t's container to out, as Docker.Logs does.
+func (w *Worker) TaskLogs(ctx context.Context, t task.Task, follow bool, tail int, out io.Writer) error {
+	config := task.NewConfig(&t)
+	d, err := task.NewDocker(config)
+	if err != nil {
+		return err
+	}
+	return d.Logs(ctx, t.ContainerID, follow, tail, out)
+}
+
 func (w *Worker) InspectTask(ctx context.Context, t task.Task) (types.ContainerJSON, error) {
 	config := task.NewConfig(&t)
 	d, err := task.NewDocker(config)
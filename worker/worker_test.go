@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+func TestAccept(t *testing.T) {
+	w, err := New("worker", "memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	te := task.TaskEvent{ID: uuid.New(), State: task.Scheduled, Task: task.Task{ID: uuid.New(), State: task.Scheduled}}
+
+	if _, created := w.Accept(te); !created {
+		t.Error("the first event was not accepted")
+	}
+	if _, created := w.Accept(te); created {
+		t.Error("a repeated event was accepted again")
+	}
+
+	// The manager places the task again with a new event.
+	again := te
+	again.ID = uuid.New()
+	if _, created := w.Accept(again); !created {
+		t.Error("a new event for the task was not accepted")
+	}
+
+	w.forget(te.Task.ID)
+	if _, created := w.Accept(again); !created {
+		t.Error("an event of a stopped task was not accepted")
+	}
+	if n := w.Queue.Len(); n != 3 {
+		t.Errorf("got %d queued tasks, want 3", n)
+	}
+}
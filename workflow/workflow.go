@@ -0,0 +1,202 @@
+package workflow
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"time"
+
+	"github.com/dev6699/cube/labels"
+	"github.com/dev6699/cube/namespace"
+	"github.com/dev6699/cube/task"
+	"github.com/google/uuid"
+)
+
+// Kind is the owner kind recorded on tasks created for a workflow.
+const Kind = "Workflow"
+
+type State string
+
+const (
+	Running   State = "Running"
+	Succeeded State = "Succeeded"
+	Failed    State = "Failed"
+)
+
+type StepState string
+
+const (
+	StepPending   StepState = "Pending"
+	StepRunning   StepState = "Running"
+	StepCompleted StepState = "Completed"
+	StepFailed    StepState = "Failed"
+	StepSkipped   StepState = "Skipped"
+)
+
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Workflow is a graph of steps. A step's task is only scheduled once every
+// step it depends on has completed; if one of them fails or is skipped,
+// the step is skipped too.
+type Workflow struct {
+	Name      string
+	Namespace string
+	Steps     []Step
+	CreatedAt time.Time
+	Status    Status
+}
+
+type Step struct {
+	Name      string
+	DependsOn []string
+	Task      task.Task
+}
+
+type Status struct {
+	State          State
+	Steps          []StepStatus
+	StartTime      time.Time
+	CompletionTime time.Time
+}
+
+type StepStatus struct {
+	Name    string
+	State   StepState
+	TaskID  uuid.UUID
+	Message string
+}
+
+// Validate checks that step names are unique, that dependencies exist and
+// that the steps form an acyclic graph.
+func (w *Workflow) Validate() error {
+	if !validName.MatchString(w.Name) {
+		return fmt.Errorf("invalid workflow name %q", w.Name)
+	}
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("workflow has no steps")
+	}
+
+	names := make(map[string]bool)
+	for _, s := range w.Steps {
+		if !validName.MatchString(s.Name) {
+			return fmt.Errorf("invalid step name %q", s.Name)
+		}
+		if names[s.Name] {
+			return fmt.Errorf("duplicate step name %q", s.Name)
+		}
+		if s.Task.Image == "" {
+			return fmt.Errorf("step %s: task image is required", s.Name)
+		}
+		err := labels.Validate(s.Task.Labels)
+		if err != nil {
+			return fmt.Errorf("step %s: %v", s.Name, err)
+		}
+		names[s.Name] = true
+	}
+
+	for _, s := range w.Steps {
+		for _, dep := range s.DependsOn {
+			if !names[dep] {
+				return fmt.Errorf("step %s depends on unknown step %q", s.Name, dep)
+			}
+		}
+	}
+
+	_, err := w.Order()
+	return err
+}
+
+// Order returns the indexes of the steps in topological order.
+func (w *Workflow) Order() ([]int, error) {
+	index := make(map[string]int)
+	for i, s := range w.Steps {
+		index[s.Name] = i
+	}
+
+	inDegree := make([]int, len(w.Steps))
+	dependents := make([][]int, len(w.Steps))
+	for i, s := range w.Steps {
+		for _, dep := range s.DependsOn {
+			inDegree[i]++
+			dependents[index[dep]] = append(dependents[index[dep]], i)
+		}
+	}
+
+	var ready, order []int
+	for i := range w.Steps {
+		if inDegree[i] == 0 {
+			ready = append(ready, i)
+		}
+	}
+	for len(ready) > 0 {
+		i := ready[0]
+		ready = ready[1:]
+		order = append(order, i)
+		for _, d := range dependents[i] {
+			inDegree[d]--
+			if inDegree[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(order) != len(w.Steps) {
+		return nil, fmt.Errorf("workflow %s has a dependency cycle", w.Name)
+	}
+	return order, nil
+}
+
+// Levels returns the depth of every step in the graph: steps without
+// dependencies are at level 0, the others one level below their deepest
+// dependency.
+func (w *Workflow) Levels() map[string]int {
+	levels := make(map[string]int)
+	order, err := w.Order()
+	if err != nil {
+		return levels
+	}
+
+	for _, i := range order {
+		s := w.Steps[i]
+		level := 0
+		for _, dep := range s.DependsOn {
+			level = max(level, levels[dep]+1)
+		}
+		levels[s.Name] = level
+	}
+	return levels
+}
+
+// Finished reports whether every step has reached a final state.
+func (w *Workflow) Finished() bool {
+	return w.Status.State == Succeeded || w.Status.State == Failed
+}
+
+// NewTask returns a fresh task for the step at index i.
+func (w *Workflow) NewTask(i int) task.Task {
+	s := w.Steps[i]
+	t := s.Task
+	t.ID = uuid.New()
+	t.Name = fmt.Sprintf("%s-%s-%s", w.Name, s.Name, t.ID.String()[:8])
+	t.State = task.Pending
+	t.ContainerID = ""
+	t.HostPorts = nil
+	t.StartTime = time.Time{}
+	t.FinishTime = time.Time{}
+	t.RestartCount = 0
+	t.Labels = maps.Clone(s.Task.Labels)
+	t.Annotations = maps.Clone(s.Task.Annotations)
+	t.Namespace = w.Namespace
+	t.DesiredState = task.Running
+	t.Owner = &task.Owner{
+		Kind:  Kind,
+		Name:  w.Name,
+		Index: i,
+	}
+	return t
+}
+
+// Key returns the store key of the workflow, which is unique across namespaces.
+func (w *Workflow) Key() string {
+	return namespace.Key(w.Namespace, w.Name)
+}